@@ -0,0 +1,1715 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: api/admin.proto
+
+package api
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type ServiceInfo struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ServiceId            int32    `protobuf:"varint,2,opt,name=service_id,json=serviceId,proto3" json:"service_id,omitempty"`
+	CanonicalName        string   `protobuf:"bytes,3,opt,name=canonical_name,json=canonicalName,proto3" json:"canonical_name,omitempty"`
+	Retired              bool     `protobuf:"varint,4,opt,name=retired,proto3" json:"retired,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ServiceInfo) Reset()         { *m = ServiceInfo{} }
+func (m *ServiceInfo) String() string { return proto.CompactTextString(m) }
+func (*ServiceInfo) ProtoMessage()    {}
+func (*ServiceInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{0}
+}
+
+func (m *ServiceInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServiceInfo.Unmarshal(m, b)
+}
+func (m *ServiceInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServiceInfo.Marshal(b, m, deterministic)
+}
+func (m *ServiceInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceInfo.Merge(m, src)
+}
+func (m *ServiceInfo) XXX_Size() int {
+	return xxx_messageInfo_ServiceInfo.Size(m)
+}
+func (m *ServiceInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServiceInfo proto.InternalMessageInfo
+
+func (m *ServiceInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ServiceInfo) GetServiceId() int32 {
+	if m != nil {
+		return m.ServiceId
+	}
+	return 0
+}
+
+func (m *ServiceInfo) GetCanonicalName() string {
+	if m != nil {
+		return m.CanonicalName
+	}
+	return ""
+}
+
+func (m *ServiceInfo) GetRetired() bool {
+	if m != nil {
+		return m.Retired
+	}
+	return false
+}
+
+type RegisterServiceRequest struct {
+	ServiceName          string   `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	ServiceId            int32    `protobuf:"varint,2,opt,name=service_id,json=serviceId,proto3" json:"service_id,omitempty"`
+	Namespace            string   `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterServiceRequest) Reset()         { *m = RegisterServiceRequest{} }
+func (m *RegisterServiceRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterServiceRequest) ProtoMessage()    {}
+func (*RegisterServiceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{1}
+}
+
+func (m *RegisterServiceRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterServiceRequest.Unmarshal(m, b)
+}
+func (m *RegisterServiceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterServiceRequest.Marshal(b, m, deterministic)
+}
+func (m *RegisterServiceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterServiceRequest.Merge(m, src)
+}
+func (m *RegisterServiceRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterServiceRequest.Size(m)
+}
+func (m *RegisterServiceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterServiceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterServiceRequest proto.InternalMessageInfo
+
+func (m *RegisterServiceRequest) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *RegisterServiceRequest) GetServiceId() int32 {
+	if m != nil {
+		return m.ServiceId
+	}
+	return 0
+}
+
+func (m *RegisterServiceRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type AddAliasRequest struct {
+	Alias                string   `protobuf:"bytes,1,opt,name=alias,proto3" json:"alias,omitempty"`
+	ServiceName          string   `protobuf:"bytes,2,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Namespace            string   `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddAliasRequest) Reset()         { *m = AddAliasRequest{} }
+func (m *AddAliasRequest) String() string { return proto.CompactTextString(m) }
+func (*AddAliasRequest) ProtoMessage()    {}
+func (*AddAliasRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{2}
+}
+
+func (m *AddAliasRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddAliasRequest.Unmarshal(m, b)
+}
+func (m *AddAliasRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddAliasRequest.Marshal(b, m, deterministic)
+}
+func (m *AddAliasRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddAliasRequest.Merge(m, src)
+}
+func (m *AddAliasRequest) XXX_Size() int {
+	return xxx_messageInfo_AddAliasRequest.Size(m)
+}
+func (m *AddAliasRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddAliasRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddAliasRequest proto.InternalMessageInfo
+
+func (m *AddAliasRequest) GetAlias() string {
+	if m != nil {
+		return m.Alias
+	}
+	return ""
+}
+
+func (m *AddAliasRequest) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *AddAliasRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type RetireServiceRequest struct {
+	ServiceName          string   `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RetireServiceRequest) Reset()         { *m = RetireServiceRequest{} }
+func (m *RetireServiceRequest) String() string { return proto.CompactTextString(m) }
+func (*RetireServiceRequest) ProtoMessage()    {}
+func (*RetireServiceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{3}
+}
+
+func (m *RetireServiceRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RetireServiceRequest.Unmarshal(m, b)
+}
+func (m *RetireServiceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RetireServiceRequest.Marshal(b, m, deterministic)
+}
+func (m *RetireServiceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetireServiceRequest.Merge(m, src)
+}
+func (m *RetireServiceRequest) XXX_Size() int {
+	return xxx_messageInfo_RetireServiceRequest.Size(m)
+}
+func (m *RetireServiceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RetireServiceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RetireServiceRequest proto.InternalMessageInfo
+
+func (m *RetireServiceRequest) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *RetireServiceRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type GetServiceRequest struct {
+	ServiceName          string   `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetServiceRequest) Reset()         { *m = GetServiceRequest{} }
+func (m *GetServiceRequest) String() string { return proto.CompactTextString(m) }
+func (*GetServiceRequest) ProtoMessage()    {}
+func (*GetServiceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{4}
+}
+
+func (m *GetServiceRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetServiceRequest.Unmarshal(m, b)
+}
+func (m *GetServiceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetServiceRequest.Marshal(b, m, deterministic)
+}
+func (m *GetServiceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetServiceRequest.Merge(m, src)
+}
+func (m *GetServiceRequest) XXX_Size() int {
+	return xxx_messageInfo_GetServiceRequest.Size(m)
+}
+func (m *GetServiceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetServiceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetServiceRequest proto.InternalMessageInfo
+
+func (m *GetServiceRequest) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *GetServiceRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type ResolveRequest struct {
+	Uuid                 int64    `protobuf:"varint,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResolveRequest) Reset()         { *m = ResolveRequest{} }
+func (m *ResolveRequest) String() string { return proto.CompactTextString(m) }
+func (*ResolveRequest) ProtoMessage()    {}
+func (*ResolveRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{5}
+}
+
+func (m *ResolveRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResolveRequest.Unmarshal(m, b)
+}
+func (m *ResolveRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResolveRequest.Marshal(b, m, deterministic)
+}
+func (m *ResolveRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResolveRequest.Merge(m, src)
+}
+func (m *ResolveRequest) XXX_Size() int {
+	return xxx_messageInfo_ResolveRequest.Size(m)
+}
+func (m *ResolveRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResolveRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResolveRequest proto.InternalMessageInfo
+
+func (m *ResolveRequest) GetUuid() int64 {
+	if m != nil {
+		return m.Uuid
+	}
+	return 0
+}
+
+func (m *ResolveRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type ResolveReply struct {
+	ServiceId            int32    `protobuf:"varint,1,opt,name=service_id,json=serviceId,proto3" json:"service_id,omitempty"`
+	ContainerId          int32    `protobuf:"varint,2,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	SequenceId           int32    `protobuf:"varint,3,opt,name=sequence_id,json=sequenceId,proto3" json:"sequence_id,omitempty"`
+	ServiceName          string   `protobuf:"bytes,4,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	ContainerName        string   `protobuf:"bytes,5,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	ContainerReassigned  bool     `protobuf:"varint,6,opt,name=container_reassigned,json=containerReassigned,proto3" json:"container_reassigned,omitempty"`
+	Allocated            bool     `protobuf:"varint,7,opt,name=allocated,proto3" json:"allocated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResolveReply) Reset()         { *m = ResolveReply{} }
+func (m *ResolveReply) String() string { return proto.CompactTextString(m) }
+func (*ResolveReply) ProtoMessage()    {}
+func (*ResolveReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{6}
+}
+
+func (m *ResolveReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResolveReply.Unmarshal(m, b)
+}
+func (m *ResolveReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResolveReply.Marshal(b, m, deterministic)
+}
+func (m *ResolveReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResolveReply.Merge(m, src)
+}
+func (m *ResolveReply) XXX_Size() int {
+	return xxx_messageInfo_ResolveReply.Size(m)
+}
+func (m *ResolveReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResolveReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResolveReply proto.InternalMessageInfo
+
+func (m *ResolveReply) GetServiceId() int32 {
+	if m != nil {
+		return m.ServiceId
+	}
+	return 0
+}
+
+func (m *ResolveReply) GetContainerId() int32 {
+	if m != nil {
+		return m.ContainerId
+	}
+	return 0
+}
+
+func (m *ResolveReply) GetSequenceId() int32 {
+	if m != nil {
+		return m.SequenceId
+	}
+	return 0
+}
+
+func (m *ResolveReply) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *ResolveReply) GetContainerName() string {
+	if m != nil {
+		return m.ContainerName
+	}
+	return ""
+}
+
+func (m *ResolveReply) GetContainerReassigned() bool {
+	if m != nil {
+		return m.ContainerReassigned
+	}
+	return false
+}
+
+func (m *ResolveReply) GetAllocated() bool {
+	if m != nil {
+		return m.Allocated
+	}
+	return false
+}
+
+type ListRequest struct {
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// limit the number of the newest entries returned by ListReassignments, a default is used if zero
+	Limit                int32    `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()    {}
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{7}
+}
+
+func (m *ListRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListRequest.Unmarshal(m, b)
+}
+func (m *ListRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListRequest.Marshal(b, m, deterministic)
+}
+func (m *ListRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListRequest.Merge(m, src)
+}
+func (m *ListRequest) XXX_Size() int {
+	return xxx_messageInfo_ListRequest.Size(m)
+}
+func (m *ListRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListRequest proto.InternalMessageInfo
+
+func (m *ListRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *ListRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type ListServicesReply struct {
+	Services             []*ServiceInfo `protobuf:"bytes,1,rep,name=services,proto3" json:"services,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ListServicesReply) Reset()         { *m = ListServicesReply{} }
+func (m *ListServicesReply) String() string { return proto.CompactTextString(m) }
+func (*ListServicesReply) ProtoMessage()    {}
+func (*ListServicesReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{8}
+}
+
+func (m *ListServicesReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListServicesReply.Unmarshal(m, b)
+}
+func (m *ListServicesReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListServicesReply.Marshal(b, m, deterministic)
+}
+func (m *ListServicesReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListServicesReply.Merge(m, src)
+}
+func (m *ListServicesReply) XXX_Size() int {
+	return xxx_messageInfo_ListServicesReply.Size(m)
+}
+func (m *ListServicesReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListServicesReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListServicesReply proto.InternalMessageInfo
+
+func (m *ListServicesReply) GetServices() []*ServiceInfo {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
+type ContainerInfo struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ContainerId          int32    `protobuf:"varint,2,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ContainerInfo) Reset()         { *m = ContainerInfo{} }
+func (m *ContainerInfo) String() string { return proto.CompactTextString(m) }
+func (*ContainerInfo) ProtoMessage()    {}
+func (*ContainerInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{9}
+}
+
+func (m *ContainerInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ContainerInfo.Unmarshal(m, b)
+}
+func (m *ContainerInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ContainerInfo.Marshal(b, m, deterministic)
+}
+func (m *ContainerInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ContainerInfo.Merge(m, src)
+}
+func (m *ContainerInfo) XXX_Size() int {
+	return xxx_messageInfo_ContainerInfo.Size(m)
+}
+func (m *ContainerInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_ContainerInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ContainerInfo proto.InternalMessageInfo
+
+func (m *ContainerInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ContainerInfo) GetContainerId() int32 {
+	if m != nil {
+		return m.ContainerId
+	}
+	return 0
+}
+
+type ListContainersReply struct {
+	Containers           []*ContainerInfo `protobuf:"bytes,1,rep,name=containers,proto3" json:"containers,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *ListContainersReply) Reset()         { *m = ListContainersReply{} }
+func (m *ListContainersReply) String() string { return proto.CompactTextString(m) }
+func (*ListContainersReply) ProtoMessage()    {}
+func (*ListContainersReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{10}
+}
+
+func (m *ListContainersReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListContainersReply.Unmarshal(m, b)
+}
+func (m *ListContainersReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListContainersReply.Marshal(b, m, deterministic)
+}
+func (m *ListContainersReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListContainersReply.Merge(m, src)
+}
+func (m *ListContainersReply) XXX_Size() int {
+	return xxx_messageInfo_ListContainersReply.Size(m)
+}
+func (m *ListContainersReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListContainersReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListContainersReply proto.InternalMessageInfo
+
+func (m *ListContainersReply) GetContainers() []*ContainerInfo {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+type Watermark struct {
+	ServiceId     int32  `protobuf:"varint,1,opt,name=service_id,json=serviceId,proto3" json:"service_id,omitempty"`
+	ContainerId   int32  `protobuf:"varint,2,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	ServiceName   string `protobuf:"bytes,3,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	ContainerName string `protobuf:"bytes,4,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	// next_sequence the first sequence not handed out yet
+	NextSequence         int32    `protobuf:"varint,5,opt,name=next_sequence,json=nextSequence,proto3" json:"next_sequence,omitempty"`
+	UsedPercent          float64  `protobuf:"fixed64,6,opt,name=used_percent,json=usedPercent,proto3" json:"used_percent,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Watermark) Reset()         { *m = Watermark{} }
+func (m *Watermark) String() string { return proto.CompactTextString(m) }
+func (*Watermark) ProtoMessage()    {}
+func (*Watermark) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{11}
+}
+
+func (m *Watermark) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Watermark.Unmarshal(m, b)
+}
+func (m *Watermark) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Watermark.Marshal(b, m, deterministic)
+}
+func (m *Watermark) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Watermark.Merge(m, src)
+}
+func (m *Watermark) XXX_Size() int {
+	return xxx_messageInfo_Watermark.Size(m)
+}
+func (m *Watermark) XXX_DiscardUnknown() {
+	xxx_messageInfo_Watermark.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Watermark proto.InternalMessageInfo
+
+func (m *Watermark) GetServiceId() int32 {
+	if m != nil {
+		return m.ServiceId
+	}
+	return 0
+}
+
+func (m *Watermark) GetContainerId() int32 {
+	if m != nil {
+		return m.ContainerId
+	}
+	return 0
+}
+
+func (m *Watermark) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *Watermark) GetContainerName() string {
+	if m != nil {
+		return m.ContainerName
+	}
+	return ""
+}
+
+func (m *Watermark) GetNextSequence() int32 {
+	if m != nil {
+		return m.NextSequence
+	}
+	return 0
+}
+
+func (m *Watermark) GetUsedPercent() float64 {
+	if m != nil {
+		return m.UsedPercent
+	}
+	return 0
+}
+
+type ListWatermarksReply struct {
+	Watermarks           []*Watermark `protobuf:"bytes,1,rep,name=watermarks,proto3" json:"watermarks,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *ListWatermarksReply) Reset()         { *m = ListWatermarksReply{} }
+func (m *ListWatermarksReply) String() string { return proto.CompactTextString(m) }
+func (*ListWatermarksReply) ProtoMessage()    {}
+func (*ListWatermarksReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{12}
+}
+
+func (m *ListWatermarksReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListWatermarksReply.Unmarshal(m, b)
+}
+func (m *ListWatermarksReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListWatermarksReply.Marshal(b, m, deterministic)
+}
+func (m *ListWatermarksReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListWatermarksReply.Merge(m, src)
+}
+func (m *ListWatermarksReply) XXX_Size() int {
+	return xxx_messageInfo_ListWatermarksReply.Size(m)
+}
+func (m *ListWatermarksReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListWatermarksReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListWatermarksReply proto.InternalMessageInfo
+
+func (m *ListWatermarksReply) GetWatermarks() []*Watermark {
+	if m != nil {
+		return m.Watermarks
+	}
+	return nil
+}
+
+type Reassignment struct {
+	ContainerName        string   `protobuf:"bytes,1,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	OldContainerId       int32    `protobuf:"varint,2,opt,name=old_container_id,json=oldContainerId,proto3" json:"old_container_id,omitempty"`
+	NewContainerId       int32    `protobuf:"varint,3,opt,name=new_container_id,json=newContainerId,proto3" json:"new_container_id,omitempty"`
+	Time                 int64    `protobuf:"varint,4,opt,name=time,proto3" json:"time,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Reassignment) Reset()         { *m = Reassignment{} }
+func (m *Reassignment) String() string { return proto.CompactTextString(m) }
+func (*Reassignment) ProtoMessage()    {}
+func (*Reassignment) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{13}
+}
+
+func (m *Reassignment) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Reassignment.Unmarshal(m, b)
+}
+func (m *Reassignment) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Reassignment.Marshal(b, m, deterministic)
+}
+func (m *Reassignment) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Reassignment.Merge(m, src)
+}
+func (m *Reassignment) XXX_Size() int {
+	return xxx_messageInfo_Reassignment.Size(m)
+}
+func (m *Reassignment) XXX_DiscardUnknown() {
+	xxx_messageInfo_Reassignment.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Reassignment proto.InternalMessageInfo
+
+func (m *Reassignment) GetContainerName() string {
+	if m != nil {
+		return m.ContainerName
+	}
+	return ""
+}
+
+func (m *Reassignment) GetOldContainerId() int32 {
+	if m != nil {
+		return m.OldContainerId
+	}
+	return 0
+}
+
+func (m *Reassignment) GetNewContainerId() int32 {
+	if m != nil {
+		return m.NewContainerId
+	}
+	return 0
+}
+
+func (m *Reassignment) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+type ListReassignmentsReply struct {
+	Reassignments        []*Reassignment `protobuf:"bytes,1,rep,name=reassignments,proto3" json:"reassignments,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *ListReassignmentsReply) Reset()         { *m = ListReassignmentsReply{} }
+func (m *ListReassignmentsReply) String() string { return proto.CompactTextString(m) }
+func (*ListReassignmentsReply) ProtoMessage()    {}
+func (*ListReassignmentsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{14}
+}
+
+func (m *ListReassignmentsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListReassignmentsReply.Unmarshal(m, b)
+}
+func (m *ListReassignmentsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListReassignmentsReply.Marshal(b, m, deterministic)
+}
+func (m *ListReassignmentsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListReassignmentsReply.Merge(m, src)
+}
+func (m *ListReassignmentsReply) XXX_Size() int {
+	return xxx_messageInfo_ListReassignmentsReply.Size(m)
+}
+func (m *ListReassignmentsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListReassignmentsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListReassignmentsReply proto.InternalMessageInfo
+
+func (m *ListReassignmentsReply) GetReassignments() []*Reassignment {
+	if m != nil {
+		return m.Reassignments
+	}
+	return nil
+}
+
+type LookupLedgerRequest struct {
+	Uuid                 int64    `protobuf:"varint,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LookupLedgerRequest) Reset()         { *m = LookupLedgerRequest{} }
+func (m *LookupLedgerRequest) String() string { return proto.CompactTextString(m) }
+func (*LookupLedgerRequest) ProtoMessage()    {}
+func (*LookupLedgerRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{15}
+}
+
+func (m *LookupLedgerRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LookupLedgerRequest.Unmarshal(m, b)
+}
+func (m *LookupLedgerRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LookupLedgerRequest.Marshal(b, m, deterministic)
+}
+func (m *LookupLedgerRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LookupLedgerRequest.Merge(m, src)
+}
+func (m *LookupLedgerRequest) XXX_Size() int {
+	return xxx_messageInfo_LookupLedgerRequest.Size(m)
+}
+func (m *LookupLedgerRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LookupLedgerRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LookupLedgerRequest proto.InternalMessageInfo
+
+func (m *LookupLedgerRequest) GetUuid() int64 {
+	if m != nil {
+		return m.Uuid
+	}
+	return 0
+}
+
+func (m *LookupLedgerRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type LedgerEntry struct {
+	Namespace       string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	ServiceName     string `protobuf:"bytes,2,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	ServiceId       int32  `protobuf:"varint,3,opt,name=service_id,json=serviceId,proto3" json:"service_id,omitempty"`
+	ContainerName   string `protobuf:"bytes,4,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	ContainerId     int32  `protobuf:"varint,5,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	SequenceIdStart int32  `protobuf:"varint,6,opt,name=sequence_id_start,json=sequenceIdStart,proto3" json:"sequence_id_start,omitempty"`
+	SequenceIdEnd   int32  `protobuf:"varint,7,opt,name=sequence_id_end,json=sequenceIdEnd,proto3" json:"sequence_id_end,omitempty"`
+	// peer the address of the client, identity the authenticated caller
+	Peer     string `protobuf:"bytes,8,opt,name=peer,proto3" json:"peer,omitempty"`
+	Identity string `protobuf:"bytes,9,opt,name=identity,proto3" json:"identity,omitempty"`
+	// instance the server that issued the range
+	Instance             string   `protobuf:"bytes,10,opt,name=instance,proto3" json:"instance,omitempty"`
+	TimeUnixNano         int64    `protobuf:"varint,11,opt,name=time_unix_nano,json=timeUnixNano,proto3" json:"time_unix_nano,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LedgerEntry) Reset()         { *m = LedgerEntry{} }
+func (m *LedgerEntry) String() string { return proto.CompactTextString(m) }
+func (*LedgerEntry) ProtoMessage()    {}
+func (*LedgerEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{16}
+}
+
+func (m *LedgerEntry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LedgerEntry.Unmarshal(m, b)
+}
+func (m *LedgerEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LedgerEntry.Marshal(b, m, deterministic)
+}
+func (m *LedgerEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LedgerEntry.Merge(m, src)
+}
+func (m *LedgerEntry) XXX_Size() int {
+	return xxx_messageInfo_LedgerEntry.Size(m)
+}
+func (m *LedgerEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_LedgerEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LedgerEntry proto.InternalMessageInfo
+
+func (m *LedgerEntry) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *LedgerEntry) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *LedgerEntry) GetServiceId() int32 {
+	if m != nil {
+		return m.ServiceId
+	}
+	return 0
+}
+
+func (m *LedgerEntry) GetContainerName() string {
+	if m != nil {
+		return m.ContainerName
+	}
+	return ""
+}
+
+func (m *LedgerEntry) GetContainerId() int32 {
+	if m != nil {
+		return m.ContainerId
+	}
+	return 0
+}
+
+func (m *LedgerEntry) GetSequenceIdStart() int32 {
+	if m != nil {
+		return m.SequenceIdStart
+	}
+	return 0
+}
+
+func (m *LedgerEntry) GetSequenceIdEnd() int32 {
+	if m != nil {
+		return m.SequenceIdEnd
+	}
+	return 0
+}
+
+func (m *LedgerEntry) GetPeer() string {
+	if m != nil {
+		return m.Peer
+	}
+	return ""
+}
+
+func (m *LedgerEntry) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *LedgerEntry) GetInstance() string {
+	if m != nil {
+		return m.Instance
+	}
+	return ""
+}
+
+func (m *LedgerEntry) GetTimeUnixNano() int64 {
+	if m != nil {
+		return m.TimeUnixNano
+	}
+	return 0
+}
+
+type LookupLedgerReply struct {
+	// entries the ranges that contain the UUID, more than one means it was issued twice
+	Entries              []*LedgerEntry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *LookupLedgerReply) Reset()         { *m = LookupLedgerReply{} }
+func (m *LookupLedgerReply) String() string { return proto.CompactTextString(m) }
+func (*LookupLedgerReply) ProtoMessage()    {}
+func (*LookupLedgerReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{17}
+}
+
+func (m *LookupLedgerReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LookupLedgerReply.Unmarshal(m, b)
+}
+func (m *LookupLedgerReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LookupLedgerReply.Marshal(b, m, deterministic)
+}
+func (m *LookupLedgerReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LookupLedgerReply.Merge(m, src)
+}
+func (m *LookupLedgerReply) XXX_Size() int {
+	return xxx_messageInfo_LookupLedgerReply.Size(m)
+}
+func (m *LookupLedgerReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_LookupLedgerReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LookupLedgerReply proto.InternalMessageInfo
+
+func (m *LookupLedgerReply) GetEntries() []*LedgerEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+type FsckRequest struct {
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// repair the problems that can be repaired safely
+	Repair               bool     `protobuf:"varint,2,opt,name=repair,proto3" json:"repair,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FsckRequest) Reset()         { *m = FsckRequest{} }
+func (m *FsckRequest) String() string { return proto.CompactTextString(m) }
+func (*FsckRequest) ProtoMessage()    {}
+func (*FsckRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{18}
+}
+
+func (m *FsckRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FsckRequest.Unmarshal(m, b)
+}
+func (m *FsckRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FsckRequest.Marshal(b, m, deterministic)
+}
+func (m *FsckRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FsckRequest.Merge(m, src)
+}
+func (m *FsckRequest) XXX_Size() int {
+	return xxx_messageInfo_FsckRequest.Size(m)
+}
+func (m *FsckRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FsckRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FsckRequest proto.InternalMessageInfo
+
+func (m *FsckRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *FsckRequest) GetRepair() bool {
+	if m != nil {
+		return m.Repair
+	}
+	return false
+}
+
+type FsckProblem struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Problem              string   `protobuf:"bytes,2,opt,name=problem,proto3" json:"problem,omitempty"`
+	Repaired             bool     `protobuf:"varint,3,opt,name=repaired,proto3" json:"repaired,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FsckProblem) Reset()         { *m = FsckProblem{} }
+func (m *FsckProblem) String() string { return proto.CompactTextString(m) }
+func (*FsckProblem) ProtoMessage()    {}
+func (*FsckProblem) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{19}
+}
+
+func (m *FsckProblem) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FsckProblem.Unmarshal(m, b)
+}
+func (m *FsckProblem) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FsckProblem.Marshal(b, m, deterministic)
+}
+func (m *FsckProblem) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FsckProblem.Merge(m, src)
+}
+func (m *FsckProblem) XXX_Size() int {
+	return xxx_messageInfo_FsckProblem.Size(m)
+}
+func (m *FsckProblem) XXX_DiscardUnknown() {
+	xxx_messageInfo_FsckProblem.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FsckProblem proto.InternalMessageInfo
+
+func (m *FsckProblem) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *FsckProblem) GetProblem() string {
+	if m != nil {
+		return m.Problem
+	}
+	return ""
+}
+
+func (m *FsckProblem) GetRepaired() bool {
+	if m != nil {
+		return m.Repaired
+	}
+	return false
+}
+
+type FsckReply struct {
+	// keys the number of keys checked
+	Keys                 int32          `protobuf:"varint,1,opt,name=keys,proto3" json:"keys,omitempty"`
+	Problems             []*FsckProblem `protobuf:"bytes,2,rep,name=problems,proto3" json:"problems,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *FsckReply) Reset()         { *m = FsckReply{} }
+func (m *FsckReply) String() string { return proto.CompactTextString(m) }
+func (*FsckReply) ProtoMessage()    {}
+func (*FsckReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_109d096f4b62305b, []int{20}
+}
+
+func (m *FsckReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FsckReply.Unmarshal(m, b)
+}
+func (m *FsckReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FsckReply.Marshal(b, m, deterministic)
+}
+func (m *FsckReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FsckReply.Merge(m, src)
+}
+func (m *FsckReply) XXX_Size() int {
+	return xxx_messageInfo_FsckReply.Size(m)
+}
+func (m *FsckReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_FsckReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FsckReply proto.InternalMessageInfo
+
+func (m *FsckReply) GetKeys() int32 {
+	if m != nil {
+		return m.Keys
+	}
+	return 0
+}
+
+func (m *FsckReply) GetProblems() []*FsckProblem {
+	if m != nil {
+		return m.Problems
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*ServiceInfo)(nil), "api.ServiceInfo")
+	proto.RegisterType((*RegisterServiceRequest)(nil), "api.RegisterServiceRequest")
+	proto.RegisterType((*AddAliasRequest)(nil), "api.AddAliasRequest")
+	proto.RegisterType((*RetireServiceRequest)(nil), "api.RetireServiceRequest")
+	proto.RegisterType((*GetServiceRequest)(nil), "api.GetServiceRequest")
+	proto.RegisterType((*ResolveRequest)(nil), "api.ResolveRequest")
+	proto.RegisterType((*ResolveReply)(nil), "api.ResolveReply")
+	proto.RegisterType((*ListRequest)(nil), "api.ListRequest")
+	proto.RegisterType((*ListServicesReply)(nil), "api.ListServicesReply")
+	proto.RegisterType((*ContainerInfo)(nil), "api.ContainerInfo")
+	proto.RegisterType((*ListContainersReply)(nil), "api.ListContainersReply")
+	proto.RegisterType((*Watermark)(nil), "api.Watermark")
+	proto.RegisterType((*ListWatermarksReply)(nil), "api.ListWatermarksReply")
+	proto.RegisterType((*Reassignment)(nil), "api.Reassignment")
+	proto.RegisterType((*ListReassignmentsReply)(nil), "api.ListReassignmentsReply")
+	proto.RegisterType((*LookupLedgerRequest)(nil), "api.LookupLedgerRequest")
+	proto.RegisterType((*LedgerEntry)(nil), "api.LedgerEntry")
+	proto.RegisterType((*LookupLedgerReply)(nil), "api.LookupLedgerReply")
+	proto.RegisterType((*FsckRequest)(nil), "api.FsckRequest")
+	proto.RegisterType((*FsckProblem)(nil), "api.FsckProblem")
+	proto.RegisterType((*FsckReply)(nil), "api.FsckReply")
+}
+
+func init() { proto.RegisterFile("api/admin.proto", fileDescriptor_109d096f4b62305b) }
+
+var fileDescriptor_109d096f4b62305b = []byte{
+	// 1051 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0x5b, 0x6f, 0x1b, 0x45,
+	0x14, 0xce, 0xfa, 0xd2, 0xd8, 0x67, 0x6d, 0x27, 0x9e, 0x44, 0xd6, 0xe2, 0x82, 0x48, 0x87, 0x8b,
+	0xac, 0x08, 0x05, 0x91, 0x22, 0x81, 0x78, 0x00, 0x39, 0x21, 0xad, 0x22, 0x85, 0xa8, 0x4c, 0xa8,
+	0xfa, 0x68, 0x4d, 0xbd, 0x43, 0x18, 0x79, 0x3d, 0xbb, 0xec, 0x8e, 0x9b, 0xf8, 0x91, 0x77, 0xfe,
+	0x02, 0x2f, 0xfc, 0x31, 0x7e, 0x09, 0x12, 0x9a, 0xcb, 0x5e, 0xbd, 0x34, 0xae, 0xd4, 0xb7, 0x9d,
+	0x6f, 0xce, 0x39, 0x73, 0x2e, 0xdf, 0x9c, 0x39, 0x0b, 0x7b, 0x34, 0xe2, 0x5f, 0x52, 0x7f, 0xc9,
+	0xc5, 0x49, 0x14, 0x87, 0x32, 0x44, 0x4d, 0x1a, 0x71, 0xfc, 0x87, 0x03, 0xee, 0x0d, 0x8b, 0xdf,
+	0xf0, 0x39, 0xbb, 0x14, 0xbf, 0x86, 0x08, 0x41, 0x4b, 0xd0, 0x25, 0xf3, 0x9c, 0x23, 0x67, 0xd2,
+	0x25, 0xfa, 0x1b, 0x7d, 0x04, 0x90, 0x18, 0x91, 0x19, 0xf7, 0xbd, 0xc6, 0x91, 0x33, 0x69, 0x93,
+	0xae, 0x45, 0x2e, 0x7d, 0xf4, 0x19, 0x0c, 0xe6, 0x54, 0x84, 0x82, 0xcf, 0x69, 0x30, 0xd3, 0xca,
+	0x4d, 0xad, 0xdc, 0xcf, 0xd0, 0x6b, 0x65, 0xc5, 0x83, 0xdd, 0x98, 0x49, 0x1e, 0x33, 0xdf, 0x6b,
+	0x1d, 0x39, 0x93, 0x0e, 0x49, 0x97, 0xf8, 0x1e, 0x46, 0x84, 0xdd, 0xf2, 0x44, 0xb2, 0xd8, 0xba,
+	0x42, 0xd8, 0xef, 0x2b, 0x96, 0x48, 0xf4, 0x04, 0x7a, 0xe9, 0xc9, 0x05, 0xaf, 0x5c, 0x8b, 0x5d,
+	0x6f, 0xe1, 0xdc, 0x87, 0xd0, 0x55, 0x9a, 0x49, 0x44, 0xe7, 0xa9, 0x5f, 0x39, 0x80, 0x7f, 0x83,
+	0xbd, 0xa9, 0xef, 0x4f, 0x03, 0x4e, 0x93, 0xf4, 0xc8, 0x43, 0x68, 0x53, 0xb5, 0xb6, 0x67, 0x99,
+	0xc5, 0x86, 0x23, 0x8d, 0x4d, 0x47, 0xde, 0x7e, 0xd2, 0x2b, 0x38, 0x24, 0x3a, 0xdc, 0x77, 0x8f,
+	0xb0, 0x64, 0xb8, 0x51, 0x35, 0xfc, 0x0b, 0x0c, 0x9f, 0x33, 0xf9, 0xbe, 0xad, 0x9e, 0xc1, 0x80,
+	0xb0, 0x24, 0x0c, 0xde, 0x64, 0x26, 0x11, 0xb4, 0x56, 0x2b, 0xee, 0x6b, 0x53, 0x4d, 0xa2, 0xbf,
+	0x1f, 0xb0, 0xf1, 0x67, 0x03, 0x7a, 0x99, 0x91, 0x28, 0x58, 0x57, 0x4a, 0xe5, 0x54, 0x4b, 0xf5,
+	0x04, 0x7a, 0xf3, 0x50, 0x48, 0xca, 0x05, 0x8b, 0xf3, 0x5a, 0xba, 0x19, 0x76, 0xe9, 0xa3, 0x8f,
+	0xc1, 0x4d, 0x94, 0x3f, 0xc2, 0x98, 0x68, 0x6a, 0x09, 0x48, 0x21, 0x63, 0xa3, 0x14, 0x78, 0x6b,
+	0x33, 0x70, 0x45, 0xd7, 0xec, 0x18, 0x2d, 0xd4, 0xb6, 0x74, 0x4d, 0x51, 0x2d, 0xf6, 0x15, 0x1c,
+	0xe6, 0x62, 0x31, 0xa3, 0x49, 0xc2, 0x6f, 0x05, 0xf3, 0xbd, 0x47, 0x9a, 0xbb, 0x07, 0xd9, 0x1e,
+	0xc9, 0xb6, 0x54, 0x3a, 0x68, 0x10, 0x84, 0x73, 0x2a, 0x99, 0xef, 0xed, 0x6a, 0xb9, 0x1c, 0xc0,
+	0x53, 0x70, 0xaf, 0x78, 0x22, 0xd3, 0x7c, 0x96, 0x72, 0xe7, 0x54, 0x72, 0xa7, 0x58, 0x18, 0xf0,
+	0x25, 0x97, 0x36, 0x09, 0x66, 0x81, 0xa7, 0x30, 0x54, 0x26, 0x6c, 0xb1, 0x13, 0x93, 0xd5, 0x2f,
+	0xa0, 0x63, 0xc3, 0x53, 0x9c, 0x6d, 0x4e, 0xdc, 0xd3, 0xfd, 0x13, 0x1a, 0xf1, 0x93, 0xc2, 0xad,
+	0x26, 0x99, 0x04, 0x7e, 0x06, 0xfd, 0xf3, 0x2c, 0xa1, 0xff, 0x77, 0xe1, 0x1f, 0xae, 0x04, 0xbe,
+	0x84, 0x03, 0xe5, 0x4a, 0x66, 0xcb, 0x3a, 0x73, 0x0a, 0x90, 0x49, 0xa5, 0xee, 0x20, 0xed, 0x4e,
+	0xe9, 0x54, 0x52, 0x90, 0xc2, 0xff, 0x38, 0xd0, 0x7d, 0x45, 0x25, 0x8b, 0x97, 0x34, 0x5e, 0xbc,
+	0x07, 0x92, 0x54, 0x39, 0xd0, 0xdc, 0x86, 0x03, 0xad, 0x3a, 0x0e, 0x7c, 0x02, 0x7d, 0xc1, 0xee,
+	0xe5, 0x2c, 0x25, 0x98, 0x66, 0x4a, 0x9b, 0xf4, 0x14, 0x78, 0x63, 0x31, 0x75, 0xdc, 0x2a, 0x61,
+	0xfe, 0x2c, 0x62, 0xf1, 0x9c, 0x09, 0xa9, 0x09, 0xe2, 0x10, 0x57, 0x61, 0x2f, 0x0c, 0x84, 0x2f,
+	0x4c, 0xb2, 0xb2, 0x20, 0x6d, 0xb2, 0x4e, 0x00, 0xee, 0x32, 0xc8, 0x26, 0x6b, 0xa0, 0x93, 0x95,
+	0x49, 0x92, 0x82, 0x04, 0xfe, 0xcb, 0x81, 0x5e, 0x4a, 0xb7, 0x25, 0x13, 0xb2, 0x26, 0x0c, 0xa7,
+	0x2e, 0x8c, 0x09, 0xec, 0x87, 0x81, 0x3f, 0xab, 0xc9, 0xdb, 0x20, 0x0c, 0xfc, 0xf3, 0x42, 0xea,
+	0x26, 0xb0, 0x2f, 0xd8, 0x5d, 0x59, 0xd2, 0x5c, 0xb2, 0x81, 0x60, 0x77, 0x45, 0x49, 0x04, 0x2d,
+	0xc9, 0x6d, 0xde, 0x9a, 0x44, 0x7f, 0xe3, 0x9f, 0x61, 0x64, 0x18, 0x9e, 0xbb, 0x68, 0x23, 0xfd,
+	0x06, 0xfa, 0x71, 0x11, 0xb5, 0xc1, 0x0e, 0x75, 0xb0, 0x45, 0x79, 0x52, 0x96, 0xc3, 0xcf, 0xe1,
+	0xe0, 0x2a, 0x0c, 0x17, 0xab, 0xe8, 0x8a, 0xf9, 0xb7, 0x2c, 0x4e, 0x2f, 0xcf, 0xbb, 0x37, 0xa3,
+	0x7f, 0x1b, 0xe0, 0x1a, 0x1b, 0x17, 0x42, 0xc6, 0xeb, 0x07, 0xae, 0xdf, 0x16, 0xed, 0xbe, 0xcc,
+	0xd3, 0x66, 0xdd, 0xa3, 0xb8, 0x05, 0xc3, 0xaa, 0x74, 0x6e, 0x6f, 0xd2, 0xf9, 0x18, 0x86, 0x85,
+	0x9e, 0x37, 0x4b, 0x24, 0x8d, 0x0d, 0xc9, 0xda, 0x64, 0x2f, 0xef, 0x7c, 0x37, 0x0a, 0x46, 0x9f,
+	0xc3, 0x5e, 0x51, 0x96, 0x09, 0xd3, 0x87, 0xda, 0xa4, 0x9f, 0x4b, 0x5e, 0x08, 0x5d, 0xbd, 0x88,
+	0xb1, 0xd8, 0xeb, 0x98, 0x4b, 0xaf, 0xbe, 0xd1, 0x18, 0x3a, 0xdc, 0x67, 0x42, 0x72, 0xb9, 0xf6,
+	0xba, 0x1a, 0xcf, 0xd6, 0x7a, 0x4f, 0x24, 0x92, 0xaa, 0x3b, 0x00, 0x76, 0xcf, 0xae, 0xd1, 0xa7,
+	0x30, 0x50, 0xd5, 0x9f, 0xad, 0x04, 0xbf, 0x9f, 0x09, 0x2a, 0x42, 0xcf, 0xd5, 0x55, 0xe9, 0x29,
+	0xf4, 0xa5, 0xe0, 0xf7, 0xd7, 0x54, 0x84, 0xf8, 0x07, 0x18, 0x96, 0x0b, 0xa9, 0x68, 0x71, 0x0c,
+	0xbb, 0x4c, 0xc8, 0x98, 0x57, 0x3a, 0x57, 0xa1, 0x4e, 0x24, 0x15, 0xc0, 0xe7, 0xe0, 0x3e, 0x4b,
+	0xe6, 0x8b, 0xed, 0xda, 0xe7, 0x08, 0x1e, 0xc5, 0x2c, 0xa2, 0x3c, 0xd6, 0x95, 0xeb, 0x10, 0xbb,
+	0xc2, 0x2f, 0x8d, 0x91, 0x17, 0x71, 0xf8, 0x3a, 0x60, 0x4b, 0xb4, 0x0f, 0xcd, 0x05, 0x5b, 0x5b,
+	0x75, 0xf5, 0xa9, 0x86, 0x94, 0xc8, 0x6c, 0xda, 0x9a, 0xa7, 0x4b, 0x95, 0x02, 0x63, 0x84, 0x99,
+	0x6a, 0x77, 0x48, 0xb6, 0xc6, 0x3f, 0x41, 0xd7, 0xf8, 0xa6, 0x82, 0x42, 0xd0, 0x5a, 0xb0, 0x75,
+	0x62, 0x5b, 0x97, 0xfe, 0x56, 0x3d, 0xda, 0xda, 0x49, 0xbc, 0x46, 0x21, 0xd2, 0x82, 0x33, 0x24,
+	0x93, 0x38, 0xfd, 0xbb, 0x0d, 0xed, 0xa9, 0x1a, 0xd4, 0xd0, 0x19, 0xec, 0x55, 0x26, 0x23, 0xf4,
+	0xd8, 0xde, 0x99, 0xba, 0x79, 0x69, 0xbc, 0xd1, 0xf9, 0xf1, 0x0e, 0xfa, 0x1a, 0x3a, 0xe9, 0x8c,
+	0x83, 0x0e, 0xf5, 0x7e, 0x65, 0xe4, 0xa9, 0xd5, 0xfa, 0x1e, 0xfa, 0xa5, 0x79, 0x05, 0x7d, 0x60,
+	0xcf, 0xdd, 0x9c, 0x61, 0x6a, 0xf5, 0xbf, 0x05, 0xc8, 0xc7, 0x12, 0x34, 0xd2, 0x12, 0x1b, 0x73,
+	0x4a, 0xad, 0xe6, 0x53, 0xd8, 0xb5, 0x53, 0x03, 0x3a, 0xb0, 0x67, 0x16, 0x07, 0x91, 0xf1, 0xb0,
+	0x0c, 0x46, 0xc1, 0x1a, 0xef, 0xa0, 0xef, 0xa0, 0x57, 0x7c, 0x19, 0x91, 0x25, 0x52, 0xfe, 0xde,
+	0x8e, 0x47, 0x19, 0x52, 0x7a, 0x3e, 0x75, 0xa8, 0x83, 0xf2, 0x53, 0x56, 0xa3, 0xed, 0x65, 0x48,
+	0xe5, 0xc5, 0xcb, 0xf5, 0xf3, 0xee, 0xfe, 0x56, 0xfd, 0xca, 0x23, 0x80, 0x77, 0xd0, 0x8f, 0xe6,
+	0x55, 0x2f, 0xb5, 0xcd, 0x1a, 0x13, 0x8f, 0x0b, 0x48, 0xb5, 0xc1, 0xe2, 0x1d, 0x74, 0x06, 0xbd,
+	0xe2, 0x05, 0x43, 0xf6, 0xc4, 0xcd, 0xe6, 0x39, 0x1e, 0xd5, 0xec, 0x18, 0x1b, 0xc7, 0xd0, 0x52,
+	0x8c, 0x44, 0x39, 0x39, 0x53, 0x9d, 0x41, 0x01, 0xd1, 0xb2, 0xaf, 0x1f, 0xe9, 0x9f, 0x88, 0xa7,
+	0xff, 0x0d, 0x00, 0xaa, 0x73, 0x7c, 0x11, 0x57, 0x0c, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type AdminClient interface {
+	RegisterService(ctx context.Context, in *RegisterServiceRequest, opts ...grpc.CallOption) (*ServiceInfo, error)
+	AddAlias(ctx context.Context, in *AddAliasRequest, opts ...grpc.CallOption) (*ServiceInfo, error)
+	RetireService(ctx context.Context, in *RetireServiceRequest, opts ...grpc.CallOption) (*ServiceInfo, error)
+	GetService(ctx context.Context, in *GetServiceRequest, opts ...grpc.CallOption) (*ServiceInfo, error)
+	Resolve(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveReply, error)
+	ListServices(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListServicesReply, error)
+	ListContainers(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListContainersReply, error)
+	ListWatermarks(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListWatermarksReply, error)
+	ListReassignments(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListReassignmentsReply, error)
+	LookupLedger(ctx context.Context, in *LookupLedgerRequest, opts ...grpc.CallOption) (*LookupLedgerReply, error)
+	Fsck(ctx context.Context, in *FsckRequest, opts ...grpc.CallOption) (*FsckReply, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) RegisterService(ctx context.Context, in *RegisterServiceRequest, opts ...grpc.CallOption) (*ServiceInfo, error) {
+	out := new(ServiceInfo)
+	err := c.cc.Invoke(ctx, "/api.Admin/RegisterService", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) AddAlias(ctx context.Context, in *AddAliasRequest, opts ...grpc.CallOption) (*ServiceInfo, error) {
+	out := new(ServiceInfo)
+	err := c.cc.Invoke(ctx, "/api.Admin/AddAlias", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RetireService(ctx context.Context, in *RetireServiceRequest, opts ...grpc.CallOption) (*ServiceInfo, error) {
+	out := new(ServiceInfo)
+	err := c.cc.Invoke(ctx, "/api.Admin/RetireService", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetService(ctx context.Context, in *GetServiceRequest, opts ...grpc.CallOption) (*ServiceInfo, error) {
+	out := new(ServiceInfo)
+	err := c.cc.Invoke(ctx, "/api.Admin/GetService", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Resolve(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveReply, error) {
+	out := new(ResolveReply)
+	err := c.cc.Invoke(ctx, "/api.Admin/Resolve", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListServices(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListServicesReply, error) {
+	out := new(ListServicesReply)
+	err := c.cc.Invoke(ctx, "/api.Admin/ListServices", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListContainers(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListContainersReply, error) {
+	out := new(ListContainersReply)
+	err := c.cc.Invoke(ctx, "/api.Admin/ListContainers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListWatermarks(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListWatermarksReply, error) {
+	out := new(ListWatermarksReply)
+	err := c.cc.Invoke(ctx, "/api.Admin/ListWatermarks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListReassignments(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListReassignmentsReply, error) {
+	out := new(ListReassignmentsReply)
+	err := c.cc.Invoke(ctx, "/api.Admin/ListReassignments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) LookupLedger(ctx context.Context, in *LookupLedgerRequest, opts ...grpc.CallOption) (*LookupLedgerReply, error) {
+	out := new(LookupLedgerReply)
+	err := c.cc.Invoke(ctx, "/api.Admin/LookupLedger", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Fsck(ctx context.Context, in *FsckRequest, opts ...grpc.CallOption) (*FsckReply, error) {
+	out := new(FsckReply)
+	err := c.cc.Invoke(ctx, "/api.Admin/Fsck", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+type AdminServer interface {
+	RegisterService(context.Context, *RegisterServiceRequest) (*ServiceInfo, error)
+	AddAlias(context.Context, *AddAliasRequest) (*ServiceInfo, error)
+	RetireService(context.Context, *RetireServiceRequest) (*ServiceInfo, error)
+	GetService(context.Context, *GetServiceRequest) (*ServiceInfo, error)
+	Resolve(context.Context, *ResolveRequest) (*ResolveReply, error)
+	ListServices(context.Context, *ListRequest) (*ListServicesReply, error)
+	ListContainers(context.Context, *ListRequest) (*ListContainersReply, error)
+	ListWatermarks(context.Context, *ListRequest) (*ListWatermarksReply, error)
+	ListReassignments(context.Context, *ListRequest) (*ListReassignmentsReply, error)
+	LookupLedger(context.Context, *LookupLedgerRequest) (*LookupLedgerReply, error)
+	Fsck(context.Context, *FsckRequest) (*FsckReply, error)
+}
+
+// UnimplementedAdminServer can be embedded to have forward compatible implementations.
+type UnimplementedAdminServer struct {
+}
+
+func (*UnimplementedAdminServer) RegisterService(ctx context.Context, req *RegisterServiceRequest) (*ServiceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterService not implemented")
+}
+func (*UnimplementedAdminServer) AddAlias(ctx context.Context, req *AddAliasRequest) (*ServiceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddAlias not implemented")
+}
+func (*UnimplementedAdminServer) RetireService(ctx context.Context, req *RetireServiceRequest) (*ServiceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetireService not implemented")
+}
+func (*UnimplementedAdminServer) GetService(ctx context.Context, req *GetServiceRequest) (*ServiceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetService not implemented")
+}
+func (*UnimplementedAdminServer) Resolve(ctx context.Context, req *ResolveRequest) (*ResolveReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resolve not implemented")
+}
+func (*UnimplementedAdminServer) ListServices(ctx context.Context, req *ListRequest) (*ListServicesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListServices not implemented")
+}
+func (*UnimplementedAdminServer) ListContainers(ctx context.Context, req *ListRequest) (*ListContainersReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListContainers not implemented")
+}
+func (*UnimplementedAdminServer) ListWatermarks(ctx context.Context, req *ListRequest) (*ListWatermarksReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWatermarks not implemented")
+}
+func (*UnimplementedAdminServer) ListReassignments(ctx context.Context, req *ListRequest) (*ListReassignmentsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListReassignments not implemented")
+}
+func (*UnimplementedAdminServer) LookupLedger(ctx context.Context, req *LookupLedgerRequest) (*LookupLedgerReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupLedger not implemented")
+}
+func (*UnimplementedAdminServer) Fsck(ctx context.Context, req *FsckRequest) (*FsckReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Fsck not implemented")
+}
+
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&_Admin_serviceDesc, srv)
+}
+
+func _Admin_RegisterService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RegisterService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/RegisterService",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RegisterService(ctx, req.(*RegisterServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_AddAlias_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddAliasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).AddAlias(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/AddAlias",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).AddAlias(ctx, req.(*AddAliasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RetireService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetireServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RetireService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/RetireService",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RetireService(ctx, req.(*RetireServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/GetService",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetService(ctx, req.(*GetServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Resolve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Resolve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/Resolve",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Resolve(ctx, req.(*ResolveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/ListServices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListServices(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListContainers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListContainers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/ListContainers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListContainers(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListWatermarks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListWatermarks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/ListWatermarks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListWatermarks(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListReassignments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListReassignments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/ListReassignments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListReassignments(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_LookupLedger_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupLedgerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).LookupLedger(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/LookupLedger",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).LookupLedger(ctx, req.(*LookupLedgerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Fsck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FsckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Fsck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Admin/Fsck",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Fsck(ctx, req.(*FsckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Admin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "api.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RegisterService",
+			Handler:    _Admin_RegisterService_Handler,
+		},
+		{
+			MethodName: "AddAlias",
+			Handler:    _Admin_AddAlias_Handler,
+		},
+		{
+			MethodName: "RetireService",
+			Handler:    _Admin_RetireService_Handler,
+		},
+		{
+			MethodName: "GetService",
+			Handler:    _Admin_GetService_Handler,
+		},
+		{
+			MethodName: "Resolve",
+			Handler:    _Admin_Resolve_Handler,
+		},
+		{
+			MethodName: "ListServices",
+			Handler:    _Admin_ListServices_Handler,
+		},
+		{
+			MethodName: "ListContainers",
+			Handler:    _Admin_ListContainers_Handler,
+		},
+		{
+			MethodName: "ListWatermarks",
+			Handler:    _Admin_ListWatermarks_Handler,
+		},
+		{
+			MethodName: "ListReassignments",
+			Handler:    _Admin_ListReassignments_Handler,
+		},
+		{
+			MethodName: "LookupLedger",
+			Handler:    _Admin_LookupLedger_Handler,
+		},
+		{
+			MethodName: "Fsck",
+			Handler:    _Admin_Fsck_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/admin.proto",
+}
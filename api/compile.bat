@@ -1 +1 @@
-protoc --go_out=plugins=grpc:. api\uuid.proto
\ No newline at end of file
+protoc --go_out=plugins=grpc:. api\uuid.proto api\sequence.proto api\admin.proto
\ No newline at end of file
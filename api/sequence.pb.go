@@ -0,0 +1,510 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: api/sequence.proto
+
+package api
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type ReserveRequest struct {
+	ServiceName          string   `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	TtlSeconds           int32    `protobuf:"varint,2,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	Namespace            string   `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReserveRequest) Reset()         { *m = ReserveRequest{} }
+func (m *ReserveRequest) String() string { return proto.CompactTextString(m) }
+func (*ReserveRequest) ProtoMessage()    {}
+func (*ReserveRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a160de10ccac3d0f, []int{0}
+}
+
+func (m *ReserveRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReserveRequest.Unmarshal(m, b)
+}
+func (m *ReserveRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReserveRequest.Marshal(b, m, deterministic)
+}
+func (m *ReserveRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReserveRequest.Merge(m, src)
+}
+func (m *ReserveRequest) XXX_Size() int {
+	return xxx_messageInfo_ReserveRequest.Size(m)
+}
+func (m *ReserveRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReserveRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReserveRequest proto.InternalMessageInfo
+
+func (m *ReserveRequest) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *ReserveRequest) GetTtlSeconds() int32 {
+	if m != nil {
+		return m.TtlSeconds
+	}
+	return 0
+}
+
+func (m *ReserveRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type ReserveReply struct {
+	Number               int64    `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	Token                string   `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	ExpireTime           int64    `protobuf:"varint,3,opt,name=expire_time,json=expireTime,proto3" json:"expire_time,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReserveReply) Reset()         { *m = ReserveReply{} }
+func (m *ReserveReply) String() string { return proto.CompactTextString(m) }
+func (*ReserveReply) ProtoMessage()    {}
+func (*ReserveReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a160de10ccac3d0f, []int{1}
+}
+
+func (m *ReserveReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReserveReply.Unmarshal(m, b)
+}
+func (m *ReserveReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReserveReply.Marshal(b, m, deterministic)
+}
+func (m *ReserveReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReserveReply.Merge(m, src)
+}
+func (m *ReserveReply) XXX_Size() int {
+	return xxx_messageInfo_ReserveReply.Size(m)
+}
+func (m *ReserveReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReserveReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReserveReply proto.InternalMessageInfo
+
+func (m *ReserveReply) GetNumber() int64 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *ReserveReply) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *ReserveReply) GetExpireTime() int64 {
+	if m != nil {
+		return m.ExpireTime
+	}
+	return 0
+}
+
+type CommitRequest struct {
+	ServiceName          string   `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Number               int64    `protobuf:"varint,2,opt,name=number,proto3" json:"number,omitempty"`
+	Token                string   `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"`
+	Namespace            string   `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommitRequest) Reset()         { *m = CommitRequest{} }
+func (m *CommitRequest) String() string { return proto.CompactTextString(m) }
+func (*CommitRequest) ProtoMessage()    {}
+func (*CommitRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a160de10ccac3d0f, []int{2}
+}
+
+func (m *CommitRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CommitRequest.Unmarshal(m, b)
+}
+func (m *CommitRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CommitRequest.Marshal(b, m, deterministic)
+}
+func (m *CommitRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommitRequest.Merge(m, src)
+}
+func (m *CommitRequest) XXX_Size() int {
+	return xxx_messageInfo_CommitRequest.Size(m)
+}
+func (m *CommitRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommitRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommitRequest proto.InternalMessageInfo
+
+func (m *CommitRequest) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *CommitRequest) GetNumber() int64 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *CommitRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *CommitRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type CommitReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommitReply) Reset()         { *m = CommitReply{} }
+func (m *CommitReply) String() string { return proto.CompactTextString(m) }
+func (*CommitReply) ProtoMessage()    {}
+func (*CommitReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a160de10ccac3d0f, []int{3}
+}
+
+func (m *CommitReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CommitReply.Unmarshal(m, b)
+}
+func (m *CommitReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CommitReply.Marshal(b, m, deterministic)
+}
+func (m *CommitReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommitReply.Merge(m, src)
+}
+func (m *CommitReply) XXX_Size() int {
+	return xxx_messageInfo_CommitReply.Size(m)
+}
+func (m *CommitReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommitReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommitReply proto.InternalMessageInfo
+
+type AbortRequest struct {
+	ServiceName          string   `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Number               int64    `protobuf:"varint,2,opt,name=number,proto3" json:"number,omitempty"`
+	Token                string   `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"`
+	Namespace            string   `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AbortRequest) Reset()         { *m = AbortRequest{} }
+func (m *AbortRequest) String() string { return proto.CompactTextString(m) }
+func (*AbortRequest) ProtoMessage()    {}
+func (*AbortRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a160de10ccac3d0f, []int{4}
+}
+
+func (m *AbortRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AbortRequest.Unmarshal(m, b)
+}
+func (m *AbortRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AbortRequest.Marshal(b, m, deterministic)
+}
+func (m *AbortRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AbortRequest.Merge(m, src)
+}
+func (m *AbortRequest) XXX_Size() int {
+	return xxx_messageInfo_AbortRequest.Size(m)
+}
+func (m *AbortRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AbortRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AbortRequest proto.InternalMessageInfo
+
+func (m *AbortRequest) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *AbortRequest) GetNumber() int64 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *AbortRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *AbortRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type AbortReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AbortReply) Reset()         { *m = AbortReply{} }
+func (m *AbortReply) String() string { return proto.CompactTextString(m) }
+func (*AbortReply) ProtoMessage()    {}
+func (*AbortReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a160de10ccac3d0f, []int{5}
+}
+
+func (m *AbortReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AbortReply.Unmarshal(m, b)
+}
+func (m *AbortReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AbortReply.Marshal(b, m, deterministic)
+}
+func (m *AbortReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AbortReply.Merge(m, src)
+}
+func (m *AbortReply) XXX_Size() int {
+	return xxx_messageInfo_AbortReply.Size(m)
+}
+func (m *AbortReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_AbortReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AbortReply proto.InternalMessageInfo
+
+func init() {
+	proto.RegisterType((*ReserveRequest)(nil), "api.ReserveRequest")
+	proto.RegisterType((*ReserveReply)(nil), "api.ReserveReply")
+	proto.RegisterType((*CommitRequest)(nil), "api.CommitRequest")
+	proto.RegisterType((*CommitReply)(nil), "api.CommitReply")
+	proto.RegisterType((*AbortRequest)(nil), "api.AbortRequest")
+	proto.RegisterType((*AbortReply)(nil), "api.AbortReply")
+}
+
+func init() { proto.RegisterFile("api/sequence.proto", fileDescriptor_a160de10ccac3d0f) }
+
+var fileDescriptor_a160de10ccac3d0f = []byte{
+	// 309 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x92, 0xbf, 0x4e, 0xc3, 0x30,
+	0x10, 0xc6, 0x49, 0x43, 0x0b, 0xbd, 0xa6, 0xfc, 0x39, 0x10, 0xaa, 0x2a, 0x24, 0x4a, 0xa6, 0x2e,
+	0x14, 0x44, 0x9f, 0x00, 0xb1, 0x33, 0xb8, 0xac, 0xa8, 0x72, 0xc3, 0x0d, 0x16, 0x71, 0x6c, 0x6c,
+	0x17, 0x91, 0x01, 0x9e, 0x85, 0x47, 0x45, 0xb1, 0x03, 0x4d, 0x10, 0x0b, 0x13, 0xe3, 0xfd, 0x9c,
+	0xbb, 0xef, 0xfb, 0x2e, 0x07, 0xc8, 0xb5, 0xb8, 0xb4, 0xf4, 0xbc, 0xa6, 0x22, 0xa3, 0x99, 0x36,
+	0xca, 0x29, 0x8c, 0xb9, 0x16, 0xa9, 0x81, 0x3d, 0x46, 0x96, 0xcc, 0x0b, 0xb1, 0xea, 0xd5, 0x3a,
+	0x3c, 0x87, 0xa4, 0xaa, 0x45, 0x46, 0xcb, 0x82, 0x4b, 0x1a, 0x45, 0x93, 0x68, 0xda, 0x67, 0x83,
+	0x9a, 0xdd, 0x71, 0x49, 0x78, 0x06, 0x03, 0xe7, 0xf2, 0xa5, 0xa5, 0x4c, 0x15, 0x8f, 0x76, 0xd4,
+	0x99, 0x44, 0xd3, 0x2e, 0x03, 0xe7, 0xf2, 0x45, 0x20, 0x78, 0x0a, 0xfd, 0xaa, 0xd7, 0x6a, 0x9e,
+	0xd1, 0x28, 0xf6, 0x03, 0x36, 0x20, 0x7d, 0x80, 0xe4, 0x5b, 0x53, 0xe7, 0x25, 0x9e, 0x40, 0xaf,
+	0x58, 0xcb, 0x15, 0x19, 0xaf, 0x15, 0xb3, 0xba, 0xc2, 0x63, 0xe8, 0x3a, 0xf5, 0x44, 0x85, 0x17,
+	0xe8, 0xb3, 0x50, 0x54, 0xe2, 0xf4, 0xaa, 0x85, 0xa1, 0xa5, 0x13, 0x32, 0x4c, 0x8f, 0x19, 0x04,
+	0x74, 0x2f, 0x24, 0xa5, 0xef, 0x30, 0xbc, 0x55, 0x52, 0x0a, 0xf7, 0x87, 0x44, 0x1b, 0x0b, 0x9d,
+	0xdf, 0x2d, 0xc4, 0x4d, 0x0b, 0xad, 0x78, 0xdb, 0x3f, 0xe3, 0x0d, 0x61, 0xf0, 0xa5, 0xaf, 0xf3,
+	0x32, 0x7d, 0x83, 0xe4, 0x66, 0xa5, 0xcc, 0x7f, 0xb9, 0x49, 0x00, 0x6a, 0x79, 0x9d, 0x97, 0xd7,
+	0x1f, 0x11, 0xec, 0x2e, 0xea, 0x33, 0xc0, 0x39, 0xec, 0xd4, 0xff, 0x01, 0x8f, 0x66, 0x5c, 0x8b,
+	0x59, 0xfb, 0x12, 0xc6, 0x87, 0x6d, 0x58, 0x85, 0xd9, 0xc2, 0x2b, 0xe8, 0x85, 0x74, 0x88, 0xfe,
+	0xb9, 0xb5, 0xea, 0xf1, 0x41, 0x8b, 0x85, 0x8e, 0x0b, 0xe8, 0x7a, 0x07, 0x18, 0xe6, 0x35, 0x97,
+	0x31, 0xde, 0x6f, 0x22, 0xff, 0xf9, 0xaa, 0xe7, 0xaf, 0x73, 0xfe, 0x39, 0x00, 0x47, 0xe2, 0x27,
+	0xce, 0xb3, 0x02, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// SequenceClient is the client API for Sequence service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type SequenceClient interface {
+	Reserve(ctx context.Context, in *ReserveRequest, opts ...grpc.CallOption) (*ReserveReply, error)
+	Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitReply, error)
+	Abort(ctx context.Context, in *AbortRequest, opts ...grpc.CallOption) (*AbortReply, error)
+}
+
+type sequenceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSequenceClient(cc grpc.ClientConnInterface) SequenceClient {
+	return &sequenceClient{cc}
+}
+
+func (c *sequenceClient) Reserve(ctx context.Context, in *ReserveRequest, opts ...grpc.CallOption) (*ReserveReply, error) {
+	out := new(ReserveReply)
+	err := c.cc.Invoke(ctx, "/api.Sequence/Reserve", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sequenceClient) Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitReply, error) {
+	out := new(CommitReply)
+	err := c.cc.Invoke(ctx, "/api.Sequence/Commit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sequenceClient) Abort(ctx context.Context, in *AbortRequest, opts ...grpc.CallOption) (*AbortReply, error) {
+	out := new(AbortReply)
+	err := c.cc.Invoke(ctx, "/api.Sequence/Abort", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SequenceServer is the server API for Sequence service.
+type SequenceServer interface {
+	Reserve(context.Context, *ReserveRequest) (*ReserveReply, error)
+	Commit(context.Context, *CommitRequest) (*CommitReply, error)
+	Abort(context.Context, *AbortRequest) (*AbortReply, error)
+}
+
+// UnimplementedSequenceServer can be embedded to have forward compatible implementations.
+type UnimplementedSequenceServer struct {
+}
+
+func (*UnimplementedSequenceServer) Reserve(ctx context.Context, req *ReserveRequest) (*ReserveReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reserve not implemented")
+}
+func (*UnimplementedSequenceServer) Commit(ctx context.Context, req *CommitRequest) (*CommitReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Commit not implemented")
+}
+func (*UnimplementedSequenceServer) Abort(ctx context.Context, req *AbortRequest) (*AbortReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Abort not implemented")
+}
+
+func RegisterSequenceServer(s *grpc.Server, srv SequenceServer) {
+	s.RegisterService(&_Sequence_serviceDesc, srv)
+}
+
+func _Sequence_Reserve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SequenceServer).Reserve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Sequence/Reserve",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SequenceServer).Reserve(ctx, req.(*ReserveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sequence_Commit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SequenceServer).Commit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Sequence/Commit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SequenceServer).Commit(ctx, req.(*CommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sequence_Abort_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AbortRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SequenceServer).Abort(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Sequence/Abort",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SequenceServer).Abort(ctx, req.(*AbortRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Sequence_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "api.Sequence",
+	HandlerType: (*SequenceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Reserve",
+			Handler:    _Sequence_Reserve_Handler,
+		},
+		{
+			MethodName: "Commit",
+			Handler:    _Sequence_Commit_Handler,
+		},
+		{
+			MethodName: "Abort",
+			Handler:    _Sequence_Abort_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/sequence.proto",
+}
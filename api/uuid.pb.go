@@ -28,6 +28,7 @@ type FetchRequest struct {
 	ServiceName          string   `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
 	ContainerName        string   `protobuf:"bytes,2,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
 	NeedCount            int32    `protobuf:"varint,3,opt,name=need_count,json=needCount,proto3" json:"need_count,omitempty"`
+	Namespace            string   `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -79,6 +80,13 @@ func (m *FetchRequest) GetNeedCount() int32 {
 	return 0
 }
 
+func (m *FetchRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
 type UUIDRange struct {
 	ServiceId            int32    `protobuf:"varint,1,opt,name=service_id,json=serviceId,proto3" json:"service_id,omitempty"`
 	ContainerId          int32    `protobuf:"varint,2,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
@@ -142,8 +150,64 @@ func (m *UUIDRange) GetSequenceIdEnd() int32 {
 	return 0
 }
 
+type Layout struct {
+	ServiceBits          int32    `protobuf:"varint,1,opt,name=service_bits,json=serviceBits,proto3" json:"service_bits,omitempty"`
+	ContainerBits        int32    `protobuf:"varint,2,opt,name=container_bits,json=containerBits,proto3" json:"container_bits,omitempty"`
+	SequenceBits         int32    `protobuf:"varint,3,opt,name=sequence_bits,json=sequenceBits,proto3" json:"sequence_bits,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Layout) Reset()         { *m = Layout{} }
+func (m *Layout) String() string { return proto.CompactTextString(m) }
+func (*Layout) ProtoMessage()    {}
+func (*Layout) Descriptor() ([]byte, []int) {
+	return fileDescriptor_61fc83c022ba86aa, []int{2}
+}
+
+func (m *Layout) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Layout.Unmarshal(m, b)
+}
+func (m *Layout) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Layout.Marshal(b, m, deterministic)
+}
+func (m *Layout) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Layout.Merge(m, src)
+}
+func (m *Layout) XXX_Size() int {
+	return xxx_messageInfo_Layout.Size(m)
+}
+func (m *Layout) XXX_DiscardUnknown() {
+	xxx_messageInfo_Layout.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Layout proto.InternalMessageInfo
+
+func (m *Layout) GetServiceBits() int32 {
+	if m != nil {
+		return m.ServiceBits
+	}
+	return 0
+}
+
+func (m *Layout) GetContainerBits() int32 {
+	if m != nil {
+		return m.ContainerBits
+	}
+	return 0
+}
+
+func (m *Layout) GetSequenceBits() int32 {
+	if m != nil {
+		return m.SequenceBits
+	}
+	return 0
+}
+
 type FetchReply struct {
 	Items                []*UUIDRange `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	Layout               *Layout      `protobuf:"bytes,2,opt,name=layout,proto3" json:"layout,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
 	XXX_unrecognized     []byte       `json:"-"`
 	XXX_sizecache        int32        `json:"-"`
@@ -153,7 +217,7 @@ func (m *FetchReply) Reset()         { *m = FetchReply{} }
 func (m *FetchReply) String() string { return proto.CompactTextString(m) }
 func (*FetchReply) ProtoMessage()    {}
 func (*FetchReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_61fc83c022ba86aa, []int{2}
+	return fileDescriptor_61fc83c022ba86aa, []int{3}
 }
 
 func (m *FetchReply) XXX_Unmarshal(b []byte) error {
@@ -181,34 +245,46 @@ func (m *FetchReply) GetItems() []*UUIDRange {
 	return nil
 }
 
+func (m *FetchReply) GetLayout() *Layout {
+	if m != nil {
+		return m.Layout
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*FetchRequest)(nil), "api.FetchRequest")
 	proto.RegisterType((*UUIDRange)(nil), "api.UUIDRange")
+	proto.RegisterType((*Layout)(nil), "api.Layout")
 	proto.RegisterType((*FetchReply)(nil), "api.FetchReply")
 }
 
 func init() { proto.RegisterFile("api/uuid.proto", fileDescriptor_61fc83c022ba86aa) }
 
 var fileDescriptor_61fc83c022ba86aa = []byte{
-	// 280 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x91, 0xdf, 0x4a, 0x84, 0x40,
-	0x14, 0xc6, 0xb3, 0x5d, 0x03, 0x8f, 0xbb, 0xca, 0xce, 0x95, 0x04, 0x81, 0x49, 0x85, 0x04, 0x19,
-	0x18, 0x3d, 0x41, 0x7f, 0xc0, 0x9b, 0x2e, 0x26, 0xf6, 0x5a, 0x26, 0xe7, 0x50, 0x03, 0xeb, 0x38,
-	0xe9, 0x58, 0xec, 0xe3, 0xf4, 0xa6, 0x31, 0xb3, 0xea, 0x7a, 0xfb, 0x3b, 0xbf, 0x99, 0x6f, 0xce,
-	0x37, 0x10, 0x30, 0x25, 0xee, 0xfb, 0x5e, 0xf0, 0x4c, 0xb5, 0x8d, 0x6e, 0xc8, 0x82, 0x29, 0x91,
-	0xfc, 0xc2, 0xea, 0x15, 0x75, 0xf5, 0x45, 0xf1, 0xbb, 0xc7, 0x4e, 0x93, 0x4b, 0x58, 0x75, 0xd8,
-	0xfe, 0x88, 0x0a, 0x4b, 0xc9, 0x6a, 0x8c, 0x9c, 0xd8, 0x49, 0x3d, 0xea, 0x0f, 0xec, 0x8d, 0xd5,
-	0x48, 0xae, 0x21, 0xa8, 0x1a, 0xa9, 0x99, 0x90, 0xd8, 0x1e, 0xa4, 0x53, 0x2b, 0xad, 0x27, 0x6a,
-	0xb5, 0x0b, 0x00, 0x89, 0xc8, 0xcb, 0xaa, 0xe9, 0xa5, 0x8e, 0x16, 0xb1, 0x93, 0xba, 0xd4, 0x33,
-	0xe4, 0xc9, 0x80, 0xe4, 0xcf, 0x01, 0x6f, 0xbb, 0x2d, 0x9e, 0x29, 0x93, 0x9f, 0x56, 0x1e, 0x63,
-	0x05, 0xb7, 0xa1, 0x2e, 0xf5, 0x06, 0x52, 0x70, 0xf3, 0xaa, 0x63, 0xa4, 0xe0, 0x36, 0xd0, 0xa5,
-	0xfe, 0xc4, 0x0a, 0x4e, 0x6e, 0x61, 0xd3, 0x99, 0x1d, 0xa4, 0xbd, 0xa2, 0xec, 0x34, 0x6b, 0xc7,
-	0xd4, 0x70, 0x1c, 0x14, 0xfc, 0xdd, 0x60, 0x72, 0x03, 0xe1, 0xdc, 0x45, 0xc9, 0xa3, 0xa5, 0x35,
-	0xd7, 0x47, 0xf3, 0x45, 0xf2, 0x24, 0x07, 0x18, 0xca, 0x51, 0xbb, 0x3d, 0xb9, 0x02, 0x57, 0x68,
-	0xac, 0xbb, 0xc8, 0x89, 0x17, 0xa9, 0x9f, 0x07, 0x19, 0x53, 0x22, 0x9b, 0x56, 0xa0, 0x87, 0x61,
-	0xfe, 0x08, 0x4b, 0xc3, 0xc8, 0x1d, 0xb8, 0xf6, 0x2c, 0xd9, 0x58, 0x6f, 0x5e, 0xf2, 0x79, 0x38,
-	0x47, 0x6a, 0xb7, 0x4f, 0x4e, 0x3e, 0xce, 0xec, 0x9f, 0x3c, 0xfc, 0x07, 0x00, 0x00, 0xff, 0xff,
-	0x3f, 0xc9, 0xdb, 0x7b, 0xa5, 0x01, 0x00, 0x00,
+	// 351 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x92, 0xdd, 0x4a, 0xf3, 0x30,
+	0x18, 0xc7, 0xdf, 0xbe, 0x5b, 0x07, 0x7d, 0xba, 0x0f, 0x96, 0xa3, 0x21, 0x0a, 0xb3, 0x53, 0x19,
+	0x82, 0x13, 0x26, 0xde, 0x80, 0x5f, 0x50, 0x10, 0x0f, 0x22, 0xc3, 0xc3, 0x91, 0x35, 0x41, 0x03,
+	0x5b, 0x1a, 0x9b, 0x54, 0xd9, 0x85, 0x78, 0x01, 0xde, 0xa9, 0xe4, 0xe9, 0xe7, 0x4e, 0xff, 0xf9,
+	0xe5, 0x79, 0x7e, 0xfd, 0x37, 0x30, 0x64, 0x5a, 0x5e, 0xe7, 0xb9, 0xe4, 0x0b, 0x9d, 0xa5, 0x36,
+	0x25, 0x1d, 0xa6, 0x65, 0xf4, 0xe3, 0x41, 0xff, 0x49, 0xd8, 0xe4, 0x83, 0x8a, 0xcf, 0x5c, 0x18,
+	0x4b, 0x4e, 0xa1, 0x6f, 0x44, 0xf6, 0x25, 0x13, 0xb1, 0x56, 0x6c, 0x27, 0x26, 0xde, 0xd4, 0x9b,
+	0x07, 0x34, 0x2c, 0xb3, 0x17, 0xb6, 0x13, 0xe4, 0x1c, 0x86, 0x49, 0xaa, 0x2c, 0x93, 0x4a, 0x64,
+	0x05, 0xf4, 0x1f, 0xa1, 0x41, 0x9d, 0x22, 0x76, 0x02, 0xa0, 0x84, 0xe0, 0xeb, 0x24, 0xcd, 0x95,
+	0x9d, 0x74, 0xa6, 0xde, 0xdc, 0xa7, 0x81, 0x4b, 0xee, 0x5d, 0x40, 0x8e, 0x21, 0x70, 0x77, 0x8d,
+	0x66, 0x89, 0x98, 0x74, 0x71, 0x40, 0x13, 0x44, 0xbf, 0x1e, 0x04, 0xab, 0x55, 0xfc, 0x40, 0x99,
+	0x7a, 0xc7, 0x51, 0x95, 0x94, 0xe4, 0xa8, 0xe4, 0xd3, 0xa0, 0x4c, 0x62, 0xee, 0x9c, 0x1b, 0x21,
+	0xc9, 0x51, 0xc7, 0xa7, 0x61, 0x9d, 0xc5, 0x9c, 0x5c, 0xc2, 0xd8, 0xb8, 0x2f, 0x54, 0x38, 0x62,
+	0x6d, 0x2c, 0xcb, 0x2a, 0xa7, 0x51, 0x75, 0x10, 0xf3, 0x57, 0x17, 0x93, 0x0b, 0x18, 0xb5, 0x59,
+	0xa1, 0x38, 0xfa, 0xf9, 0x74, 0xd0, 0x90, 0x8f, 0x8a, 0x47, 0xdf, 0xd0, 0x7b, 0x66, 0xfb, 0x34,
+	0x3f, 0x28, 0x6d, 0x23, 0xad, 0x29, 0x0d, 0xab, 0xd2, 0xee, 0xa4, 0x35, 0x87, 0xa5, 0x21, 0x54,
+	0x58, 0x36, 0xa5, 0x21, 0x36, 0x83, 0x7a, 0x49, 0x41, 0x15, 0x8e, 0xfd, 0x2a, 0x74, 0x50, 0xf4,
+	0x06, 0x50, 0xfe, 0x33, 0xbd, 0xdd, 0x93, 0x33, 0xf0, 0xa5, 0x15, 0x3b, 0xb7, 0xb5, 0x33, 0x0f,
+	0x97, 0xc3, 0x05, 0xd3, 0x72, 0x51, 0x77, 0x47, 0x8b, 0x43, 0x32, 0x83, 0xde, 0x16, 0x65, 0x71,
+	0x6f, 0xb8, 0x0c, 0x11, 0x2b, 0xfc, 0x69, 0x79, 0xb4, 0xbc, 0x85, 0xae, 0xbb, 0x48, 0xae, 0xc0,
+	0xc7, 0x05, 0x64, 0x8c, 0x54, 0xfb, 0x81, 0x1c, 0x8d, 0xda, 0x91, 0xde, 0xee, 0xa3, 0x7f, 0x9b,
+	0x1e, 0x3e, 0xa8, 0x9b, 0xbf, 0x01, 0x00, 0xd6, 0x97, 0x53, 0x31, 0x62, 0x02, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
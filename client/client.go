@@ -7,7 +7,14 @@ import (
 	"github.com/cnwinds/flake/api"
 	"github.com/cnwinds/flake/util"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 )
 
 // Config the config used to create client.
@@ -15,6 +22,42 @@ type Config struct {
 	Endpoint   string
 	IsPrefetch bool
 	NeedCount  int
+	// Namespace the namespace on the server, "" is the default namespace.
+	Namespace string
+
+	// TLS connect with TLS, it is implied by any of the TLS files.
+	TLS bool
+	// TLSCAFile the CA that signs the server certificate, the system roots are used if it is empty.
+	TLSCAFile string
+	// TLSCertFile the client certificate for servers that require one.
+	TLSCertFile string
+	// TLSKeyFile the private key of TLSCertFile.
+	TLSKeyFile string
+	// TLSServerName overrides the server name checked in the server certificate.
+	TLSServerName string
+
+	// Token the bearer token sent with each request, a static token or a JWT.
+	Token string
+
+	// TracerProvider traces the fetches from the server, the global provider is used if it is nil.
+	TracerProvider trace.TracerProvider
+}
+
+// tracerName the instrumentation name of the flake client spans.
+const tracerName = "github.com/cnwinds/flake/client"
+
+// tokenCredentials sends a bearer token as per-RPC credentials.
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
 }
 
 type uuidNode struct {
@@ -25,6 +68,7 @@ type uuidNode struct {
 	needCount   int
 	leftCount   int
 	serviceName string
+	layout      util.Layout
 }
 
 // Client client.
@@ -33,15 +77,29 @@ type Client struct {
 	conn *grpc.ClientConn
 	api  api.UUIDClient
 
+	tracer trace.Tracer
+
 	containerName string
 
 	storeLock sync.Mutex
 	store     map[string]*uuidNode
 }
 
-func (c *Client) fetch(serviceName string, containerName string, needCount int) (*api.FetchReply, error) {
-	resp, err := c.api.Fetch(context.Background(), &api.FetchRequest{ServiceName: serviceName, ContainerName: containerName,
-		NeedCount: int32(needCount)})
+func (c *Client) fetch(ctx context.Context, serviceName string, containerName string, needCount int) (*api.FetchReply, error) {
+	ctx, span := c.tracer.Start(ctx, "api.UUID/Fetch", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("flake.service", serviceName),
+			attribute.Int("flake.need_count", needCount)))
+	defer span.End()
+
+	md := metadata.MD{}
+	propagation.TraceContext{}.Inject(ctx, util.MetadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	resp, err := c.api.Fetch(ctx, &api.FetchRequest{ServiceName: serviceName, ContainerName: containerName,
+		NeedCount: int32(needCount), Namespace: c.cfg.Namespace})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
 	return resp, err
 }
 
@@ -63,6 +121,11 @@ func (c *Client) SetNeedCount(serviceName string, needCount int) {
 // GenUUID generate a UUID.
 // Support for multi-threaded parallel calls.
 func (c *Client) GenUUID(serviceName string) (uuid int64, err error) {
+	return c.GenUUIDContext(context.Background(), serviceName)
+}
+
+// GenUUIDContext generate a UUID, a fetch from the server is traced as part of the trace in ctx.
+func (c *Client) GenUUIDContext(ctx context.Context, serviceName string) (uuid int64, err error) {
 	key := serviceName + c.containerName
 
 	c.storeLock.Lock()
@@ -80,6 +143,7 @@ func (c *Client) GenUUID(serviceName string) (uuid int64, err error) {
 			v1 := r.ServiceId
 			v2 := r.ContainerId
 			v3 := r.SequenceIdStart
+			layout := v.layout
 			r.SequenceIdStart++
 			if r.SequenceIdStart > r.SequenceIdEnd {
 				// remove used data
@@ -92,25 +156,26 @@ func (c *Client) GenUUID(serviceName string) (uuid int64, err error) {
 				if v.isFetching == false && v.leftCount < v.needCount/2 {
 					// start coroutines
 					v.isFetching = true
-					go c.fetchAndInsert(serviceName, v)
+					// the prefetch outlives the call, it keeps the trace but not the deadline
+					go c.fetchAndInsert(trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx)), serviceName, v)
 				}
 			}
 			v.takeLock.Unlock()
 
 			// return uuid
-			return util.GenUUID(v1, v2, v3), nil
+			return layout.Compose(v1, v2, v3), nil
 		}
 		v.takeLock.Unlock()
 
 		// fetch data
-		err = c.fetchAndInsert(serviceName, v)
+		err = c.fetchAndInsert(ctx, serviceName, v)
 		if err != nil {
 			return 0, err
 		}
 	}
 }
 
-func (c *Client) fetchAndInsert(serviceName string, node *uuidNode) error {
+func (c *Client) fetchAndInsert(ctx context.Context, serviceName string, node *uuidNode) error {
 	node.fetchLock.Lock()
 	defer node.fetchLock.Unlock()
 
@@ -124,11 +189,15 @@ func (c *Client) fetchAndInsert(serviceName string, node *uuidNode) error {
 	node.takeLock.Unlock()
 
 	needCount := node.needCount
-	resp, err := c.fetch(serviceName, c.containerName, needCount)
+	resp, err := c.fetch(ctx, serviceName, c.containerName, needCount)
 	if err != nil {
 		return err
 	}
 	node.takeLock.Lock()
+	node.layout = util.DefaultLayout
+	if l := resp.Layout; l != nil {
+		node.layout = util.Layout{ServiceBits: int(l.ServiceBits), ContainerBits: int(l.ContainerBits), SequenceBits: int(l.SequenceBits)}
+	}
 	node.datas = append(node.datas, resp.Items...)
 	node.leftCount += needCount
 	node.isFetching = false
@@ -143,17 +212,40 @@ func (c *Client) Close() {
 	c.store = nil
 }
 
+// Dial connect to the server with the TLS and token settings of the config,
+// the connection can be used for the other services such as api.Admin.
+func Dial(cfg *Config) (*grpc.ClientConn, error) {
+	secure := cfg.TLS || len(cfg.TLSCAFile) > 0 || len(cfg.TLSCertFile) > 0
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if secure {
+		tlsCfg, err := util.ClientTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSServerName)
+		if err != nil {
+			return nil, err
+		}
+		opts[0] = grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg))
+	}
+	if len(cfg.Token) > 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{token: cfg.Token, secure: secure}))
+	}
+	return grpc.Dial(cfg.Endpoint, opts...)
+}
+
 // NewClient create a new client.
 func NewClient(cfg *Config) (client *Client, err error) {
 	client = &Client{cfg: cfg}
 	if client.cfg.NeedCount == 0 {
 		client.cfg.NeedCount = 1000
 	}
-	client.conn, err = grpc.Dial(client.cfg.Endpoint, grpc.WithInsecure())
+	client.conn, err = Dial(cfg)
 	if err != nil {
 		return nil, err
 	}
 	client.api = api.NewUUIDClient(client.conn)
+	tp := cfg.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	client.tracer = tp.Tracer(tracerName)
 	client.containerName = util.GetContainerName()
 	client.store = make(map[string]*uuidNode)
 	return client, nil
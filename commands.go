@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/client"
+	"github.com/cnwinds/flake/util"
+	cli "github.com/urfave/cli/v2"
+)
+
+// base62Digits the digits of the base62 format of the IDs.
+const base62Digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// clientFlags returns the options of the commands that talk to a server.
+func clientFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "endpoint",
+			EnvVars: []string{"FLAKE_ENDPOINT"},
+			Value:   "127.0.0.1:10001",
+			Usage:   "address:port of the flake server",
+		},
+		&cli.StringFlag{
+			Name:  "namespace",
+			Usage: "namespace on the server, the default namespace if empty",
+		},
+		&cli.StringFlag{
+			Name:    "token",
+			EnvVars: []string{"FLAKE_TOKEN"},
+			Usage:   "bearer token sent to the server",
+		},
+		&cli.BoolFlag{
+			Name:  "tls",
+			Usage: "connect with TLS, implied by the TLS files",
+		},
+		&cli.StringFlag{
+			Name:  "tlsca",
+			Usage: "CA file of the server certificate, the system roots if empty",
+		},
+		&cli.StringFlag{
+			Name:  "tlscert",
+			Usage: "client certificate file",
+		},
+		&cli.StringFlag{
+			Name:  "tlskey",
+			Usage: "client private key file",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Value: 10 * time.Second,
+			Usage: "how long the command may take",
+		},
+	}
+}
+
+func clientConfig(c *cli.Context) *client.Config {
+	return &client.Config{
+		Endpoint:    c.String("endpoint"),
+		Namespace:   c.String("namespace"),
+		Token:       c.String("token"),
+		TLS:         c.Bool("tls"),
+		TLSCAFile:   c.String("tlsca"),
+		TLSCertFile: c.String("tlscert"),
+		TLSKeyFile:  c.String("tlskey"),
+	}
+}
+
+func genCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "gen",
+		Usage:     "fetch IDs from the server and print one per line",
+		UsageText: "flake gen --service NAME [-n COUNT] [--format dec|hex|base62]",
+		Flags: append(clientFlags(),
+			&cli.StringFlag{
+				Name:     "service",
+				Aliases:  []string{"s"},
+				Required: true,
+				Usage:    "service name",
+			},
+			&cli.IntFlag{
+				Name:    "count",
+				Aliases: []string{"n"},
+				Value:   1,
+				Usage:   "number of IDs",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Value: "dec",
+				Usage: "format of the IDs, dec, hex or base62",
+			},
+		),
+		Action: gen,
+	}
+}
+
+func gen(c *cli.Context) error {
+	count := c.Int("count")
+	if count <= 0 {
+		return fmt.Errorf("count must be positive")
+	}
+	format := c.String("format")
+	if _, err := formatID(0, format); err != nil {
+		return err
+	}
+
+	cfg := clientConfig(c)
+	cfg.NeedCount = count
+	cl, err := client.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+	defer cancel()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for i := 0; i < count; i++ {
+		id, err := cl.GenUUIDContext(ctx, c.String("service"))
+		if err != nil {
+			return err
+		}
+		s, _ := formatID(id, format)
+		fmt.Fprintln(w, s)
+	}
+	return nil
+}
+
+func decodeCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "decode",
+		Usage:     "split an ID into the service ID, container ID and sequence",
+		UsageText: "flake decode [--layout S/C/Q] [--format dec|hex|base62] [--resolve] ID",
+		Flags: append(clientFlags(),
+			&cli.StringFlag{
+				Name:  "layout",
+				Value: util.DefaultLayout.String(),
+				Usage: "bits of the service/container/sequence parts of the ID",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Value: "dec",
+				Usage: "format of the ID, dec, hex or base62",
+			},
+			&cli.BoolFlag{
+				Name:  "resolve",
+				Usage: "ask the server for the names of the service and the container, the layout of the namespace is used",
+			},
+		),
+		Action: decode,
+	}
+}
+
+func decode(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("want one ID, got %d arguments", c.NArg())
+	}
+	layout, err := util.ParseLayout(c.String("layout"))
+	if err != nil {
+		return err
+	}
+	id, err := parseID(c.Args().First(), c.String("format"))
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("resolve") {
+		return resolve(c, id)
+	}
+	serviceID, containerID, sequenceID, err := util.ParseUUID(id, layout)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("id:           %d\n", id)
+	fmt.Printf("service_id:   %d\n", serviceID)
+	fmt.Printf("container_id: %d\n", containerID)
+	fmt.Printf("sequence:     %d\n", sequenceID)
+	return nil
+}
+
+// resolve prints the parts of an ID and the names the server knows for them.
+func resolve(c *cli.Context, id int64) error {
+	return withAdmin(c, func(ctx context.Context, admin api.AdminClient) error {
+		reply, err := admin.Resolve(ctx, &api.ResolveRequest{Uuid: id, Namespace: c.String("namespace")})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("id:                   %d\n", id)
+		fmt.Printf("service_id:           %d\n", reply.ServiceId)
+		fmt.Printf("service_name:         %v\n", reply.ServiceName)
+		fmt.Printf("container_id:         %d\n", reply.ContainerId)
+		fmt.Printf("container_name:       %v\n", reply.ContainerName)
+		fmt.Printf("container_reassigned: %v\n", reply.ContainerReassigned)
+		fmt.Printf("sequence:             %d\n", reply.SequenceId)
+		fmt.Printf("allocated:            %v\n", reply.Allocated)
+		return nil
+	})
+}
+
+// formatID renders an ID as dec, hex or base62.
+func formatID(id int64, format string) (string, error) {
+	switch format {
+	case "", "dec":
+		return strconv.FormatInt(id, 10), nil
+	case "hex":
+		return strconv.FormatInt(id, 16), nil
+	case "base62":
+		if id == 0 {
+			return "0", nil
+		}
+		var buf [11]byte
+		i := len(buf)
+		for v := uint64(id); v > 0; v /= 62 {
+			i--
+			buf[i] = base62Digits[v%62]
+		}
+		return string(buf[i:]), nil
+	}
+	return "", fmt.Errorf("unknown format %q, want dec, hex or base62", format)
+}
+
+// parseID reads an ID written by formatID.
+func parseID(s string, format string) (int64, error) {
+	switch format {
+	case "", "dec":
+		return strconv.ParseInt(s, 10, 64)
+	case "hex":
+		return strconv.ParseInt(strings.TrimPrefix(s, "0x"), 16, 64)
+	case "base62":
+		var v uint64
+		for _, r := range s {
+			d := strings.IndexRune(base62Digits, r)
+			if d < 0 {
+				return 0, fmt.Errorf("invalid base62 ID %q", s)
+			}
+			if v > (1<<63-1-uint64(d))/62 {
+				return 0, fmt.Errorf("base62 ID %q is out of range", s)
+			}
+			v = v*62 + uint64(d)
+		}
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("unknown format %q, want dec, hex or base62", format)
+}
+
+func adminCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "admin",
+		Usage: "inspect and change the services and containers",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "service",
+				Usage:     "show the ID of a service name",
+				ArgsUsage: "NAME",
+				Flags:     clientFlags(),
+				Action: adminAction(1, func(ctx context.Context, admin api.AdminClient, c *cli.Context) (*api.ServiceInfo, error) {
+					return admin.GetService(ctx, &api.GetServiceRequest{ServiceName: c.Args().Get(0), Namespace: c.String("namespace")})
+				}),
+			},
+			{
+				Name:      "register",
+				Usage:     "register a service name with an explicit ID",
+				ArgsUsage: "NAME",
+				Flags: append(clientFlags(), &cli.IntFlag{
+					Name:     "id",
+					Required: true,
+					Usage:    "service ID",
+				}),
+				Action: adminAction(1, func(ctx context.Context, admin api.AdminClient, c *cli.Context) (*api.ServiceInfo, error) {
+					return admin.RegisterService(ctx, &api.RegisterServiceRequest{ServiceName: c.Args().Get(0),
+						ServiceId: int32(c.Int("id")), Namespace: c.String("namespace")})
+				}),
+			},
+			{
+				Name:      "alias",
+				Usage:     "add an alias that resolves to the ID of a service",
+				ArgsUsage: "ALIAS NAME",
+				Flags:     clientFlags(),
+				Action: adminAction(2, func(ctx context.Context, admin api.AdminClient, c *cli.Context) (*api.ServiceInfo, error) {
+					return admin.AddAlias(ctx, &api.AddAliasRequest{Alias: c.Args().Get(0), ServiceName: c.Args().Get(1),
+						Namespace: c.String("namespace")})
+				}),
+			},
+			{
+				Name:      "retire",
+				Usage:     "retire a service name, it can not be fetched or registered again",
+				ArgsUsage: "NAME",
+				Flags:     clientFlags(),
+				Action: adminAction(1, func(ctx context.Context, admin api.AdminClient, c *cli.Context) (*api.ServiceInfo, error) {
+					return admin.RetireService(ctx, &api.RetireServiceRequest{ServiceName: c.Args().Get(0), Namespace: c.String("namespace")})
+				}),
+			},
+			{
+				Name:   "services",
+				Usage:  "list the service names and their IDs",
+				Flags:  clientFlags(),
+				Action: adminList(listServices),
+			},
+			{
+				Name:   "containers",
+				Usage:  "list the container names and their IDs",
+				Flags:  clientFlags(),
+				Action: adminList(listContainers),
+			},
+			{
+				Name:   "watermarks",
+				Usage:  "list the next sequence of each service and container and how much of the sequence space is used",
+				Flags:  clientFlags(),
+				Action: adminList(listWatermarks),
+			},
+			{
+				Name:  "reassignments",
+				Usage: "list the recent container ID reassignments, the newest first",
+				Flags: append(clientFlags(), &cli.IntFlag{
+					Name:  "limit",
+					Value: 20,
+					Usage: "number of reassignments",
+				}),
+				Action: adminList(listReassignments),
+			},
+			{
+				Name:      "ledger",
+				Usage:     "show who received the range containing an ID and when",
+				ArgsUsage: "ID",
+				Flags: append(clientFlags(), &cli.StringFlag{
+					Name:  "format",
+					Value: "dec",
+					Usage: "format of the ID, dec, hex or base62",
+				}),
+				Action: lookupLedger,
+			},
+		},
+	}
+}
+
+// adminAction connects to the admin service, calls it and prints the service info it returns.
+func adminAction(nargs int, call func(ctx context.Context, admin api.AdminClient, c *cli.Context) (*api.ServiceInfo, error)) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.NArg() != nargs {
+			return fmt.Errorf("want %d arguments, got %d", nargs, c.NArg())
+		}
+		return withAdmin(c, func(ctx context.Context, admin api.AdminClient) error {
+			info, err := call(ctx, admin, c)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("name:           %v\n", info.Name)
+			fmt.Printf("service_id:     %v\n", info.ServiceId)
+			fmt.Printf("canonical_name: %v\n", info.CanonicalName)
+			fmt.Printf("retired:        %v\n", info.Retired)
+			return nil
+		})
+	}
+}
+
+// adminList runs a list command of the admin service, the rows are printed as a table.
+func adminList(list func(ctx context.Context, admin api.AdminClient, in *api.ListRequest, w *tabwriter.Writer) error) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.NArg() != 0 {
+			return fmt.Errorf("want no arguments, got %d", c.NArg())
+		}
+		return withAdmin(c, func(ctx context.Context, admin api.AdminClient) error {
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			// only the reassignments have a limit, c.Int returns 0 for the others
+			in := &api.ListRequest{Namespace: c.String("namespace"), Limit: int32(c.Int("limit"))}
+			if err := list(ctx, admin, in, w); err != nil {
+				return err
+			}
+			return w.Flush()
+		})
+	}
+}
+
+// withAdmin connects to the admin service and calls it within the timeout.
+func withAdmin(c *cli.Context, call func(ctx context.Context, admin api.AdminClient) error) error {
+	conn, err := client.Dial(clientConfig(c))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+	defer cancel()
+	return call(ctx, api.NewAdminClient(conn))
+}
+
+func lookupLedger(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("want one ID, got %d arguments", c.NArg())
+	}
+	id, err := parseID(c.Args().First(), c.String("format"))
+	if err != nil {
+		return err
+	}
+	return withAdmin(c, func(ctx context.Context, admin api.AdminClient) error {
+		reply, err := admin.LookupLedger(ctx, &api.LookupLedgerRequest{Uuid: id, Namespace: c.String("namespace")})
+		if err != nil {
+			return err
+		}
+		if len(reply.Entries) == 0 {
+			return fmt.Errorf("%v is not in the ledger", id)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tSERVICE\tCONTAINER\tRANGE\tPEER\tIDENTITY\tINSTANCE")
+		for _, e := range reply.Entries {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v-%v\t%v\t%v\t%v\n", time.Unix(0, e.TimeUnixNano).Format(time.RFC3339Nano),
+				e.ServiceName, e.ContainerName, e.SequenceIdStart, e.SequenceIdEnd, e.Peer, e.Identity, e.Instance)
+		}
+		return w.Flush()
+	})
+}
+
+func fsckCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "fsck",
+		Usage:     "check the keys of a namespace, exits with 1 if problems are left",
+		UsageText: "flake fsck [--repair]",
+		Flags: append(clientFlags(), &cli.BoolFlag{
+			Name:  "repair",
+			Usage: "repair the problems that can be repaired safely, nothing is deleted or lowered",
+		}),
+		Action: fsck,
+	}
+}
+
+func fsck(c *cli.Context) error {
+	return withAdmin(c, func(ctx context.Context, admin api.AdminClient) error {
+		reply, err := admin.Fsck(ctx, &api.FsckRequest{Namespace: c.String("namespace"), Repair: c.Bool("repair")})
+		if err != nil {
+			return err
+		}
+		left := 0
+		for _, p := range reply.Problems {
+			state := "unrepaired"
+			if p.Repaired {
+				state = "repaired"
+			} else {
+				left++
+			}
+			fmt.Printf("%v: %v (%v)\n", p.Key, p.Problem, state)
+		}
+		fmt.Printf("%d keys checked, %d problems, %d left\n", reply.Keys, len(reply.Problems), left)
+		if left > 0 {
+			return cli.Exit("", 1)
+		}
+		return nil
+	})
+}
+
+func listServices(ctx context.Context, admin api.AdminClient, in *api.ListRequest, w *tabwriter.Writer) error {
+	reply, err := admin.ListServices(ctx, in)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "NAME\tSERVICE_ID\tCANONICAL_NAME\tRETIRED")
+	for _, info := range reply.Services {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", info.Name, info.ServiceId, info.CanonicalName, info.Retired)
+	}
+	return nil
+}
+
+func listContainers(ctx context.Context, admin api.AdminClient, in *api.ListRequest, w *tabwriter.Writer) error {
+	reply, err := admin.ListContainers(ctx, in)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "NAME\tCONTAINER_ID")
+	for _, info := range reply.Containers {
+		fmt.Fprintf(w, "%v\t%v\n", info.Name, info.ContainerId)
+	}
+	return nil
+}
+
+func listWatermarks(ctx context.Context, admin api.AdminClient, in *api.ListRequest, w *tabwriter.Writer) error {
+	reply, err := admin.ListWatermarks(ctx, in)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "SERVICE\tSERVICE_ID\tCONTAINER\tCONTAINER_ID\tNEXT_SEQUENCE\tUSED")
+	for _, m := range reply.Watermarks {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%.4f%%\n", m.ServiceName, m.ServiceId, m.ContainerName, m.ContainerId, m.NextSequence, m.UsedPercent)
+	}
+	return nil
+}
+
+func listReassignments(ctx context.Context, admin api.AdminClient, in *api.ListRequest, w *tabwriter.Writer) error {
+	reply, err := admin.ListReassignments(ctx, in)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "TIME\tCONTAINER\tOLD_CONTAINER_ID\tNEW_CONTAINER_ID")
+	for _, r := range reply.Reassignments {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", time.Unix(r.Time, 0).Format(time.RFC3339), r.ContainerName, r.OldContainerId, r.NewContainerId)
+	}
+	return nil
+}
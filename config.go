@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cnwinds/flake/server"
+	"github.com/cnwinds/flake/util"
+	cli "github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+	"gopkg.in/yaml.v2"
+)
+
+// configFlag the flag naming the YAML or TOML config file.
+const configFlag = "config"
+
+// secretFlags the flags that are redacted by "flake config print".
+var secretFlags = map[string]bool{"etcdpassword": true}
+
+// listEnvVars the environment variables of list flags whose entries contain commas,
+// the entries are separated by semicolons instead.
+var listEnvVars = map[string]string{
+	"servicequota":     "FLAKE_SERVICE_QUOTA",
+	"acl":              "FLAKE_ACL",
+	"serviceallowlist": "FLAKE_SERVICE_ALLOWLIST",
+}
+
+// configSource reads the flags from the config file, numbers are accepted for float flags.
+type configSource struct {
+	altsrc.InputSourceContext
+	values map[string]interface{}
+}
+
+func (s configSource) Float64(name string) (float64, error) {
+	switch v := s.values[name].(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	}
+	return s.InputSourceContext.Float64(name)
+}
+
+// withConfig applies the config file to the flags before the action runs.
+func withConfig(flags []cli.Flag, action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if err := loadConfigFile(c, flags); err != nil {
+			return err
+		}
+		return action(c)
+	}
+}
+
+// loadConfigFile applies the config file to the flags that are neither given on
+// the command line nor in the environment.
+func loadConfigFile(c *cli.Context, flags []cli.Flag) error {
+	file := c.String(configFlag)
+	if len(file) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]interface{})
+	var src altsrc.InputSourceContext
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+		if err == nil {
+			src, err = altsrc.NewYamlSourceFromFile(file)
+		}
+	case ".toml":
+		_, err = toml.Decode(string(data), &values)
+		if err == nil {
+			src, err = altsrc.NewTomlSourceFromFile(file)
+		}
+	default:
+		return fmt.Errorf("config file %v: unknown format, want .yaml, .yml or .toml", file)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %v: %v", file, err)
+	}
+
+	known := make(map[string]bool)
+	for _, f := range flags {
+		known[f.Names()[0]] = true
+	}
+	var unknown []string
+	for key := range values {
+		if !known[key] || key == configFlag {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("config file %v: unknown options %v", file, strings.Join(unknown, ", "))
+	}
+	err = altsrc.ApplyInputSourceValues(c, configSource{InputSourceContext: src, values: values}, flags)
+	if err != nil {
+		return fmt.Errorf("config file %v: %v", file, err)
+	}
+	return nil
+}
+
+// listValue returns the entries of a list flag, the environment variable of the
+// flag is read here since its entries are separated by semicolons.
+func listValue(c *cli.Context, name string) []string {
+	if !c.IsSet(name) {
+		if env, ok := os.LookupEnv(listEnvVars[name]); ok {
+			var entries []string
+			for _, entry := range strings.Split(env, ";") {
+				if entry = strings.TrimSpace(entry); len(entry) > 0 {
+					entries = append(entries, entry)
+				}
+			}
+			return entries
+		}
+	}
+	return c.StringSlice(name)
+}
+
+// buildConfig parse the flags into the server config and validate it.
+func buildConfig(c *cli.Context) (*server.Config, error) {
+	layout, err := util.ParseLayout(c.String("layout"))
+	if err != nil {
+		return nil, err
+	}
+	var namespaces []server.NamespaceConfig
+	for _, spec := range c.StringSlice("namespace") {
+		ns, err := server.ParseNamespace(spec)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, ns)
+	}
+	serviceQuotas := make(map[string]server.Quota)
+	for _, spec := range listValue(c, "servicequota") {
+		name, q, err := server.ParseServiceQuota(spec)
+		if err != nil {
+			return nil, err
+		}
+		serviceQuotas[name] = q
+	}
+	var containerQuota server.Quota
+	if spec := c.String("containerquota"); len(spec) > 0 {
+		containerQuota, err = server.ParseQuota(spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	acl := make(map[string][]string)
+	for _, spec := range listValue(c, "acl") {
+		identity, patterns, err := server.ParseACL(spec)
+		if err != nil {
+			return nil, err
+		}
+		acl[identity] = append(acl[identity], patterns...)
+	}
+	if sample := c.Float64("tracesample"); sample < 0 || sample > 1 {
+		return nil, fmt.Errorf("trace sample rate %v is not between 0 and 1", sample)
+	}
+
+	cfg := &server.Config{
+		Endpoints:         c.StringSlice("etcdhosts"),
+		ListenAddress:     c.String("listen"),
+		HTTPListenAddress: c.String("httplisten"),
+		HTTPGateway:       c.Bool("httpgateway"),
+		RESPListenAddress: c.String("resplisten"),
+		RESPCacheCount:    int32(c.Int("respcache")),
+		HealthInterval:    c.Duration("healthinterval"),
+		Prefix:            c.String("etcdkeyprefix"),
+		UserName:          c.String("etcduser"),
+		Password:          c.String("etcdpassword"),
+		EtcdCAFile:        c.String("etcdca"),
+		EtcdCertFile:      c.String("etcdcert"),
+		EtcdKeyFile:       c.String("etcdkey"),
+
+		TLSCertFile:     c.String("tlscert"),
+		TLSKeyFile:      c.String("tlskey"),
+		TLSClientCAFile: c.String("tlsclientca"),
+
+		ReservationTTL:     c.Duration("reservationttl"),
+		RegistrationPolicy: c.String("registration"),
+		ServiceAllowlist:   listValue(c, "serviceallowlist"),
+		Layout:             layout,
+		Namespaces:         namespaces,
+		ServiceQuotas:      serviceQuotas,
+		ContainerQuota:     containerQuota,
+
+		AuthTokenFile:  c.String("authtokens"),
+		AuthJWTKeyFile: c.String("jwtkey"),
+		AdminTokenFile: c.String("admintokens"),
+		ACL:            acl,
+
+		AccessLogSampleRate: c.Float64("accesslog"),
+
+		LedgerStore:     c.Bool("ledgerstore"),
+		LedgerTTL:       c.Duration("ledgerttl"),
+		LedgerFile:      c.String("ledgerfile"),
+		LedgerFileSize:  int64(c.Int("ledgerfilesize")) << 20,
+		LedgerFileCount: c.Int("ledgerfiles"),
+		InstanceName:    c.String("instance"),
+		StateFile:       c.String("statefile"),
+	}
+	return cfg, cfg.Validate()
+}
+
+// printConfig prints the effective options as a YAML config file, the secrets
+// are redacted. The config is validated after it is printed.
+func printConfig(flags []cli.Flag) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		var options yaml.MapSlice
+		for _, f := range flags {
+			name := f.Names()[0]
+			if name == configFlag {
+				continue
+			}
+			var value interface{}
+			switch f.(type) {
+			case *altsrc.StringSliceFlag:
+				value = listValue(c, name)
+			case *altsrc.BoolFlag:
+				value = c.Bool(name)
+			case *altsrc.IntFlag:
+				value = c.Int(name)
+			case *altsrc.Float64Flag:
+				value = c.Float64(name)
+			case *altsrc.DurationFlag:
+				value = c.Duration(name).String()
+			default:
+				value = c.String(name)
+				if secretFlags[name] && len(c.String(name)) > 0 {
+					value = "******"
+				}
+			}
+			options = append(options, yaml.MapItem{Key: name, Value: value})
+		}
+		out, err := yaml.Marshal(options)
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(out)
+
+		if _, err := buildConfig(c); err != nil {
+			return cli.Exit(fmt.Sprintf("invalid config:\n%v", err), 1)
+		}
+		return nil
+	}
+}
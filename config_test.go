@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	cli "github.com/urfave/cli/v2"
+)
+
+func TestListEnvVars(t *testing.T) {
+	// a regular expression may hold commas, the entries are separated by semicolons
+	t.Setenv("FLAKE_REGISTRATION", "allowlist")
+	t.Setenv("FLAKE_SERVICE_ALLOWLIST", "^order-[a-z]{1,8}$; ^billing$")
+	flags := serverFlags()
+	var got []string
+	app := &cli.App{
+		Flags: flags,
+		Action: withConfig(flags, func(c *cli.Context) error {
+			cfg, err := buildConfig(c)
+			if err != nil {
+				return err
+			}
+			got = cfg.ServiceAllowlist
+			return nil
+		}),
+	}
+	if err := app.Run([]string{"flake"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"^order-[a-z]{1,8}$", "^billing$"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("allowlist: got %q, want %q", got, want)
+	}
+}
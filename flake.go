@@ -1,47 +1,483 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/cnwinds/flake/server"
+	"github.com/cnwinds/flake/util"
 	cli "github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
 )
 
-func main() {
+// serverFlags returns the options of the server. Each option is taken from the command line,
+// then the FLAKE_* environment variable, then the config file, then the default.
+func serverFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    configFlag,
+			EnvVars: []string{"FLAKE_CONFIG"},
+			Usage:   "YAML (.yaml, .yml) or TOML (.toml) file with the options below, named like the flags",
+		},
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "listen",
+			EnvVars: []string{"FLAKE_LISTEN"},
+			Value:   "127.0.0.1:10001",
+			Usage:   "listen address:port",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "httplisten",
+			EnvVars: []string{"FLAKE_HTTP_LISTEN"},
+			Usage:   "listen address:port of the HTTP endpoints /healthz, /readyz and /metrics, disabled if empty",
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    "httpgateway",
+			EnvVars: []string{"FLAKE_HTTP_GATEWAY"},
+			Usage:   "serve the HTTP/JSON API /v1/ids/{service} on the HTTP address",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "resplisten",
+			EnvVars: []string{"FLAKE_RESP_LISTEN"},
+			Usage:   "listen address:port of the Redis protocol frontend, disabled if empty",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    "respcache",
+			EnvVars: []string{"FLAKE_RESP_CACHE"},
+			Value:   server.DefaultRESPCacheCount,
+			Usage:   "number of IDs of a service the Redis frontend fetches at once",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "healthinterval",
+			EnvVars: []string{"FLAKE_HEALTH_INTERVAL"},
+			Value:   server.DefaultHealthInterval,
+			Usage:   "how often the store is checked for the health service",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "tlscert",
+			EnvVars: []string{"FLAKE_TLS_CERT"},
+			Usage:   "server certificate file, enables TLS",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "tlskey",
+			EnvVars: []string{"FLAKE_TLS_KEY"},
+			Usage:   "server private key file",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "tlsclientca",
+			EnvVars: []string{"FLAKE_TLS_CLIENT_CA"},
+			Usage:   "CA file of the client certificates, enables mutual TLS",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "etcdkeyprefix",
+			EnvVars: []string{"FLAKE_ETCD_KEY_PREFIX"},
+			Value:   "/flake/",
+			Usage:   "etcd key path prefix",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    "etcdhosts",
+			EnvVars: []string{"FLAKE_ETCD_HOSTS"},
+			Value:   cli.NewStringSlice("http://127.0.0.1:32379"),
+			Usage:   "etcd hosts",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "etcduser",
+			EnvVars: []string{"FLAKE_ETCD_USER"},
+			Usage:   "etcd user name",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "etcdpassword",
+			EnvVars: []string{"FLAKE_ETCD_PASSWORD"},
+			Usage:   "etcd password, prefer the environment variable to keep it out of the process list",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "etcdca",
+			EnvVars: []string{"FLAKE_ETCD_CA"},
+			Usage:   "CA file of the etcd server certificates",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "etcdcert",
+			EnvVars: []string{"FLAKE_ETCD_CERT"},
+			Usage:   "client certificate file for etcd",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "etcdkey",
+			EnvVars: []string{"FLAKE_ETCD_KEY"},
+			Usage:   "client private key file for etcd",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "reservationttl",
+			EnvVars: []string{"FLAKE_RESERVATION_TTL"},
+			Value:   server.DefaultReservationTTL,
+			Usage:   "how long a gapless number stays reserved",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "registration",
+			EnvVars: []string{"FLAKE_REGISTRATION"},
+			Value:   server.PolicyOpen,
+			Usage:   "which new service names get an ID: open, allowlist or registered",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  "serviceallowlist",
+			Usage: "regular expression of the new service names accepted by the allowlist policy, $FLAKE_SERVICE_ALLOWLIST separated by ;",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "layout",
+			EnvVars: []string{"FLAKE_LAYOUT"},
+			Value:   util.DefaultLayout.String(),
+			Usage:   "bits of the service/container/sequence parts of the UUID",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    "namespace",
+			EnvVars: []string{"FLAKE_NAMESPACE"},
+			Usage:   "namespace with its own etcd key path prefix, name=prefix[@layout]",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  "servicequota",
+			Usage: "limits of a service, [service:]segment=N,rate=N,burst=N,ids=N/window, $FLAKE_SERVICE_QUOTA separated by ;",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "containerquota",
+			EnvVars: []string{"FLAKE_CONTAINER_QUOTA"},
+			Usage:   "limits of each container, segment=N,rate=N,burst=N,ids=N/window",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "authtokens",
+			EnvVars: []string{"FLAKE_AUTH_TOKENS"},
+			Usage:   "file of static bearer tokens, one \"identity token\" per line",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "admintokens",
+			EnvVars: []string{"FLAKE_ADMIN_TOKENS"},
+			Usage:   "file of the static bearer tokens of the admin service, one \"identity token\" per line",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "jwtkey",
+			EnvVars: []string{"FLAKE_JWT_KEY"},
+			Usage:   "PEM public key or HMAC secret file that verifies JWT bearer tokens",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  "acl",
+			Usage: "service name patterns an identity may use, identity=pattern[,pattern], $FLAKE_ACL separated by ;",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "draindelay",
+			EnvVars: []string{"FLAKE_DRAIN_DELAY"},
+			Usage:   "how long new fetches are refused before the server stops on SIGTERM",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "shutdowntimeout",
+			EnvVars: []string{"FLAKE_SHUTDOWN_TIMEOUT"},
+			Value:   30 * time.Second,
+			Usage:   "how long the calls in flight may take to finish on SIGTERM",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "loglevel",
+			EnvVars: []string{"FLAKE_LOG_LEVEL"},
+			Value:   "info",
+			Usage:   "log level, debug, info, warn or error",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "logformat",
+			EnvVars: []string{"FLAKE_LOG_FORMAT"},
+			Value:   "text",
+			Usage:   "log format, text or json",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "tracefile",
+			EnvVars: []string{"FLAKE_TRACE_FILE"},
+			Usage:   "file the OpenTelemetry spans are appended to as JSON lines, - for stdout, disabled if empty",
+		}),
+		altsrc.NewFloat64Flag(&cli.Float64Flag{
+			Name:    "tracesample",
+			EnvVars: []string{"FLAKE_TRACE_SAMPLE"},
+			Value:   1,
+			Usage:   "share of the new traces recorded, from 0 to 1",
+		}),
+		altsrc.NewFloat64Flag(&cli.Float64Flag{
+			Name:    "accesslog",
+			EnvVars: []string{"FLAKE_ACCESS_LOG"},
+			Usage:   "share of the calls written to the access log, from 0 (none) to 1 (all)",
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    "ledgerstore",
+			EnvVars: []string{"FLAKE_LEDGER_STORE"},
+			Usage:   "record every issued range in etcd",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "ledgerttl",
+			EnvVars: []string{"FLAKE_LEDGER_TTL"},
+			Usage:   "how long the ranges stay in etcd, forever if 0",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "ledgerfile",
+			EnvVars: []string{"FLAKE_LEDGER_FILE"},
+			Usage:   "record every issued range in a local file, disabled if empty",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    "ledgerfilesize",
+			EnvVars: []string{"FLAKE_LEDGER_FILE_SIZE"},
+			Value:   server.DefaultLedgerFileSize >> 20,
+			Usage:   "size in MB at which the ledger file is rotated",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    "ledgerfiles",
+			EnvVars: []string{"FLAKE_LEDGER_FILES"},
+			Value:   server.DefaultLedgerFileCount,
+			Usage:   "number of rotated ledger files that are kept",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "instance",
+			EnvVars: []string{"FLAKE_INSTANCE"},
+			Usage:   "name of the server in the ledger, hostname/listen address if empty",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "statefile",
+			EnvVars: []string{"FLAKE_STATE_FILE"},
+			Usage:   "local record of the highest IDs seen, the server refuses a wiped or rolled back store, disabled if empty",
+		}),
+	}
+}
+
+// pickFlags returns the flags with the given names.
+func pickFlags(flags []cli.Flag, names ...string) []cli.Flag {
+	var picked []cli.Flag
+	for _, f := range flags {
+		for _, name := range names {
+			if f.Names()[0] == name {
+				picked = append(picked, f)
+			}
+		}
+	}
+	return picked
+}
+
+// hide hides the flags in the help.
+func hide(flags []cli.Flag) []cli.Flag {
+	for _, f := range flags {
+		switch f := f.(type) {
+		case *cli.StringFlag:
+			f.Hidden = true
+		case *altsrc.StringFlag:
+			f.Hidden = true
+		case *altsrc.StringSliceFlag:
+			f.Hidden = true
+		case *altsrc.BoolFlag:
+			f.Hidden = true
+		case *altsrc.IntFlag:
+			f.Hidden = true
+		case *altsrc.Float64Flag:
+			f.Hidden = true
+		case *altsrc.DurationFlag:
+			f.Hidden = true
+		}
+	}
+	return flags
+}
 
+func main() {
+	// the server options without a command are kept for compatibility, use "flake serve"
+	rootFlags := hide(serverFlags())
 	app := &cli.App{
-		Name: "flake",
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:  "listen",
-				Value: "127.0.0.1:10001",
-				Usage: "listen address:port",
+		Name:    "flake",
+		Usage:   "distributed UUID generator",
+		Version: server.Version,
+		Flags:   rootFlags,
+		Action:  withConfig(rootFlags, serve),
+		Commands: []*cli.Command{
+			serveCommand(),
+			genCommand(),
+			decodeCommand(),
+			adminCommand(),
+			verifyCommand(),
+			fsckCommand(),
+			fenceCommand(),
+			schemaCommand(),
+			configCommand(),
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func serveCommand() *cli.Command {
+	flags := serverFlags()
+	return &cli.Command{
+		Name:   "serve",
+		Usage:  "run the server",
+		Flags:  flags,
+		Action: withConfig(flags, serve),
+	}
+}
+
+func configCommand() *cli.Command {
+	flags := serverFlags()
+	return &cli.Command{
+		Name:  "config",
+		Usage: "inspect the configuration of the server",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "print",
+				Usage:  "print the effective configuration with the secrets redacted",
+				Flags:  flags,
+				Action: withConfig(flags, printConfig(flags)),
 			},
-			&cli.StringFlag{
-				Name:  "etcdkeyprefix",
-				Value: "/flake/",
-				Usage: "etcd key path prefix",
+		},
+	}
+}
+
+func fenceCommand() *cli.Command {
+	flags := serverFlags()
+	return &cli.Command{
+		Name:  "fence",
+		Usage: "manage the detection of wiped or rolled back stores",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "ack",
+				Usage:     "raise the store to the state files of every server and accept it",
+				ArgsUsage: "<state file>...",
+				Flags:     flags,
+				Action:    withConfig(flags, fenceAck),
 			},
-			&cli.StringSliceFlag{
-				Name:  "etcdhosts",
-				Value: cli.NewStringSlice("http://127.0.0.1:32379"),
-				Usage: "etcd hosts",
+		},
+	}
+}
+
+// fenceAck raises the store to the state files given as arguments and bumps its epoch.
+func fenceAck(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+	files := c.Args().Slice()
+	if len(cfg.StateFile) > 0 {
+		files = append(files, cfg.StateFile)
+	}
+	epoch, err := server.AckEpoch(context.Background(), cfg, files)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("epoch %d\n", epoch)
+	return nil
+}
+
+func schemaCommand() *cli.Command {
+	flags := serverFlags()
+	return &cli.Command{
+		Name:  "schema",
+		Usage: "inspect and upgrade the schema of the keys in etcd",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "status",
+				Usage:  "print the schema of every namespace",
+				Flags:  flags,
+				Action: withConfig(flags, schemaAction(server.SchemaStatuses)),
+			},
+			{
+				Name:   "upgrade",
+				Usage:  "upgrade every namespace to the schema of this version, stop the old servers first",
+				Flags:  flags,
+				Action: withConfig(flags, schemaAction(server.UpgradeSchema)),
 			},
 		},
-		Action: func(c *cli.Context) error {
-			cfg := server.Config{
-				Endpoints:     c.StringSlice("etcdhosts"),
-				ListenAddress: c.String("listen"),
-				Prefix:        c.String("etcdkeyprefix"),
+	}
+}
+
+func schemaAction(run func(context.Context, *server.Config) ([]*server.SchemaStatus, error)) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		cfg, err := buildConfig(c)
+		if err != nil {
+			return err
+		}
+		statuses, err := run(context.Background(), cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("flake %v, schema %d\n", server.Version, server.SchemaVersion)
+		for _, st := range statuses {
+			switch {
+			case st.Empty:
+				fmt.Printf("namespace %q (%v): empty\n", st.Namespace, st.Prefix)
+			case st.Schema == 0:
+				fmt.Printf("namespace %q (%v): schema 0, written before the schema was recorded\n", st.Namespace, st.Prefix)
+			default:
+				fmt.Printf("namespace %q (%v): schema %d, layout %v, flake %v\n", st.Namespace, st.Prefix, st.Schema, st.Layout, st.Version)
+			}
+			for _, step := range st.Upgraded {
+				fmt.Printf("  upgraded to schema %v\n", step)
 			}
-			_, err := server.StartServer(&cfg)
+		}
+		return nil
+	}
+}
+
+// serve runs the server until it is shut down by a signal.
+func serve(c *cli.Context) error {
+	logger, err := server.NewLogger(os.Stderr, c.String("loglevel"), c.String("logformat"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	cfg, err := buildConfig(c)
+	if err != nil {
+		log.Fatalf("invalid config:\n%v", err)
+	}
+	cfg.Logger = logger
+	if file := c.String("tracefile"); len(file) > 0 {
+		out := os.Stdout
+		if file != "-" {
+			out, err = os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 			if err != nil {
 				log.Fatal(err)
 			}
-			return err
-		},
+			defer out.Close()
+		}
+		tp, err := server.NewWriterTracerProvider(out, c.Float64("tracesample"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer tp.Shutdown(context.Background())
+		cfg.TracerProvider = tp
 	}
 
-	app.Run(os.Args)
+	svr, err := server.NewServer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	done := make(chan struct{})
+	go shutdownOnSignal(svr, c.Duration("draindelay"), c.Duration("shutdowntimeout"), done)
+	err = svr.Serve()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Serve returns as soon as gRPC stops, the ledger and the state file are closed after it
+	<-done
+	logger.Info("flake stopped")
+	return nil
+}
+
+// shutdownOnSignal drain the server on SIGTERM or SIGINT, then stop it gracefully.
+// done is closed when the shutdown is finished.
+func shutdownOnSignal(svr *server.UUIDServer, drainDelay time.Duration, timeout time.Duration, done chan<- struct{}) {
+	defer close(done)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	slog.Info("flake is shutting down", "signal", sig.String())
+
+	svr.Drain()
+	time.Sleep(drainDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := svr.Shutdown(ctx); err != nil {
+		slog.Error("flake shutdown", "error", err)
+	}
 }
@@ -0,0 +1,337 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cnwinds/flake/api"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retiredPrefix marks the record of a retired service name, the name keeps its
+// ID so neither the name nor the ID is ever handed out again.
+const retiredPrefix = "retired:"
+
+// parseServiceRecord parses the value saved under the service directory.
+func parseServiceRecord(value string) (id int, retired bool, err error) {
+	if strings.HasPrefix(value, retiredPrefix) {
+		id, err = strconv.Atoi(value[len(retiredPrefix):])
+		return id, true, err
+	}
+	id, err = strconv.Atoi(value)
+	return id, false, err
+}
+
+// RegisterService pre-register a service name with an explicit ID.
+func (s *UUIDServer) RegisterService(ctx context.Context, in *api.RegisterServiceRequest) (*api.ServiceInfo, error) {
+	serviceID := int(in.ServiceId)
+	if len(in.ServiceName) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "service name is required")
+	}
+	if err := checkName("service", in.ServiceName); err != nil {
+		return nil, err
+	}
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if serviceID <= StartOfServerID || serviceID >= ns.layout.MaxServiceID() {
+		return nil, status.Errorf(codes.InvalidArgument, "service ID must be between %d and %d", StartOfServerID+1, ns.layout.MaxServiceID()-1)
+	}
+
+	key := ns.nameKey(KeyOfServiceDir, in.ServiceName)
+	r, err := s.etcdWrap.Get(ctx, key)
+	if err == nil {
+		id, retired, err := parseServiceRecord(r.Node.Value)
+		if err != nil {
+			return nil, err
+		}
+		if id == serviceID && !retired {
+			return s.serviceInfo(ctx, ns, in.ServiceName, id, retired)
+		}
+		return nil, status.Errorf(codes.AlreadyExists, "service %q is already registered with ID %d", in.ServiceName, id)
+	}
+	if !s.etcdWrap.IsKeyNotFound(err) {
+		return nil, err
+	}
+
+	owner, err := s.serviceIDOwner(ctx, ns, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(owner) > 0 {
+		return nil, status.Errorf(codes.AlreadyExists, "service ID %d is taken by %q", serviceID, owner)
+	}
+	ok, err := s.claimServiceID(ctx, ns, serviceID, in.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, status.Errorf(codes.AlreadyExists, "service ID %d is taken", serviceID)
+	}
+	_, err = s.etcdWrap.Set(ctx, key, strconv.Itoa(serviceID), &client.SetOptions{PrevExist: client.PrevNoExist})
+	if err != nil {
+		s.releaseServiceID(ctx, ns, serviceID, in.ServiceName)
+		if s.etcdWrap.IsKeyExist(err) {
+			return nil, status.Errorf(codes.AlreadyExists, "service %q is already registered", in.ServiceName)
+		}
+		return nil, err
+	}
+	return s.serviceInfo(ctx, ns, in.ServiceName, serviceID, false)
+}
+
+// AddAlias add an alias that resolves to the same ID as the service.
+func (s *UUIDServer) AddAlias(ctx context.Context, in *api.AddAliasRequest) (*api.ServiceInfo, error) {
+	if len(in.Alias) == 0 || len(in.ServiceName) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "alias and service name are required")
+	}
+	if in.Alias == in.ServiceName {
+		return nil, status.Error(codes.InvalidArgument, "alias must differ from the service name")
+	}
+	if err := checkName("alias", in.Alias); err != nil {
+		return nil, err
+	}
+	if err := checkName("service", in.ServiceName); err != nil {
+		return nil, err
+	}
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.etcdWrap.Get(ctx, ns.nameKey(KeyOfServiceDir, in.ServiceName))
+	if err != nil {
+		if s.etcdWrap.IsKeyNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "service %q is not registered", in.ServiceName)
+		}
+		return nil, err
+	}
+	serviceID, retired, err := parseServiceRecord(r.Node.Value)
+	if err != nil {
+		return nil, err
+	}
+	if retired {
+		return nil, status.Errorf(codes.FailedPrecondition, "service %q is retired", in.ServiceName)
+	}
+	canonical, err := s.canonicalName(ctx, ns, in.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// the alias record goes first, a crash before the service record leaves
+	// an alias that nobody can fetch with yet.
+	aliasKey := ns.nameKey(KeyOfAliasDir, in.Alias)
+	created := true
+	_, err = s.etcdWrap.Set(ctx, aliasKey, canonical, &client.SetOptions{PrevExist: client.PrevNoExist})
+	if err != nil {
+		if !s.etcdWrap.IsKeyExist(err) {
+			return nil, err
+		}
+		cur, err := s.canonicalName(ctx, ns, in.Alias)
+		if err != nil {
+			return nil, err
+		}
+		if cur != canonical {
+			return nil, status.Errorf(codes.AlreadyExists, "%q is already an alias of %q", in.Alias, cur)
+		}
+		created = false
+	}
+
+	_, err = s.etcdWrap.Set(ctx, ns.nameKey(KeyOfServiceDir, in.Alias), strconv.Itoa(serviceID), &client.SetOptions{PrevExist: client.PrevNoExist})
+	if err != nil {
+		if !s.etcdWrap.IsKeyExist(err) {
+			return nil, err
+		}
+		r, err := s.etcdWrap.Get(ctx, ns.nameKey(KeyOfServiceDir, in.Alias))
+		if err != nil {
+			return nil, err
+		}
+		id, _, err := parseServiceRecord(r.Node.Value)
+		if err != nil {
+			return nil, err
+		}
+		if id != serviceID {
+			if created {
+				s.etcdWrap.Delete(ctx, aliasKey)
+			}
+			return nil, status.Errorf(codes.AlreadyExists, "service %q is already registered with ID %d", in.Alias, id)
+		}
+	}
+
+	// the service may have been retired meanwhile without seeing the new alias
+	r, err = s.etcdWrap.Get(ctx, ns.nameKey(KeyOfServiceDir, canonical))
+	if err != nil {
+		return nil, err
+	}
+	if _, retired, err = parseServiceRecord(r.Node.Value); err != nil {
+		return nil, err
+	}
+	if retired {
+		if _, err := s.retireName(ctx, ns, in.Alias); err != nil {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.FailedPrecondition, "service %q is retired", canonical)
+	}
+	return s.serviceInfo(ctx, ns, in.Alias, serviceID, false)
+}
+
+// RetireService tombstone a service name, it can not be fetched or registered again.
+// Retiring a canonical name retires its aliases too.
+func (s *UUIDServer) RetireService(ctx context.Context, in *api.RetireServiceRequest) (*api.ServiceInfo, error) {
+	if len(in.ServiceName) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "service name is required")
+	}
+	if err := checkName("service", in.ServiceName); err != nil {
+		return nil, err
+	}
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	serviceID, err := s.retireName(ctx, ns, in.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.serviceInfo(ctx, ns, in.ServiceName, serviceID, true)
+	if err != nil || info.CanonicalName != in.ServiceName {
+		return info, err
+	}
+	nodes, err := s.etcdWrap.List(ctx, ns.key(KeyOfAliasDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		if node.Dir || node.Value != in.ServiceName {
+			continue
+		}
+		if _, err := s.retireName(ctx, ns, nameOfKey(node.Key)); err != nil && status.Code(err) != codes.NotFound {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// retireName writes the tombstone of a service name and returns its ID.
+func (s *UUIDServer) retireName(ctx context.Context, ns *namespace, serviceName string) (int, error) {
+	key := ns.nameKey(KeyOfServiceDir, serviceName)
+	for {
+		r, err := s.etcdWrap.Get(ctx, key)
+		if err != nil {
+			if s.etcdWrap.IsKeyNotFound(err) {
+				return 0, status.Errorf(codes.NotFound, "service %q is not registered", serviceName)
+			}
+			return 0, err
+		}
+		serviceID, retired, err := parseServiceRecord(r.Node.Value)
+		if err != nil {
+			return 0, err
+		}
+		if !retired {
+			_, err = s.etcdWrap.Set(ctx, key, retiredPrefix+strconv.Itoa(serviceID), &client.SetOptions{PrevIndex: r.Node.ModifiedIndex})
+			if err != nil {
+				// modify conflict, again
+				countConflict("retire", err)
+				continue
+			}
+		}
+		return serviceID, nil
+	}
+}
+
+// GetService look up the ID of a service name.
+func (s *UUIDServer) GetService(ctx context.Context, in *api.GetServiceRequest) (*api.ServiceInfo, error) {
+	if len(in.ServiceName) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "service name is required")
+	}
+	if err := checkName("service", in.ServiceName); err != nil {
+		return nil, err
+	}
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.etcdWrap.Get(ctx, ns.nameKey(KeyOfServiceDir, in.ServiceName))
+	if err != nil {
+		if s.etcdWrap.IsKeyNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "service %q is not registered", in.ServiceName)
+		}
+		return nil, err
+	}
+	serviceID, retired, err := parseServiceRecord(r.Node.Value)
+	if err != nil {
+		return nil, err
+	}
+	return s.serviceInfo(ctx, ns, in.ServiceName, serviceID, retired)
+}
+
+func (s *UUIDServer) serviceInfo(ctx context.Context, ns *namespace, serviceName string, serviceID int, retired bool) (*api.ServiceInfo, error) {
+	canonical, err := s.canonicalName(ctx, ns, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ServiceInfo{Name: serviceName, ServiceId: int32(serviceID), CanonicalName: canonical, Retired: retired}, nil
+}
+
+// canonicalName returns the name an alias points to, or the name itself.
+func (s *UUIDServer) canonicalName(ctx context.Context, ns *namespace, serviceName string) (string, error) {
+	r, err := s.etcdWrap.Get(ctx, ns.nameKey(KeyOfAliasDir, serviceName))
+	if err != nil {
+		if s.etcdWrap.IsKeyNotFound(err) {
+			return serviceName, nil
+		}
+		return "", err
+	}
+	return r.Node.Value, nil
+}
+
+// claimServiceID records serviceName as the owner of serviceID.
+// It returns false if the ID already has an owner.
+func (s *UUIDServer) claimServiceID(ctx context.Context, ns *namespace, serviceID int, serviceName string) (bool, error) {
+	key := ns.key(KeyOfServiceIDDir, strconv.Itoa(serviceID))
+	_, err := s.etcdWrap.Set(ctx, key, serviceName, &client.SetOptions{PrevExist: client.PrevNoExist})
+	if err != nil {
+		if s.etcdWrap.IsKeyExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseServiceID drops the claim of serviceName on serviceID, if it still holds it.
+func (s *UUIDServer) releaseServiceID(ctx context.Context, ns *namespace, serviceID int, serviceName string) {
+	key := ns.key(KeyOfServiceIDDir, strconv.Itoa(serviceID))
+	r, err := s.etcdWrap.Get(ctx, key)
+	if err != nil || r.Node.Value != serviceName {
+		return
+	}
+	s.etcdWrap.CompareAndDelete(ctx, key, r.Node.ModifiedIndex)
+}
+
+// serviceIDOwner returns the name that owns serviceID, or "" if it is free.
+// Services registered before IDs were claimed are found by scanning the names.
+func (s *UUIDServer) serviceIDOwner(ctx context.Context, ns *namespace, serviceID int) (string, error) {
+	r, err := s.etcdWrap.Get(ctx, ns.key(KeyOfServiceIDDir, strconv.Itoa(serviceID)))
+	if err == nil {
+		return r.Node.Value, nil
+	}
+	if !s.etcdWrap.IsKeyNotFound(err) {
+		return "", err
+	}
+
+	nodes, err := s.etcdWrap.List(ctx, ns.key(KeyOfServiceDir))
+	if err != nil {
+		return "", err
+	}
+	for _, node := range nodes {
+		id, _, err := parseServiceRecord(node.Value)
+		if err == nil && id == serviceID {
+			return nameOfKey(node.Key), nil
+		}
+	}
+	return "", nil
+}
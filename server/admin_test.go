@@ -0,0 +1,174 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServiceRegistry(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	// pin the ID the next automatic assignment would get
+	info, err := s.RegisterService(ctx, &api.RegisterServiceRequest{ServiceName: "order", ServiceId: StartOfServerID + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ServiceId != StartOfServerID+1 || info.CanonicalName != "order" {
+		t.Fatalf("unexpected info %v", info)
+	}
+	if _, err := s.RegisterService(ctx, &api.RegisterServiceRequest{ServiceName: "other", ServiceId: StartOfServerID + 1}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("pin of a taken ID: want AlreadyExists, got %v", err)
+	}
+
+	user, err := s.getServieID(ctx, s.namespaces[""], "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != StartOfServerID+2 {
+		t.Fatalf("automatic ID must skip the pinned one, got %v", user)
+	}
+	if _, err := s.RegisterService(ctx, &api.RegisterServiceRequest{ServiceName: "other", ServiceId: int32(user)}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("pin of an automatic ID: want AlreadyExists, got %v", err)
+	}
+
+	info, err = s.AddAlias(ctx, &api.AddAliasRequest{Alias: "orders", ServiceName: "order"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CanonicalName != "order" {
+		t.Fatalf("unexpected canonical name %v", info.CanonicalName)
+	}
+	if id, err := s.getServieID(ctx, s.namespaces[""], "orders"); err != nil || id != StartOfServerID+1 {
+		t.Fatalf("alias must resolve to the pinned ID, got %v, %v", id, err)
+	}
+
+	if _, err := s.RetireService(ctx, &api.RetireServiceRequest{ServiceName: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.getServieID(ctx, s.namespaces[""], "user"); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("fetch of a retired name: want FailedPrecondition, got %v", err)
+	}
+	if _, err := s.RegisterService(ctx, &api.RegisterServiceRequest{ServiceName: "user", ServiceId: 100}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("register of a retired name: want AlreadyExists, got %v", err)
+	}
+
+	info, err = s.GetService(ctx, &api.GetServiceRequest{ServiceName: "orders"})
+	if err != nil || info.ServiceId != StartOfServerID+1 || info.CanonicalName != "order" || info.Retired {
+		t.Fatalf("unexpected info of the alias %v, %v", info, err)
+	}
+	if info, err = s.GetService(ctx, &api.GetServiceRequest{ServiceName: "user"}); err != nil || !info.Retired {
+		t.Fatalf("unexpected info of the retired name %v, %v", info, err)
+	}
+	if _, err := s.GetService(ctx, &api.GetServiceRequest{ServiceName: "nobody"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("unknown name: want NotFound, got %v", err)
+	}
+
+	// retiring the canonical name retires its aliases, a retired name takes no aliases
+	if _, err := s.RetireService(ctx, &api.RetireServiceRequest{ServiceName: "order"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"order", "orders"} {
+		if _, err := s.getServieID(ctx, s.namespaces[""], name); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("fetch of %q after the retirement: want FailedPrecondition, got %v", name, err)
+		}
+	}
+	if _, err := s.AddAlias(ctx, &api.AddAliasRequest{Alias: "orders2", ServiceName: "order"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("alias of a retired name: want FailedPrecondition, got %v", err)
+	}
+}
+
+func TestRegistrationPolicy(t *testing.T) {
+	s := newTestServer(t, &Config{RegistrationPolicy: PolicyAllowlist, ServiceAllowlist: []string{"order", "user-[a-z]+"}})
+	for name, ok := range map[string]bool{"order": true, "user-login": true, "orders": false, "user-": false, "typo": false} {
+		_, err := s.getServieID(context.Background(), s.namespaces[""], name)
+		if ok && err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if !ok && status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("%q: want PermissionDenied, got %v", name, err)
+		}
+	}
+
+	s.cfg.RegistrationPolicy = PolicyRegistered
+	if _, err := s.getServieID(context.Background(), s.namespaces[""], "user-other"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("want PermissionDenied, got %v", err)
+	}
+	// names that already have an ID keep working
+	if _, err := s.getServieID(context.Background(), s.namespaces[""], "order"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RegisterService(context.Background(), &api.RegisterServiceRequest{ServiceName: "pay", ServiceId: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.getServieID(context.Background(), s.namespaces[""], "pay"); err != nil || id != 100 {
+		t.Fatalf("registered service: got %v, %v", id, err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	reply, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: "order", ContainerName: "c1", NeedCount: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := reply.Items[0]
+	if _, err := s.AddAlias(ctx, &api.AddAliasRequest{Alias: "orders", ServiceName: "order"}); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := s.ListServices(ctx, &api.ListRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services.Services) != 2 || services.Services[1].Name != "orders" || services.Services[1].CanonicalName != "order" ||
+		services.Services[1].ServiceId != item.ServiceId {
+		t.Fatalf("unexpected services %v", services.Services)
+	}
+
+	watermarks, err := s.ListWatermarks(ctx, &api.ListRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watermarks.Watermarks) != 1 {
+		t.Fatalf("unexpected watermarks %v", watermarks.Watermarks)
+	}
+	w := watermarks.Watermarks[0]
+	if w.ServiceName != "order" || w.ContainerName != "c1" || w.NextSequence != item.SequenceIdEnd+1 ||
+		w.UsedPercent <= 0 || w.UsedPercent >= 1 {
+		t.Fatalf("unexpected watermark %v", w)
+	}
+
+	if err := s.ReassignContainerID("c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReassignContainerID("c1"); err != nil {
+		t.Fatal(err)
+	}
+	containers, err := s.ListContainers(ctx, &api.ListRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers.Containers) != 1 || containers.Containers[0].ContainerId != item.ContainerId+2 {
+		t.Fatalf("unexpected containers %v", containers.Containers)
+	}
+
+	reassignments, err := s.ListReassignments(ctx, &api.ListRequest{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reassignments.Reassignments) != 1 {
+		t.Fatalf("unexpected reassignments %v", reassignments.Reassignments)
+	}
+	r := reassignments.Reassignments[0]
+	if r.ContainerName != "c1" || r.OldContainerId != item.ContainerId+1 || r.NewContainerId != item.ContainerId+2 || r.Time == 0 {
+		t.Fatalf("the newest reassignment must come first, got %v", r)
+	}
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator verifies the bearer token of a request and returns the identity of the caller.
+type Authenticator interface {
+	Authenticate(token string) (identity string, err error)
+}
+
+// StaticTokens an Authenticator with a fixed set of tokens, mapped from token to identity.
+type StaticTokens map[string]string
+
+// LoadStaticTokens load a token file with one "identity token" pair per line.
+// Empty lines and lines starting with # are skipped.
+func LoadStaticTokens(file string) (StaticTokens, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tokens := make(StaticTokens)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%v:%d: want \"identity token\"", file, line)
+		}
+		tokens[fields[1]] = fields[0]
+	}
+	return tokens, scanner.Err()
+}
+
+// Authenticate implements Authenticator.
+func (t StaticTokens) Authenticate(token string) (string, error) {
+	identity, ok := t[token]
+	if !ok {
+		return "", fmt.Errorf("unknown token")
+	}
+	return identity, nil
+}
+
+// JWTAuthenticator an Authenticator for JWTs signed by a local key.
+// The identity is the subject of the token.
+type JWTAuthenticator struct {
+	key interface{}
+}
+
+// NewJWTAuthenticator load the key that verifies the tokens. A PEM public key or
+// certificate verifies RSA and ECDSA signatures, anything else is an HMAC secret.
+func NewJWTAuthenticator(keyFile string) (*JWTAuthenticator, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		secret := bytes.TrimSpace(data)
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("empty JWT key in %v", keyFile)
+		}
+		return &JWTAuthenticator{key: secret}, nil
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &JWTAuthenticator{key: key}, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &JWTAuthenticator{key: cert.PublicKey}, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q in %v", block.Type, keyFile)
+}
+
+// Authenticate implements Authenticator.
+func (a *JWTAuthenticator) Authenticate(token string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		switch a.key.(type) {
+		case []byte:
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+				return a.key, nil
+			}
+		default:
+			switch t.Method.(type) {
+			case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+				return a.key, nil
+			}
+		}
+		return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(claims.Subject) == 0 {
+		return "", fmt.Errorf("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// multiAuthenticator tries the authenticators in order.
+type multiAuthenticator []Authenticator
+
+func (m multiAuthenticator) Authenticate(token string) (identity string, err error) {
+	for _, a := range m {
+		identity, err = a.Authenticate(token)
+		if err == nil {
+			return identity, nil
+		}
+	}
+	return "", err
+}
+
+// ParseACL parse the service name patterns of an identity written as "identity=pattern,pattern".
+func ParseACL(spec string) (string, []string, error) {
+	i := strings.Index(spec, "=")
+	if i <= 0 || i == len(spec)-1 {
+		return "", nil, fmt.Errorf("invalid ACL %q, want identity=pattern[,pattern]", spec)
+	}
+	return spec[:i], strings.Split(spec[i+1:], ","), nil
+}
+
+// adminMethodPrefix the prefix of the full method names of the admin service.
+const adminMethodPrefix = "/api.Admin/"
+
+// healthMethodPrefix the prefix of the full method names of the health service,
+// load balancers and orchestrators check it without a token.
+const healthMethodPrefix = "/grpc.health.v1.Health/"
+
+type identityKey struct{}
+
+// IdentityFromContext returns the identity of the caller authenticated by the server.
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(identityKey{}).(string)
+	return identity, ok
+}
+
+func (s *UUIDServer) initAuth() error {
+	var auths multiAuthenticator
+	if s.cfg.Authenticator != nil {
+		auths = append(auths, s.cfg.Authenticator)
+	}
+	if len(s.cfg.AuthTokenFile) > 0 {
+		tokens, err := LoadStaticTokens(s.cfg.AuthTokenFile)
+		if err != nil {
+			return err
+		}
+		auths = append(auths, tokens)
+	}
+	if len(s.cfg.AuthJWTKeyFile) > 0 {
+		a, err := NewJWTAuthenticator(s.cfg.AuthJWTKeyFile)
+		if err != nil {
+			return err
+		}
+		auths = append(auths, a)
+	}
+	if len(auths) > 0 {
+		s.authenticator = auths
+	}
+	if len(s.cfg.AdminTokenFile) > 0 {
+		tokens, err := LoadStaticTokens(s.cfg.AdminTokenFile)
+		if err != nil {
+			return err
+		}
+		s.adminAuthenticator = tokens
+	}
+
+	s.acl = make(map[string]namePatterns)
+	for identity, patterns := range s.cfg.ACL {
+		p, err := compileNamePatterns(patterns)
+		if err != nil {
+			return fmt.Errorf("ACL of %q: %v", identity, err)
+		}
+		s.acl[identity] = p
+	}
+	if len(s.acl) > 0 && s.authenticator == nil {
+		return fmt.Errorf("ACL needs an authenticator")
+	}
+	return nil
+}
+
+// authenticate puts the identity of the bearer token of a gRPC call into the context.
+// The admin service takes only the admin tokens and is closed if there are none,
+// the health service needs no token.
+func (s *UUIDServer) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authorization := ""
+	if values := md.Get("authorization"); len(values) > 0 {
+		authorization = values[0]
+	}
+	if strings.HasPrefix(method, healthMethodPrefix) {
+		return ctx, nil
+	}
+	if strings.HasPrefix(method, adminMethodPrefix) {
+		if s.adminAuthenticator == nil {
+			return nil, status.Error(codes.Unauthenticated, "the admin service is disabled, the server has no admin tokens")
+		}
+		return authenticateWith(ctx, s.adminAuthenticator, authorization)
+	}
+	return s.authenticateBearer(ctx, authorization)
+}
+
+// authenticateBearer puts the identity of an authorization value "Bearer <token>" into the context.
+func (s *UUIDServer) authenticateBearer(ctx context.Context, authorization string) (context.Context, error) {
+	return authenticateWith(ctx, s.authenticator, authorization)
+}
+
+func authenticateWith(ctx context.Context, authenticator Authenticator, authorization string) (context.Context, error) {
+	if authenticator == nil {
+		return ctx, nil
+	}
+	if len(authorization) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	const bearer = "bearer "
+	if len(authorization) <= len(bearer) || !strings.EqualFold(authorization[:len(bearer)], bearer) {
+		return nil, status.Error(codes.Unauthenticated, "authorization is not a bearer token")
+	}
+	identity, err := authenticator.Authenticate(authorization[len(bearer):])
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	return context.WithValue(ctx, identityKey{}, identity), nil
+}
+
+func (s *UUIDServer) authUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	authCtx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		attrs := []slog.Attr{slog.String("method", info.FullMethod), slog.String("error", err.Error())}
+		if p, ok := peer.FromContext(ctx); ok {
+			attrs = append(attrs, slog.String("peer", p.Addr.String()))
+		}
+		s.logger().LogAttrs(ctx, slog.LevelWarn, "authentication failed", attrs...)
+		return nil, err
+	}
+	return handler(authCtx, req)
+}
+
+// authorize checks that the caller may use the service name.
+func (s *UUIDServer) authorize(ctx context.Context, serviceName string) error {
+	if len(s.acl) == 0 {
+		return nil
+	}
+	identity, _ := IdentityFromContext(ctx)
+	if !s.acl[identity].Match(serviceName) && !s.acl[AnyService].Match(serviceName) {
+		return status.Errorf(codes.PermissionDenied, "%q may not use service %q", identity, serviceName)
+	}
+	return nil
+}
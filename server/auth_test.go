@@ -0,0 +1,137 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/client"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flake-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "tokens")
+	err = ioutil.WriteFile(tokenFile, []byte("# identity token\norders s3cret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("jwt-secret")
+	keyFile := filepath.Join(dir, "jwt.key")
+	if err := ioutil.WriteFile(keyFile, secret, 0600); err != nil {
+		t.Fatal(err)
+	}
+	signed := func(subject string, key []byte) string {
+		claims := jwt.RegisteredClaims{Subject: subject, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	adminFile := filepath.Join(dir, "admin")
+	if err := ioutil.WriteFile(adminFile, []byte("ops adm1n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, &Config{AuthTokenFile: tokenFile, AuthJWTKeyFile: keyFile, AdminTokenFile: adminFile,
+		ACL: map[string][]string{"orders": {"order-.*"}, "billing": {"bill"}, AnyService: {"public"}}})
+	opts, err := s.serverOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(opts...)
+	api.RegisterUUIDServer(grpcServer, s)
+	api.RegisterAdminServer(grpcServer, s)
+	go grpcServer.Serve(listen)
+	defer grpcServer.Stop()
+
+	tests := []struct {
+		token   string
+		service string
+		code    codes.Code
+	}{
+		{"", "order-a", codes.Unauthenticated},
+		{"wrong", "order-a", codes.Unauthenticated},
+		{"s3cret", "order-a", codes.OK},
+		{"s3cret", "public", codes.OK},
+		{"s3cret", "bill", codes.PermissionDenied},
+		{signed("billing", secret), "bill", codes.OK},
+		{signed("billing", secret), "order-a", codes.PermissionDenied},
+		{signed("billing", []byte("other")), "bill", codes.Unauthenticated},
+		{"adm1n", "order-a", codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		c, err := client.NewClient(&client.Config{Endpoint: listen.Addr().String(), Token: tt.token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.GenUUID(tt.service)
+		c.Close()
+		if status.Code(err) != tt.code {
+			t.Errorf("token %q, service %q: want %v, got %v", tt.token, tt.service, tt.code, err)
+		}
+	}
+
+	// the admin service takes only the admin tokens
+	for token, code := range map[string]codes.Code{"": codes.Unauthenticated, "s3cret": codes.Unauthenticated, "adm1n": codes.OK} {
+		conn, err := client.Dial(&client.Config{Endpoint: listen.Addr().String(), Token: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = api.NewAdminClient(conn).ListServices(context.Background(), &api.ListRequest{})
+		conn.Close()
+		if status.Code(err) != code {
+			t.Errorf("admin token %q: want %v, got %v", token, code, err)
+		}
+	}
+}
+
+func TestAdminClosed(t *testing.T) {
+	// without admin tokens the admin service is refused, with or without other tokens
+	s := newTestServer(t, nil)
+	opts, err := s.serverOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(opts...)
+	api.RegisterUUIDServer(grpcServer, s)
+	api.RegisterAdminServer(grpcServer, s)
+	go grpcServer.Serve(listen)
+	defer grpcServer.Stop()
+
+	conn, err := client.Dial(&client.Config{Endpoint: listen.Addr().String(), Token: "any"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := api.NewAdminClient(conn).ListServices(context.Background(), &api.ListRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("admin without admin tokens: want Unauthenticated, got %v", err)
+	}
+	if _, err := api.NewUUIDClient(conn).Fetch(context.Background(), &api.FetchRequest{ServiceName: "order", NeedCount: 1}); err != nil {
+		t.Fatalf("fetch without auth: %v", err)
+	}
+}
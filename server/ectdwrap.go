@@ -1,11 +1,18 @@
 package server
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/cnwinds/flake/util"
 
 	"github.com/coreos/etcd/client"
 	"github.com/coreos/etcd/version"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 )
 
@@ -18,6 +25,44 @@ type EtcdWrapConfig struct {
 	// Password is the password for the specified user to add as an authorization header
 	// to the request.
 	Password string
+	// CAFile the CA that signs the certificates of the etcd servers, the system roots are used if it is empty.
+	CAFile string
+	// CertFile the client certificate for etcd servers that require one.
+	CertFile string
+	// KeyFile the private key of CertFile.
+	KeyFile string
+	// Logger the logger of the wrap, slog.Default() is used if it is nil.
+	Logger *slog.Logger
+	// TracerProvider traces the etcd requests, the global provider is used if it is nil.
+	TracerProvider trace.TracerProvider
+}
+
+// String hides the password when the config is logged.
+func (cfg EtcdWrapConfig) String() string {
+	if len(cfg.Password) > 0 {
+		cfg.Password = "******"
+	}
+	type plain EtcdWrapConfig
+	return fmt.Sprintf("%+v", plain(cfg))
+}
+
+func (cfg *EtcdWrapConfig) transport() (client.CancelableTransport, error) {
+	if len(cfg.CAFile) == 0 && len(cfg.CertFile) == 0 {
+		return client.DefaultTransport, nil
+	}
+	tlsCfg, err := util.ClientTLSConfig(cfg.CAFile, cfg.CertFile, cfg.KeyFile, "")
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsCfg,
+	}, nil
 }
 
 // EtcdWrap Encapsulation of etcd
@@ -30,9 +75,18 @@ type EtcdWrap struct {
 // NewEtcdWrap create a new etcd wrap.
 func NewEtcdWrap(cfg *EtcdWrapConfig) (w *EtcdWrap, err error) {
 	w = &EtcdWrap{cfg: cfg}
-	log.Printf("etcd wrap config: %v", cfg)
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info("etcd wrap config", "config", cfg)
+	transport, err := cfg.transport()
+	if err != nil {
+		return nil, err
+	}
 	etcdCfg := client.Config{
 		Endpoints: cfg.Endpoints,
+		Transport: transport,
 		Username:  cfg.UserName,
 		Password:  cfg.Password,
 	}
@@ -41,22 +95,43 @@ func NewEtcdWrap(cfg *EtcdWrapConfig) (w *EtcdWrap, err error) {
 		return nil, err
 	}
 
-	w.etcdAPI = client.NewKeysAPI(w.etcdClient)
+	w.etcdAPI = newInstrumentedKeys(client.NewKeysAPI(w.etcdClient), cfg.TracerProvider)
 	return w, nil
 }
 
 // GetVersion retrieves the current etcd server and cluster version.
-func (w *EtcdWrap) GetVersion() (*version.Versions, error) {
-	return w.etcdClient.GetVersion(context.Background())
+func (w *EtcdWrap) GetVersion(ctx context.Context) (*version.Versions, error) {
+	return w.etcdClient.GetVersion(ctx)
+}
+
+// Probe check the credentials can write, read and delete the temporary key.
+func (w *EtcdWrap) Probe(ctx context.Context, key string) error {
+	value := strconv.FormatInt(time.Now().UnixNano(), 10)
+	_, err := w.etcdAPI.Set(ctx, key, value, &client.SetOptions{PrevExist: client.PrevNoExist, TTL: time.Minute})
+	if err != nil {
+		return fmt.Errorf("can not write %v: %v", key, err)
+	}
+	r, err := w.etcdAPI.Get(ctx, key, nil)
+	if err != nil {
+		return fmt.Errorf("can not read %v: %v", key, err)
+	}
+	if r.Node.Value != value {
+		return fmt.Errorf("read %q from %v, want %q", r.Node.Value, key, value)
+	}
+	_, err = w.etcdAPI.Delete(ctx, key, nil)
+	if err != nil {
+		return fmt.Errorf("can not delete %v: %v", key, err)
+	}
+	return nil
 }
 
 // GetNCreate retrieves a set of Nodes from etcd, created if not present.
-func (w *EtcdWrap) GetNCreate(key string, createValue int) (*client.Response, error) {
+func (w *EtcdWrap) GetNCreate(ctx context.Context, key string, createValue int) (*client.Response, error) {
 	for {
-		r, err := w.etcdAPI.Get(context.Background(), key, nil)
+		r, err := w.etcdAPI.Get(ctx, key, nil)
 		if err != nil {
 			if client.IsKeyNotFound(err) {
-				r, err := w.etcdAPI.Set(context.Background(), key, strconv.Itoa(createValue), &client.SetOptions{PrevExist: "false"})
+				r, err := w.etcdAPI.Set(ctx, key, strconv.Itoa(createValue), &client.SetOptions{PrevExist: "false"})
 				if err != nil {
 					// recreate
 					continue
@@ -72,17 +147,18 @@ func (w *EtcdWrap) GetNCreate(key string, createValue int) (*client.Response, er
 }
 
 // AtomAdd add value to the value atom of key.
-func (w *EtcdWrap) AtomAdd(key string, value int) (int, error) {
+func (w *EtcdWrap) AtomAdd(ctx context.Context, key string, value int) (int, error) {
 	for {
-		r, err := w.etcdAPI.Get(context.Background(), key, nil)
+		r, err := w.etcdAPI.Get(ctx, key, nil)
 		if err != nil {
 			return 0, err
 		}
 		v1, err := strconv.Atoi(r.Node.Value)
 		v2 := strconv.Itoa(v1 + value)
-		resp, err := w.etcdAPI.Set(context.Background(), key, v2, &client.SetOptions{PrevIndex: r.Node.ModifiedIndex})
+		resp, err := w.etcdAPI.Set(ctx, key, v2, &client.SetOptions{PrevIndex: r.Node.ModifiedIndex})
 		if err != nil {
 			// modify conflict, again
+			countConflict("atom_add", err)
 			continue
 		}
 		return strconv.Atoi(resp.Node.Value)
@@ -90,8 +166,8 @@ func (w *EtcdWrap) AtomAdd(key string, value int) (int, error) {
 }
 
 // Get retrieves a set of Nodes from etcd
-func (w *EtcdWrap) Get(key string) (*client.Response, error) {
-	r, err := w.etcdAPI.Get(context.Background(), key, nil)
+func (w *EtcdWrap) Get(ctx context.Context, key string) (*client.Response, error) {
+	r, err := w.etcdAPI.Get(ctx, key, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -99,20 +175,64 @@ func (w *EtcdWrap) Get(key string) (*client.Response, error) {
 }
 
 // Set assigns a new value to a Node identified by a given key.
-func (w *EtcdWrap) Set(key string, value string, opts *client.SetOptions) (*client.Response, error) {
-	r, err := w.etcdAPI.Set(context.Background(), key, value, opts)
+func (w *EtcdWrap) Set(ctx context.Context, key string, value string, opts *client.SetOptions) (*client.Response, error) {
+	r, err := w.etcdAPI.Set(ctx, key, value, opts)
 	return r, err
 }
 
 // Delete removes a Node identified by the given key.
-func (w *EtcdWrap) Delete(key string) (*client.Response, error) {
-	reps, err := w.etcdAPI.Delete(context.Background(), key, nil)
+func (w *EtcdWrap) Delete(ctx context.Context, key string) (*client.Response, error) {
+	reps, err := w.etcdAPI.Delete(ctx, key, nil)
 	if err != nil {
 		return nil, err
 	}
 	return reps, nil
 }
 
+// DeleteDir removes a directory with every Node under it, a missing directory is no error.
+func (w *EtcdWrap) DeleteDir(ctx context.Context, key string) error {
+	_, err := w.etcdAPI.Delete(ctx, key, &client.DeleteOptions{Recursive: true, Dir: true})
+	if err != nil && !client.IsKeyNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteEmptyDir removes a directory that holds no Node, a missing or not empty directory is no error.
+func (w *EtcdWrap) DeleteEmptyDir(ctx context.Context, key string) error {
+	_, err := w.etcdAPI.Delete(ctx, key, &client.DeleteOptions{Dir: true})
+	if err != nil && !client.IsKeyNotFound(err) {
+		if e, ok := err.(client.Error); ok && (e.Code == client.ErrorCodeDirNotEmpty || e.Code == client.ErrorCodeNotDir) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// List retrieves the children of the directory identified by the given key.
+// A missing directory is returned as an empty list.
+func (w *EtcdWrap) List(ctx context.Context, key string) (client.Nodes, error) {
+	r, err := w.etcdAPI.Get(ctx, key, &client.GetOptions{Sort: true})
+	if err != nil {
+		if client.IsKeyNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.Node.Nodes, nil
+}
+
+// CreateInOrder creates a Node with an increasing key in the directory, it expires after ttl unless ttl is zero.
+func (w *EtcdWrap) CreateInOrder(ctx context.Context, dir string, value string, ttl time.Duration) (*client.Response, error) {
+	return w.etcdAPI.CreateInOrder(ctx, dir, value, &client.CreateInOrderOptions{TTL: ttl})
+}
+
+// CompareAndDelete removes a Node only if it was not modified since prevIndex.
+func (w *EtcdWrap) CompareAndDelete(ctx context.Context, key string, prevIndex uint64) (*client.Response, error) {
+	return w.etcdAPI.Delete(ctx, key, &client.DeleteOptions{PrevIndex: prevIndex})
+}
+
 // IsKeyExist returns true if the error code is ErrorCodeNodeExist.
 func (w *EtcdWrap) IsKeyExist(err error) bool {
 	if cErr, ok := err.(client.Error); ok {
@@ -121,6 +241,14 @@ func (w *EtcdWrap) IsKeyExist(err error) bool {
 	return false
 }
 
+// IsCompareFailed returns true if the error code is ErrorCodeTestFailed.
+func (w *EtcdWrap) IsCompareFailed(err error) bool {
+	if cErr, ok := err.(client.Error); ok {
+		return cErr.Code == client.ErrorCodeTestFailed
+	}
+	return false
+}
+
 // IsKeyNotFound returns true if the error code is ErrorCodeKeyNotFound.
 func (w *EtcdWrap) IsKeyNotFound(err error) bool {
 	return client.IsKeyNotFound(err)
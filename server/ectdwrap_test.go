@@ -0,0 +1,46 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// readOnlyKeys rejects every write like etcd does for a user without write permission.
+type readOnlyKeys struct {
+	*memKeys
+}
+
+func (r readOnlyKeys) Set(ctx context.Context, key, value string, opts *client.SetOptions) (*client.Response, error) {
+	return nil, client.Error{Code: 110, Message: "The request requires user authentication", Cause: "Insufficient credentials"}
+}
+
+func TestCheckStore(t *testing.T) {
+	s := newTestServer(t, &Config{Namespaces: []NamespaceConfig{{Name: "billing", Prefix: "/billing"}}})
+	if err := s.checkStore(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.etcdWrap.Get(context.Background(), "/flake/"+KeyOfProbeDir)
+	if err == nil && len(r.Node.Nodes) > 0 {
+		t.Fatalf("probe keys are left behind: %v", r.Node.Nodes)
+	}
+
+	s.etcdWrap.etcdAPI = readOnlyKeys{s.etcdWrap.etcdAPI.(*memKeys)}
+	err = s.checkStore(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "can not write") {
+		t.Fatalf("want a write error, got %v", err)
+	}
+}
+
+func TestConfigRedaction(t *testing.T) {
+	cfg := Config{UserName: "flake", Password: "s3cret"}
+	if strings.Contains(cfg.String(), "s3cret") || !strings.Contains(cfg.String(), "flake") {
+		t.Fatalf("password is not hidden: %v", cfg.String())
+	}
+	wrapCfg := EtcdWrapConfig{Password: "s3cret"}
+	if strings.Contains(wrapCfg.String(), "s3cret") {
+		t.Fatalf("password is not hidden: %v", wrapCfg.String())
+	}
+}
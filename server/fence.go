@@ -0,0 +1,522 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// KeyOfStoreID holds a random ID written when the namespace is created, a new ID means the keys were wiped.
+	KeyOfStoreID = "store_id"
+	// KeyOfEpoch holds the epoch bumped by AckEpoch, under the prefix of the default namespace.
+	KeyOfEpoch = "epoch"
+
+	// stateSaveInterval how often the local state is written while it changes.
+	stateSaveInterval = time.Second
+)
+
+// localState the high-water record kept in Config.StateFile. The store must never
+// be behind it, otherwise the store was wiped or rolled back and IDs would be issued twice.
+type localState struct {
+	// Epoch the epoch of the store when the record was taken.
+	Epoch int64 `json:"epoch"`
+	// ClusterID the ID of the etcd cluster, a new cluster is empty.
+	ClusterID string `json:"cluster_id"`
+	// Index the highest etcd index seen.
+	Index uint64 `json:"index"`
+	// Namespaces the record of each namespace by name.
+	Namespaces map[string]*namespaceState `json:"namespaces"`
+}
+
+type namespaceState struct {
+	StoreID string `json:"store_id"`
+	// Counters the highest max_serviceid and max_containerid seen.
+	Counters map[string]int `json:"counters"`
+	// Watermarks the highest value of each "<service ID>:<container ID>" key written
+	// by this instance since the epoch, the store holds the ones before.
+	Watermarks map[string]int `json:"watermarks"`
+}
+
+// fenceState the local state and the reason the server refuses to issue IDs.
+type fenceState struct {
+	lock sync.Mutex
+	// save serializes the writes of the state file, so an older copy never replaces a newer one
+	save   sync.Mutex
+	state  *localState
+	dirty  bool
+	err    error
+	stopCh chan struct{}
+}
+
+func (l *localState) namespace(name string) *namespaceState {
+	if l.Namespaces == nil {
+		l.Namespaces = make(map[string]*namespaceState)
+	}
+	ns, ok := l.Namespaces[name]
+	if !ok {
+		ns = &namespaceState{Counters: make(map[string]int), Watermarks: make(map[string]int)}
+		l.Namespaces[name] = ns
+	}
+	return ns
+}
+
+// clone returns a copy of the state that can be written without the lock.
+func (l *localState) clone() *localState {
+	c := *l
+	c.Namespaces = make(map[string]*namespaceState, len(l.Namespaces))
+	for name, ns := range l.Namespaces {
+		c.Namespaces[name] = &namespaceState{StoreID: ns.StoreID, Counters: cloneValues(ns.Counters), Watermarks: cloneValues(ns.Watermarks)}
+	}
+	return &c
+}
+
+func cloneValues(m map[string]int) map[string]int {
+	c := make(map[string]int, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// values returns the counters or the watermarks the key belongs to.
+func (ns *namespaceState) values(name string) map[string]int {
+	if name == KeyOfMaxServiceID || name == KeyOfMaxContainerID {
+		return ns.Counters
+	}
+	return ns.Watermarks
+}
+
+func loadState(file string) (*localState, error) {
+	state := &localState{}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("state file %v: %v", file, err)
+	}
+	return state, nil
+}
+
+// saveState writes the state to a temporary file and renames it, so a crash leaves the old or the new state.
+func saveState(file string, state *localState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// storeEpoch reads the epoch of the store, 0 if it was never bumped.
+func storeEpoch(ctx context.Context, w *EtcdWrap, ns *namespace) (int64, uint64, error) {
+	r, err := w.Get(ctx, ns.key(KeyOfEpoch))
+	if err != nil {
+		if cErr, ok := err.(client.Error); ok && cErr.Code == client.ErrorCodeKeyNotFound {
+			return 0, cErr.Index, nil
+		}
+		return 0, 0, err
+	}
+	epoch, err := strconv.ParseInt(r.Node.Value, 10, 64)
+	return epoch, r.Index, err
+}
+
+// AckEpoch accept the current state of the store after it was found wiped or rolled
+// back. The store is raised to the local records in stateFiles first, they must be
+// the records of every server, then the epoch is bumped and the servers start to
+// serve again. A server that starts first so never issues the IDs another one did.
+func AckEpoch(ctx context.Context, cfg *Config, stateFiles []string) (int64, error) {
+	s := &UUIDServer{cfg: cfg}
+	if err := s.initNamespaces(); err != nil {
+		return 0, err
+	}
+	w, err := NewEtcdWrap(cfg.etcdWrapConfig())
+	if err != nil {
+		return 0, err
+	}
+	s.etcdWrap = w
+	return s.ackStates(ctx, stateFiles)
+}
+
+// ackStates raises the store to the local records in stateFiles and bumps the epoch.
+func (s *UUIDServer) ackStates(ctx context.Context, stateFiles []string) (int64, error) {
+	if len(stateFiles) == 0 {
+		return 0, errors.New("the state file of every server is needed, the store is raised to them before the epoch is bumped")
+	}
+	for _, file := range stateFiles {
+		// a missing file would be taken as an empty record
+		if _, err := os.Stat(file); err != nil {
+			return 0, err
+		}
+		state, err := loadState(file)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.restoreState(ctx, state); err != nil {
+			return 0, fmt.Errorf("state file %v: %v", file, err)
+		}
+	}
+	return ackEpoch(ctx, s.etcdWrap, s.namespaces[""])
+}
+
+// ackEpoch bumps the epoch kept in the default namespace ns.
+func ackEpoch(ctx context.Context, w *EtcdWrap, ns *namespace) (int64, error) {
+	for {
+		epoch, _, err := storeEpoch(ctx, w, ns)
+		if err != nil {
+			return 0, err
+		}
+		// the time is above the epoch of every local record, also after a wipe
+		next := time.Now().UnixNano()
+		if next <= epoch {
+			next = epoch + 1
+		}
+		opts := &client.SetOptions{PrevExist: client.PrevNoExist}
+		if epoch > 0 {
+			opts = &client.SetOptions{PrevValue: strconv.FormatInt(epoch, 10)}
+		}
+		_, err = w.Set(ctx, ns.key(KeyOfEpoch), strconv.FormatInt(next, 10), opts)
+		if err != nil {
+			if w.IsKeyExist(err) || w.IsCompareFailed(err) {
+				continue
+			}
+			return 0, err
+		}
+		return next, nil
+	}
+}
+
+// initFence compares the store with the local state and refuses to serve if the
+// store is behind it. A bumped epoch accepts the store, AckEpoch raised it to the
+// records of every server, the keys are raised to the local record again.
+func (s *UUIDServer) initFence(ctx context.Context) error {
+	if len(s.cfg.StateFile) == 0 {
+		return nil
+	}
+	state, err := loadState(s.cfg.StateFile)
+	if err != nil {
+		return err
+	}
+	epoch, index, err := storeEpoch(ctx, s.etcdWrap, s.namespaces[""])
+	if err != nil {
+		return err
+	}
+
+	if epoch > state.Epoch && len(state.Namespaces) > 0 {
+		s.logger().Warn("flake epoch acknowledged, raising the store to the local state", "epoch", epoch, "local_epoch", state.Epoch)
+		if err := s.restoreState(ctx, state); err != nil {
+			return err
+		}
+		// the store is accepted as it is now, it holds the watermarks of the old epoch
+		state.Index, state.ClusterID = 0, ""
+		for _, nsState := range state.Namespaces {
+			nsState.StoreID = ""
+			nsState.Watermarks = make(map[string]int)
+		}
+	} else {
+		var problems []error
+		if epoch < state.Epoch {
+			problems = append(problems, fmt.Errorf("epoch %d is below %d", epoch, state.Epoch))
+		}
+		if index < state.Index {
+			problems = append(problems, fmt.Errorf("etcd index %d is below %d", index, state.Index))
+		}
+		for _, name := range sortedNamespaces(state.Namespaces) {
+			if ns, ok := s.namespaces[name]; ok {
+				problems = append(problems, s.compareState(ctx, ns, state)...)
+			}
+		}
+		if len(problems) > 0 {
+			err := fmt.Errorf("refusing to serve, the store was wiped or rolled back since the state in %v was saved: %v; "+
+				"check the store, then run \"flake fence ack\" to accept it", s.cfg.StateFile, errors.Join(problems...))
+			s.logger().Error("flake fence", "error", err)
+			return err
+		}
+	}
+
+	state.Epoch = epoch
+	if index > state.Index {
+		state.Index = index
+	}
+	for _, ns := range s.namespaces {
+		nsState := state.namespace(ns.name)
+		var clusterID string
+		nsState.StoreID, clusterID, err = s.ensureStoreID(ctx, ns, nsState.StoreID)
+		if err != nil {
+			return err
+		}
+		if len(clusterID) > 0 {
+			state.ClusterID = clusterID
+		}
+	}
+	if err := saveState(s.cfg.StateFile, state); err != nil {
+		return err
+	}
+	s.fence.state = state
+	s.fence.stopCh = make(chan struct{})
+	return nil
+}
+
+// compareState returns the keys of the namespace that are behind the local state.
+func (s *UUIDServer) compareState(ctx context.Context, ns *namespace, state *localState) []error {
+	var problems []error
+	nsState := state.Namespaces[ns.name]
+	if len(nsState.StoreID) > 0 {
+		r, err := s.etcdWrap.Get(ctx, ns.key(KeyOfStoreID))
+		switch {
+		case err != nil && !s.etcdWrap.IsKeyNotFound(err):
+			return []error{err}
+		case err != nil:
+			problems = append(problems, fmt.Errorf("the store ID of namespace %q is missing", ns.name))
+		case r.Node.Value != nsState.StoreID:
+			problems = append(problems, fmt.Errorf("the store ID of namespace %q is %v, not %v", ns.name, r.Node.Value, nsState.StoreID))
+		case len(state.ClusterID) > 0 && len(r.ClusterID) > 0 && r.ClusterID != state.ClusterID:
+			problems = append(problems, fmt.Errorf("the etcd cluster ID is %v, not %v", r.ClusterID, state.ClusterID))
+		}
+	}
+	check := func(name string, want int) {
+		r, err := s.etcdWrap.Get(ctx, ns.key(name))
+		if err != nil {
+			if !s.etcdWrap.IsKeyNotFound(err) {
+				problems = append(problems, err)
+				return
+			}
+			problems = append(problems, fmt.Errorf("%v is missing, want at least %d", ns.key(name), want))
+			return
+		}
+		if value, err := strconv.Atoi(r.Node.Value); err != nil || value < want {
+			problems = append(problems, fmt.Errorf("%v is %v, want at least %d", ns.key(name), r.Node.Value, want))
+		}
+	}
+	for _, values := range []map[string]int{nsState.Counters, nsState.Watermarks} {
+		for _, name := range sortedNames(values) {
+			check(name, values[name])
+		}
+	}
+	return problems
+}
+
+// restoreState raises the counters and the watermarks of the store to the local state.
+func (s *UUIDServer) restoreState(ctx context.Context, state *localState) error {
+	for name, nsState := range state.Namespaces {
+		ns, ok := s.namespaces[name]
+		if !ok {
+			continue
+		}
+		for _, values := range []map[string]int{nsState.Counters, nsState.Watermarks} {
+			for key, value := range values {
+				if err := s.raiseKey(ctx, ns.key(key), value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// raiseKey sets a number key to value unless it is higher, a missing key is created.
+func (s *UUIDServer) raiseKey(ctx context.Context, key string, value int) error {
+	for {
+		r, err := s.etcdWrap.Get(ctx, key)
+		opts := &client.SetOptions{PrevExist: client.PrevNoExist}
+		if err == nil {
+			cur, err := strconv.Atoi(r.Node.Value)
+			if err != nil {
+				return fmt.Errorf("%v: %v", key, err)
+			}
+			if cur >= value {
+				return nil
+			}
+			opts = &client.SetOptions{PrevIndex: r.Node.ModifiedIndex}
+		} else if !s.etcdWrap.IsKeyNotFound(err) {
+			return err
+		}
+		_, err = s.etcdWrap.Set(ctx, key, strconv.Itoa(value), opts)
+		if err != nil {
+			if s.etcdWrap.IsKeyExist(err) || s.etcdWrap.IsCompareFailed(err) {
+				continue
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// ensureStoreID returns the store ID of the namespace and the etcd cluster ID, the
+// store ID is created if the namespace has none.
+func (s *UUIDServer) ensureStoreID(ctx context.Context, ns *namespace, want string) (string, string, error) {
+	id := want
+	if len(id) == 0 {
+		id = fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64())
+	}
+	r, err := s.etcdWrap.Set(ctx, ns.key(KeyOfStoreID), id, &client.SetOptions{PrevExist: client.PrevNoExist})
+	if err == nil {
+		return id, r.ClusterID, nil
+	}
+	if !s.etcdWrap.IsKeyExist(err) {
+		return "", "", err
+	}
+	r, err = s.etcdWrap.Get(ctx, ns.key(KeyOfStoreID))
+	if err != nil {
+		return "", "", err
+	}
+	return r.Node.Value, r.ClusterID, nil
+}
+
+// observeKey compares a counter or a watermark read from the store with the local
+// state, found is false if the key is missing. The server is fenced if the store is behind.
+func (s *UUIDServer) observeKey(ns *namespace, name string, value int, found bool) error {
+	if s.fence.state == nil {
+		return nil
+	}
+	s.fence.lock.Lock()
+	defer s.fence.lock.Unlock()
+	if s.fence.err != nil {
+		return s.fence.err
+	}
+	want, ok := s.fence.state.namespace(ns.name).values(name)[name]
+	if ok && (!found || value < want) {
+		s.fenceLocked(status.Errorf(codes.FailedPrecondition, "flake server is fenced, %v is %d in the store but %d was seen before, "+
+			"the store was wiped or rolled back", ns.key(name), value, want))
+		return s.fence.err
+	}
+	return nil
+}
+
+// fenceWith stops the server issuing IDs, err is returned to the callers.
+func (s *UUIDServer) fenceWith(err error) {
+	s.fence.lock.Lock()
+	defer s.fence.lock.Unlock()
+	if s.fence.err == nil {
+		s.fenceLocked(err)
+	}
+}
+
+func (s *UUIDServer) fenceLocked(err error) {
+	s.fence.err = err
+	s.logger().Error("flake fence", "error", err)
+	go s.publishHealth()
+}
+
+// recordKey raises the local state of a counter or a watermark after it was written.
+func (s *UUIDServer) recordKey(ns *namespace, name string, value int, index uint64) {
+	if s.fence.state == nil {
+		return
+	}
+	s.fence.lock.Lock()
+	defer s.fence.lock.Unlock()
+	values := s.fence.state.namespace(ns.name).values(name)
+	if value > values[name] {
+		values[name] = value
+		s.fence.dirty = true
+	}
+	if index > s.fence.state.Index {
+		s.fence.state.Index = index
+		s.fence.dirty = true
+	}
+}
+
+// fenceError returns why the server refuses to issue IDs, nil if it does not.
+func (s *UUIDServer) fenceError() error {
+	s.fence.lock.Lock()
+	defer s.fence.lock.Unlock()
+	return s.fence.err
+}
+
+// saveStateLoop writes the local state while it changes until stopFence is called.
+func (s *UUIDServer) saveStateLoop() {
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flushState()
+		case <-s.fence.stopCh:
+			return
+		}
+	}
+}
+
+func (s *UUIDServer) flushState() {
+	s.fence.save.Lock()
+	defer s.fence.save.Unlock()
+	s.fence.lock.Lock()
+	if !s.fence.dirty {
+		s.fence.lock.Unlock()
+		return
+	}
+	state := s.fence.state.clone()
+	s.fence.dirty = false
+	s.fence.lock.Unlock()
+
+	if err := saveState(s.cfg.StateFile, state); err != nil {
+		s.fence.lock.Lock()
+		s.fence.dirty = true
+		s.fence.lock.Unlock()
+		s.logger().Error("flake state", "file", s.cfg.StateFile, "error", err)
+	}
+}
+
+// stopFence writes the local state a last time.
+func (s *UUIDServer) stopFence() {
+	if s.fence.state == nil {
+		return
+	}
+	select {
+	case <-s.fence.stopCh:
+	default:
+		close(s.fence.stopCh)
+	}
+	s.flushState()
+}
+
+func sortedNamespaces(m map[string]*namespaceState) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedNames(m map[string]int) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flake-fence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &Config{StateFile: filepath.Join(dir, "state.json")}
+	ctx := context.Background()
+	fetch := func(s *UUIDServer) (*api.UUIDRange, error) {
+		reply, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: "order", ContainerName: "c1", NeedCount: 10})
+		if err != nil {
+			return nil, err
+		}
+		return reply.Items[0], nil
+	}
+	// restart starts a server on the store of s, a new store if s is nil
+	restart := func(s *UUIDServer) (*UUIDServer, error) {
+		svr := newTestServer(t, cfg)
+		if s != nil {
+			svr.etcdWrap = s.etcdWrap
+			if _, err := svr.initUUIDData(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return svr, svr.initFence(ctx)
+	}
+
+	s1, err := restart(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last *api.UUIDRange
+	for i := 0; i < 3; i++ {
+		if last, err = fetch(s1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s1.stopFence()
+	watermark := s1.namespaces[""].key(strconv.Itoa(int(last.ServiceId)) + ":" + strconv.Itoa(int(last.ContainerId)))
+
+	// the same store passes
+	s2, err := restart(s1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetch(s2); err != nil {
+		t.Fatal(err)
+	}
+
+	// a lower watermark fences the running server and stops the next start
+	if _, err := s2.etcdWrap.Set(ctx, watermark, "5", nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := fetch(s2); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("fenced server: want FailedPrecondition, got %v", err)
+		}
+	}
+	s2.stopFence()
+	if _, err := restart(s2); err == nil || !strings.Contains(err.Error(), watermark) {
+		t.Fatalf("rolled back store: want an error about %v, got %v", watermark, err)
+	}
+
+	// a wiped store is refused until the epoch is bumped, then it is raised to the record
+	s3, err := restart(nil)
+	if err == nil || !strings.Contains(err.Error(), "store ID") {
+		t.Fatalf("wiped store: want an error about the store ID, got %v", err)
+	}
+	if _, err := ackEpoch(ctx, s3.etcdWrap, s3.namespaces[""]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s3.initFence(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s3.etcdWrap.Get(ctx, watermark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, _ := strconv.Atoi(r.Node.Value); next <= int(last.SequenceIdEnd) {
+		t.Fatalf("the watermark is %d after the restore, want above %d", next, last.SequenceIdEnd)
+	}
+	item, err := fetch(s3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ServiceId <= last.ServiceId {
+		t.Fatalf("service ID %d is issued again after the restore", item.ServiceId)
+	}
+	s3.stopFence()
+	// the state keeps only the watermarks written since the epoch
+	state, err := loadState(cfg.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watermarks := state.Namespaces[""].Watermarks
+	if _, ok := watermarks[lastKeyPart(watermark)]; ok || len(watermarks) != 1 {
+		t.Fatalf("watermarks after the epoch: %v", watermarks)
+	}
+	if _, err := restart(s3); err != nil {
+		t.Fatalf("the acknowledged store must pass: %v", err)
+	}
+}
+
+func TestFenceAckEveryState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flake-fence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	files := []string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")}
+	// start starts the server of an instance on store
+	start := func(file string, store *EtcdWrap) (*UUIDServer, error) {
+		svr := newTestServer(t, &Config{StateFile: file})
+		if store != nil {
+			svr.etcdWrap = store
+			if _, err := svr.initUUIDData(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return svr, svr.initFence(ctx)
+	}
+	fetch := func(s *UUIDServer, service string, container string) *api.UUIDRange {
+		reply, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: service, ContainerName: container, NeedCount: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply.Items[0]
+	}
+
+	a, err := start(files[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := start(files[1], a.etcdWrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b registers a service and a container a never sees
+	fetch(a, "order", "c1")
+	var last *api.UUIDRange
+	for i := 0; i < 3; i++ {
+		last = fetch(b, "pay", "c2")
+	}
+	a.stopFence()
+	b.stopFence()
+
+	// the store is wiped, a restarts before b and must not issue the ranges of b
+	wiped, err := start(files[0], nil)
+	if err == nil {
+		t.Fatal("wiped store: want an error")
+	}
+	if _, err := wiped.ackStates(ctx, nil); err == nil {
+		t.Fatal("ack without the state files: want an error")
+	}
+	if _, err := wiped.ackStates(ctx, files); err != nil {
+		t.Fatal(err)
+	}
+	a, err = start(files[0], wiped.etcdWrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := fetch(a, "refund", "c3"); item.ServiceId == last.ServiceId && item.ContainerId == last.ContainerId && item.SequenceIdStart <= last.SequenceIdEnd {
+		t.Fatalf("a issued %v after the ack, b issued %v before", item, last)
+	}
+	a.stopFence()
+	b, err = start(files[1], wiped.etcdWrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.stopFence()
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cnwinds/flake/api"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// fsck checks the keys of a namespace, see Fsck.
+type fsck struct {
+	s      *UUIDServer
+	ns     *namespace
+	repair bool
+	reply  *api.FsckReply
+}
+
+// Fsck check the invariants of the keys of a namespace and report every violation.
+// With repair the safe cases are repaired: the claims of pinned service IDs that
+// are missing are created and the max_* counters are raised above the IDs in use.
+// Nothing is ever deleted or lowered.
+func (s *UUIDServer) Fsck(ctx context.Context, in *api.FsckRequest) (*api.FsckReply, error) {
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	f := &fsck{s: s, ns: ns, repair: in.Repair, reply: &api.FsckReply{}}
+	if err := f.run(ctx); err != nil {
+		return nil, err
+	}
+	if len(f.reply.Problems) > 0 {
+		s.logger().Warn("flake fsck", "namespace", ns.name, "problems", len(f.reply.Problems), "repair", in.Repair)
+	}
+	return f.reply, nil
+}
+
+func (f *fsck) report(key string, repaired bool, format string, a ...interface{}) {
+	f.reply.Problems = append(f.reply.Problems, &api.FsckProblem{Key: key, Problem: fmt.Sprintf(format, a...), Repaired: repaired})
+}
+
+// list returns the values of a directory by the last part of their keys.
+func (f *fsck) list(ctx context.Context, dir string) (map[string]string, error) {
+	nodes, err := f.s.etcdWrap.List(ctx, f.ns.key(dir))
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, node := range nodes {
+		if node.Dir {
+			continue
+		}
+		values[nameOfKey(node.Key)] = node.Value
+	}
+	f.reply.Keys += int32(len(values))
+	return values, nil
+}
+
+// counter reads a max_* counter, a missing or invalid counter is reported.
+func (f *fsck) counter(ctx context.Context, name string) (int, bool, error) {
+	r, err := f.s.etcdWrap.Get(ctx, f.ns.key(name))
+	if err != nil {
+		if f.s.etcdWrap.IsKeyNotFound(err) {
+			f.report(f.ns.key(name), false, "missing")
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	value, err := strconv.Atoi(r.Node.Value)
+	if err != nil {
+		f.report(f.ns.key(name), false, "invalid value %q", r.Node.Value)
+		return 0, false, nil
+	}
+	return value, true, nil
+}
+
+// raise sets a max_* counter to value unless it is already higher.
+func (f *fsck) raise(ctx context.Context, name string, value int) (bool, error) {
+	if !f.repair {
+		return false, nil
+	}
+	key := f.ns.key(name)
+	for {
+		r, err := f.s.etcdWrap.Get(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		cur, err := strconv.Atoi(r.Node.Value)
+		if err != nil {
+			return false, nil
+		}
+		if cur >= value {
+			return true, nil
+		}
+		_, err = f.s.etcdWrap.Set(ctx, key, strconv.Itoa(value), &client.SetOptions{PrevIndex: r.Node.ModifiedIndex})
+		if err != nil {
+			if f.s.etcdWrap.IsCompareFailed(err) {
+				continue
+			}
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+func (f *fsck) run(ctx context.Context) error {
+	layout := f.ns.layout
+	maxServiceID, serviceOK, err := f.counter(ctx, KeyOfMaxServiceID)
+	if err != nil {
+		return err
+	}
+	maxContainerID, containerOK, err := f.counter(ctx, KeyOfMaxContainerID)
+	if err != nil {
+		return err
+	}
+
+	// services, aliases and the claims of the service IDs
+	services, err := f.list(ctx, KeyOfServiceDir)
+	if err != nil {
+		return err
+	}
+	aliases, err := f.list(ctx, KeyOfAliasDir)
+	if err != nil {
+		return err
+	}
+	claims, err := f.list(ctx, KeyOfServiceIDDir)
+	if err != nil {
+		return err
+	}
+	serviceIDs := make(map[string]int)
+	namesOfID := make(map[int][]string)
+	for _, name := range sortedKeys(services) {
+		key := f.ns.nameKey(KeyOfServiceDir, name)
+		id, _, err := parseServiceRecord(services[name])
+		if err != nil {
+			f.report(key, false, "invalid value %q", services[name])
+			continue
+		}
+		if id <= StartOfServerID || id >= layout.MaxServiceID() {
+			f.report(key, false, "service ID %d is not between %d and %d", id, StartOfServerID+1, layout.MaxServiceID()-1)
+			continue
+		}
+		serviceIDs[name] = id
+		namesOfID[id] = append(namesOfID[id], name)
+	}
+	for _, id := range sortedIDs(namesOfID) {
+		canonical := make(map[string]bool)
+		for _, name := range namesOfID[id] {
+			if c, ok := aliases[name]; ok {
+				canonical[c] = true
+			} else {
+				canonical[name] = true
+			}
+		}
+		if len(canonical) > 1 {
+			f.report(f.ns.key(KeyOfServiceDir), false, "service ID %d is used by %v", id, strings.Join(namesOfID[id], ", "))
+		}
+	}
+	for _, alias := range sortedKeys(aliases) {
+		key := f.ns.nameKey(KeyOfAliasDir, alias)
+		canonical := aliases[alias]
+		id, ok := serviceIDs[canonical]
+		switch {
+		case !ok:
+			f.report(key, false, "the service %q of the alias is not registered", canonical)
+		case serviceIDs[alias] != id && len(services[alias]) > 0:
+			f.report(key, false, "the alias has ID %d, the service %q has ID %d", serviceIDs[alias], canonical, id)
+		}
+	}
+	for _, name := range sortedKeys(services) {
+		id, ok := serviceIDs[name]
+		if !ok || !serviceOK || id <= maxServiceID || len(claims[strconv.Itoa(id)]) > 0 {
+			continue
+		}
+		// a pinned ID without its claim would be handed out again
+		repaired := false
+		if f.repair {
+			if _, err := f.s.claimServiceID(ctx, f.ns, id, name); err != nil {
+				return err
+			}
+			repaired = true
+		}
+		f.report(f.ns.key(KeyOfServiceIDDir, strconv.Itoa(id)), repaired, "service ID %d of %q is above %v and not claimed", id, name, KeyOfMaxServiceID)
+	}
+	for _, id := range sortedKeys(claims) {
+		owner := claims[id]
+		if ownerID, ok := serviceIDs[owner]; !ok || strconv.Itoa(ownerID) != id {
+			f.report(f.ns.key(KeyOfServiceIDDir, id), false, "claimed by %q which does not have the ID", owner)
+		}
+	}
+
+	// containers and the owners of the container IDs
+	containers, err := f.list(ctx, KeyOfContainerDir)
+	if err != nil {
+		return err
+	}
+	owners, err := f.list(ctx, KeyOfContainerIDDir)
+	if err != nil {
+		return err
+	}
+	neededContainerID := 0
+	containersOfID := make(map[int][]string)
+	for _, name := range sortedKeys(containers) {
+		key := f.ns.nameKey(KeyOfContainerDir, name)
+		id, err := strconv.Atoi(containers[name])
+		if err != nil {
+			f.report(key, false, "invalid value %q", containers[name])
+			continue
+		}
+		if id <= StartOfContainerID || id >= layout.MaxContainerID() {
+			f.report(key, false, "container ID %d is not between %d and %d", id, StartOfContainerID+1, layout.MaxContainerID()-1)
+			continue
+		}
+		containersOfID[id] = append(containersOfID[id], name)
+		if owner, ok := owners[containers[name]]; ok && owner != name {
+			f.report(key, false, "container ID %d was given to %q", id, owner)
+		}
+		if id > neededContainerID {
+			neededContainerID = id
+		}
+	}
+	for _, id := range sortedIDs(containersOfID) {
+		if len(containersOfID[id]) > 1 {
+			f.report(f.ns.key(KeyOfContainerDir), false, "container ID %d is used by %v", id, strings.Join(containersOfID[id], ", "))
+		}
+	}
+	for id := range owners {
+		if n, err := strconv.Atoi(id); err == nil && n > neededContainerID && n < layout.MaxContainerID() {
+			neededContainerID = n
+		}
+	}
+
+	// watermarks
+	root, err := f.list(ctx, "")
+	if err != nil {
+		return err
+	}
+	neededServiceID := 0
+	for _, name := range sortedKeys(root) {
+		m := watermarkKey.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		key := f.ns.key(name)
+		serviceID, _ := strconv.Atoi(m[1])
+		containerID, _ := strconv.Atoi(m[2])
+		next, err := strconv.Atoi(root[name])
+		if err != nil {
+			f.report(key, false, "invalid value %q", root[name])
+			continue
+		}
+		if next < StartOfSequence || next > layout.MaxSequence() {
+			f.report(key, false, "watermark %d is not between %d and %d", next, StartOfSequence, layout.MaxSequence())
+		}
+		if serviceID >= layout.MaxServiceID() || containerID >= layout.MaxContainerID() {
+			f.report(key, false, "the IDs do not fit the layout %v", layout)
+			continue
+		}
+		if serviceID > maxServiceID && len(claims[m[1]]) == 0 && len(namesOfID[serviceID]) == 0 && serviceID > neededServiceID {
+			neededServiceID = serviceID
+		}
+		if containerID > neededContainerID {
+			neededContainerID = containerID
+		}
+	}
+
+	if serviceOK && neededServiceID > maxServiceID {
+		repaired, err := f.raise(ctx, KeyOfMaxServiceID, neededServiceID)
+		if err != nil {
+			return err
+		}
+		f.report(f.ns.key(KeyOfMaxServiceID), repaired, "service ID %d is in use without an owner, the counter is %d", neededServiceID, maxServiceID)
+	}
+	if containerOK && neededContainerID > maxContainerID {
+		repaired, err := f.raise(ctx, KeyOfMaxContainerID, neededContainerID)
+		if err != nil {
+			return err
+		}
+		f.report(f.ns.key(KeyOfMaxContainerID), repaired, "container ID %d is in use, the counter is %d", neededContainerID, maxContainerID)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedIDs(m map[int][]string) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
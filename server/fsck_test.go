@@ -0,0 +1,89 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+
+	"golang.org/x/net/context"
+)
+
+func TestFsck(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+	ns := s.namespaces[""]
+
+	reply, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: "order", ContainerName: "c1", NeedCount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := reply.Items[0]
+	if _, err := s.AddAlias(ctx, &api.AddAliasRequest{Alias: "orders", ServiceName: "order"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RegisterService(ctx, &api.RegisterServiceRequest{ServiceName: "pay", ServiceId: 500}); err != nil {
+		t.Fatal(err)
+	}
+	check := func(repair bool) *api.FsckReply {
+		t.Helper()
+		reply, err := s.Fsck(ctx, &api.FsckRequest{Repair: repair})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+	if r := check(false); len(r.Problems) != 0 || r.Keys == 0 {
+		t.Fatalf("a clean keyspace has problems: %v", r)
+	}
+
+	// manual edits
+	set := func(key string, value string) {
+		if _, err := s.etcdWrap.Set(ctx, key, value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set(ns.key(KeyOfServiceDir, "dup"), strconv.Itoa(int(item.ServiceId)))
+	set(ns.key(KeyOfServiceDir, "pinned"), "600")
+	set(ns.key(KeyOfContainerDir, "c2"), "900")
+	set(ns.key(strconv.Itoa(int(item.ServiceId))+":"+strconv.Itoa(int(item.ContainerId))), strconv.Itoa(ns.layout.MaxSequence()+1))
+	set(ns.key("700:20"), "5")
+
+	expect := func(r *api.FsckReply, repaired int, problems ...string) {
+		t.Helper()
+		if len(r.Problems) != len(problems) {
+			t.Fatalf("want %d problems, got %v", len(problems), r.Problems)
+		}
+		n := 0
+		for i, p := range r.Problems {
+			if !strings.Contains(p.Key+" "+p.Problem, problems[i]) {
+				t.Errorf("problem %d: want %q, got %v", i, problems[i], p)
+			}
+			if p.Repaired {
+				n++
+			}
+		}
+		if n != repaired {
+			t.Errorf("want %d repaired problems, got %d", repaired, n)
+		}
+	}
+	problems := []string{
+		"used by dup, order, orders",
+		"serviceid/600",
+		"watermark 2147483649",
+		"max_serviceid",
+		"max_containerid",
+	}
+	expect(check(false), 0, problems...)
+	expect(check(true), 3, problems...)
+	// the repaired cases are gone
+	expect(check(false), 0, problems[0], problems[2])
+
+	if r, err := s.etcdWrap.Get(ctx, ns.key(KeyOfMaxContainerID)); err != nil || r.Node.Value != "900" {
+		t.Fatalf("max_containerid is not raised: %v %v", r, err)
+	}
+	if owner, err := s.serviceIDOwner(ctx, ns, 600); err != nil || owner != "pinned" {
+		t.Fatalf("the claim of the pinned ID is not created: %v %v", owner, err)
+	}
+}
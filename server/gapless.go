@@ -0,0 +1,318 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultReservationTTL how long a gapless number stays reserved when neither
+	// the request nor the config asks for something else.
+	DefaultReservationTTL = 60 * time.Second
+
+	// CommittedRetention how long a committed number is kept as a tombstone.
+	// The tombstone stops a Reserve that read an outdated max_number from
+	// creating the same reservation again.
+	CommittedRetention = 10 * time.Minute
+
+	// KeyOfGaplessDir the directory where the gapless counters are saved.
+	KeyOfGaplessDir = "gapless"
+	// KeyOfMaxNumber holds the key for the highest gapless number handed out.
+	KeyOfMaxNumber = "max_number"
+	// KeyOfReservedDir the directory where the open reservations are saved.
+	KeyOfReservedDir = "reserved"
+)
+
+// reservation the value saved for every reserved gapless number.
+// An empty token marks an aborted number that is waiting to be re-issued.
+type reservation struct {
+	Token     string `json:"token"`
+	Expire    int64  `json:"expire,omitempty"`
+	Committed bool   `json:"committed,omitempty"`
+}
+
+func (r *reservation) reusable(now time.Time) bool {
+	return !r.Committed && (r.Token == "" || r.Expire <= now.Unix())
+}
+
+// Reserve reserve the next gapless number of the service.
+// Aborted and expired reservations are re-issued, lowest first, before new numbers.
+func (s *UUIDServer) Reserve(ctx context.Context, in *api.ReserveRequest) (*api.ReserveReply, error) {
+	if len(in.ServiceName) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "service name is required for gapless numbers")
+	}
+	if err := checkName("service", in.ServiceName); err != nil {
+		return nil, err
+	}
+	err := s.checkDraining()
+	if err != nil {
+		return nil, err
+	}
+	err = s.authorize(ctx, in.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := s.cfg.ReservationTTL
+	if in.TtlSeconds > 0 {
+		ttl = time.Duration(in.TtlSeconds) * time.Second
+	}
+	if ttl <= 0 {
+		ttl = DefaultReservationTTL
+	}
+	token, err := newReservationToken()
+	if err != nil {
+		return nil, err
+	}
+	r := reservation{Token: token, Expire: time.Now().Add(ttl).Unix()}
+	value, err := json.Marshal(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := s.reuseReservation(ctx, ns, in.ServiceName, string(value))
+	if err != nil {
+		return nil, err
+	}
+	if number == 0 {
+		number, err = s.newReservation(ctx, ns, in.ServiceName, string(value))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &api.ReserveReply{Number: number, Token: r.Token, ExpireTime: r.Expire}, nil
+}
+
+// Commit mark a reserved gapless number as used, it will never be issued again.
+func (s *UUIDServer) Commit(ctx context.Context, in *api.CommitRequest) (*api.CommitReply, error) {
+	err := s.authorize(ctx, in.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(&reservation{Token: in.Token, Committed: true})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		resp, r, err := s.getReservation(ctx, ns, in.ServiceName, in.Number, in.Token)
+		if err != nil {
+			return nil, err
+		}
+		if r.Committed {
+			return &api.CommitReply{}, nil
+		}
+		// the counter must cover the number before the reservation turns into a
+		// tombstone, otherwise a later Reserve could hand it out again.
+		err = s.raiseMaxNumber(ctx, ns, in.ServiceName, in.Number)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.etcdWrap.Set(ctx, resp.Node.Key, string(value), &client.SetOptions{PrevIndex: resp.Node.ModifiedIndex, TTL: CommittedRetention})
+		if err != nil {
+			if s.etcdWrap.IsKeyNotFound(err) || s.etcdWrap.IsCompareFailed(err) {
+				// modify conflict, again
+				countConflict("gapless", err)
+				continue
+			}
+			return nil, err
+		}
+		return &api.CommitReply{}, nil
+	}
+}
+
+// Abort give a reserved gapless number back, it is re-issued by the next Reserve.
+func (s *UUIDServer) Abort(ctx context.Context, in *api.AbortRequest) (*api.AbortReply, error) {
+	err := s.authorize(ctx, in.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(&reservation{})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		resp, r, err := s.getReservation(ctx, ns, in.ServiceName, in.Number, in.Token)
+		if err != nil {
+			return nil, err
+		}
+		if r.Committed {
+			return nil, status.Errorf(codes.FailedPrecondition, "number %d of %q is already committed", in.Number, in.ServiceName)
+		}
+		_, err = s.etcdWrap.Set(ctx, resp.Node.Key, string(value), &client.SetOptions{PrevIndex: resp.Node.ModifiedIndex})
+		if err != nil {
+			if s.etcdWrap.IsKeyNotFound(err) || s.etcdWrap.IsCompareFailed(err) {
+				// modify conflict, again
+				countConflict("gapless", err)
+				continue
+			}
+			return nil, err
+		}
+		return &api.AbortReply{}, nil
+	}
+}
+
+func reservationKey(ns *namespace, serviceName string, number int64) string {
+	return ns.key(KeyOfGaplessDir, escapeName(serviceName), KeyOfReservedDir, strconv.FormatInt(number, 10))
+}
+
+// getReservation returns the reservation of number if it is still held by token.
+func (s *UUIDServer) getReservation(ctx context.Context, ns *namespace, serviceName string, number int64, token string) (*client.Response, *reservation, error) {
+	if len(serviceName) == 0 || number <= 0 || len(token) == 0 {
+		return nil, nil, status.Error(codes.InvalidArgument, "service name, number and token are required")
+	}
+	resp, err := s.etcdWrap.Get(ctx, reservationKey(ns, serviceName, number))
+	if err != nil {
+		if s.etcdWrap.IsKeyNotFound(err) {
+			return nil, nil, status.Errorf(codes.FailedPrecondition, "number %d of %q is not reserved", number, serviceName)
+		}
+		return nil, nil, err
+	}
+	r := &reservation{}
+	if err := json.Unmarshal([]byte(resp.Node.Value), r); err != nil {
+		return nil, nil, err
+	}
+	if r.Token != token {
+		return nil, nil, status.Errorf(codes.FailedPrecondition, "reservation of number %d of %q has expired", number, serviceName)
+	}
+	return resp, r, nil
+}
+
+// reuseReservation takes over the lowest aborted or expired number.
+// It returns 0 if there is nothing to re-issue.
+func (s *UUIDServer) reuseReservation(ctx context.Context, ns *namespace, serviceName string, value string) (int64, error) {
+	nodes, err := s.etcdWrap.List(ctx, ns.key(KeyOfGaplessDir, escapeName(serviceName), KeyOfReservedDir))
+	if err != nil {
+		return 0, err
+	}
+
+	type candidate struct {
+		number int64
+		node   *client.Node
+	}
+	now := time.Now()
+	candidates := make([]candidate, 0, len(nodes))
+	for _, node := range nodes {
+		number, err := strconv.ParseInt(lastKeyPart(node.Key), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid reservation key %q: %v", node.Key, err)
+		}
+		r := reservation{}
+		if err := json.Unmarshal([]byte(node.Value), &r); err != nil {
+			return 0, fmt.Errorf("invalid reservation %q: %v", node.Key, err)
+		}
+		if r.reusable(now) {
+			candidates = append(candidates, candidate{number, node})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].number < candidates[j].number })
+
+	for _, c := range candidates {
+		_, err := s.etcdWrap.Set(ctx, c.node.Key, value, &client.SetOptions{PrevIndex: c.node.ModifiedIndex})
+		if err != nil {
+			if s.etcdWrap.IsKeyNotFound(err) || s.etcdWrap.IsCompareFailed(err) {
+				// taken by someone else, try the next one
+				continue
+			}
+			return 0, err
+		}
+		return c.number, nil
+	}
+	return 0, nil
+}
+
+// newReservation reserves the number after max_number.
+// The reservation is created before max_number moves, so a crash in between
+// leaves a reservation that expires and is re-issued instead of a gap.
+func (s *UUIDServer) newReservation(ctx context.Context, ns *namespace, serviceName string, value string) (int64, error) {
+	key := ns.key(KeyOfGaplessDir, escapeName(serviceName), KeyOfMaxNumber)
+	for {
+		resp, err := s.etcdWrap.GetNCreate(ctx, key, 0)
+		if err != nil {
+			return 0, err
+		}
+		maxNumber, err := strconv.ParseInt(resp.Node.Value, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		number := maxNumber + 1
+		_, createErr := s.etcdWrap.Set(ctx, reservationKey(ns, serviceName, number), value, &client.SetOptions{PrevExist: client.PrevNoExist})
+		if createErr != nil && !s.etcdWrap.IsKeyExist(createErr) {
+			return 0, createErr
+		}
+		// advance max_number for ourselves, or help the holder of number to do it.
+		err = s.raiseMaxNumber(ctx, ns, serviceName, number)
+		if err != nil {
+			return 0, err
+		}
+		if createErr == nil {
+			return number, nil
+		}
+	}
+}
+
+// raiseMaxNumber makes sure max_number is at least number.
+func (s *UUIDServer) raiseMaxNumber(ctx context.Context, ns *namespace, serviceName string, number int64) error {
+	key := ns.key(KeyOfGaplessDir, escapeName(serviceName), KeyOfMaxNumber)
+	for {
+		resp, err := s.etcdWrap.GetNCreate(ctx, key, 0)
+		if err != nil {
+			return err
+		}
+		maxNumber, err := strconv.ParseInt(resp.Node.Value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if maxNumber >= number {
+			return nil
+		}
+		_, err = s.etcdWrap.Set(ctx, key, strconv.FormatInt(number, 10), &client.SetOptions{PrevIndex: resp.Node.ModifiedIndex})
+		if err != nil {
+			// modify conflict, again
+			countConflict("gapless", err)
+			continue
+		}
+		return nil
+	}
+}
+
+func newReservationToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func lastKeyPart(key string) string {
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i] == '/' {
+			return key[i+1:]
+		}
+	}
+	return key
+}
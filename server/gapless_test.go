@@ -0,0 +1,140 @@
+package server
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func reserve(t *testing.T, s *UUIDServer, ttl int32) *api.ReserveReply {
+	t.Helper()
+	r, err := s.Reserve(context.Background(), &api.ReserveRequest{ServiceName: "invoice", TtlSeconds: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestGaplessReissue(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	r1, r2, r3 := reserve(t, s, 0), reserve(t, s, 0), reserve(t, s, 0)
+	if r1.Number != 1 || r2.Number != 2 || r3.Number != 3 {
+		t.Fatalf("want 1, 2, 3, got %v, %v, %v", r1.Number, r2.Number, r3.Number)
+	}
+
+	if _, err := s.Commit(ctx, &api.CommitRequest{ServiceName: "invoice", Number: r1.Number, Token: r1.Token}); err != nil {
+		t.Fatal(err)
+	}
+	// commit is idempotent for the holder
+	if _, err := s.Commit(ctx, &api.CommitRequest{ServiceName: "invoice", Number: r1.Number, Token: r1.Token}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Abort(ctx, &api.AbortRequest{ServiceName: "invoice", Number: r2.Number, Token: r2.Token}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the aborted number comes back before a new one
+	r4 := reserve(t, s, 0)
+	if r4.Number != 2 {
+		t.Fatalf("want the aborted number 2, got %v", r4.Number)
+	}
+	if _, err := s.Commit(ctx, &api.CommitRequest{ServiceName: "invoice", Number: r2.Number, Token: r2.Token}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("commit with the aborted token: want FailedPrecondition, got %v", err)
+	}
+	if r5 := reserve(t, s, 0); r5.Number != 4 {
+		t.Fatalf("want 4, got %v", r5.Number)
+	}
+}
+
+func TestGaplessExpired(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	r1 := reserve(t, s, -1)
+	// negative ttl falls back to the default, force the expiration in the store
+	key := reservationKey(s.namespaces[""], "invoice", r1.Number)
+	if _, err := s.etcdWrap.Set(context.Background(), key, `{"token":"`+r1.Token+`","expire":1}`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r2 := reserve(t, s, 0)
+	if r2.Number != r1.Number {
+		t.Fatalf("want the expired number %v, got %v", r1.Number, r2.Number)
+	}
+	if _, err := s.Commit(ctx, &api.CommitRequest{ServiceName: "invoice", Number: r1.Number, Token: r1.Token}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("commit of an expired reservation: want FailedPrecondition, got %v", err)
+	}
+	if _, err := s.Commit(ctx, &api.CommitRequest{ServiceName: "invoice", Number: r2.Number, Token: r2.Token}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGaplessParallel(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	const workers, loops = 8, 50
+	var lock sync.Mutex
+	seen := make(map[int64]bool)
+	var w sync.WaitGroup
+	w.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			defer w.Done()
+			for j := 0; j < loops; j++ {
+				r, err := s.Reserve(ctx, &api.ReserveRequest{ServiceName: "invoice"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if (i+j)%3 == 0 {
+					if _, err := s.Abort(ctx, &api.AbortRequest{ServiceName: "invoice", Number: r.Number, Token: r.Token}); err != nil {
+						t.Error(err)
+					}
+					continue
+				}
+				if _, err := s.Commit(ctx, &api.CommitRequest{ServiceName: "invoice", Number: r.Number, Token: r.Token}); err != nil {
+					t.Error(err)
+					return
+				}
+				lock.Lock()
+				if seen[r.Number] {
+					t.Errorf("number %v committed twice", r.Number)
+				}
+				seen[r.Number] = true
+				lock.Unlock()
+			}
+		}(i)
+	}
+	w.Wait()
+
+	// re-issue the aborted numbers, after that there is no gap below the top
+	top := int64(0)
+	for n := range seen {
+		if n > top {
+			top = n
+		}
+	}
+	for {
+		r := reserve(t, s, 0)
+		if _, err := s.Commit(ctx, &api.CommitRequest{ServiceName: "invoice", Number: r.Number, Token: r.Token}); err != nil {
+			t.Fatal(err)
+		}
+		if r.Number > top {
+			break
+		}
+		seen[r.Number] = true
+	}
+	for n := int64(1); n <= top; n++ {
+		if !seen[n] {
+			t.Fatalf("gap at %v", n)
+		}
+	}
+}
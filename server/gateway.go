@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// MaxGatewayCount the largest number of IDs one gateway request may ask for.
+const MaxGatewayCount = 10000
+
+// gatewayPath the path of the ID endpoint, followed by the service name.
+const gatewayPath = "/v1/ids/"
+
+type gatewayLayout struct {
+	ServiceBits   int `json:"service_bits"`
+	ContainerBits int `json:"container_bits"`
+	SequenceBits  int `json:"sequence_bits"`
+}
+
+type gatewayRange struct {
+	ServiceID     int32  `json:"service_id"`
+	ContainerID   int32  `json:"container_id"`
+	SequenceStart int32  `json:"sequence_start"`
+	SequenceEnd   int32  `json:"sequence_end"`
+	FirstID       string `json:"first_id"`
+	LastID        string `json:"last_id"`
+}
+
+type gatewayReply struct {
+	Namespace string         `json:"namespace"`
+	Service   string         `json:"service"`
+	IDs       []string       `json:"ids,omitempty"`
+	Ranges    []gatewayRange `json:"ranges,omitempty"`
+	Layout    *gatewayLayout `json:"layout,omitempty"`
+}
+
+type gatewayError struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// handleGateway serves GET /v1/ids/{service}?count=N. IDs are returned as strings
+// since JSON numbers lose precision above 2^53 in JavaScript. Optional parameters:
+// namespace, container (the container of this server by default, scoped by the
+// identity of an authenticated caller) and format=ranges for the raw ranges
+// instead of the composed IDs.
+func (s *UUIDServer) handleGateway(w http.ResponseWriter, r *http.Request) {
+	ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := s.tracer().Start(ctx, r.Method+" "+gatewayPath, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("http.method", r.Method), attribute.String("http.target", r.URL.Path)))
+	var err error
+	defer func() { endSpan(span, err) }()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		err = status.Errorf(codes.Unimplemented, "method %v is not allowed", r.Method)
+		writeGatewayError(w, http.StatusMethodNotAllowed, err)
+		return
+	}
+	// the ledger records the peer of the ranges
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+	ctx, err = s.authenticateBearer(ctx, r.Header.Get("Authorization"))
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeGatewayError(w, httpStatus(err), err)
+		return
+	}
+
+	query := r.URL.Query()
+	in := &api.FetchRequest{
+		ServiceName:   strings.TrimPrefix(r.URL.Path, gatewayPath),
+		ContainerName: query.Get("container"),
+		Namespace:     query.Get("namespace"),
+		NeedCount:     1,
+	}
+	if identity, ok := IdentityFromContext(ctx); ok && len(in.ContainerName) > 0 {
+		// a caller can not use the containers of the server or of other identities
+		in.ContainerName = identity + "/" + in.ContainerName
+	}
+	if len(in.ContainerName) == 0 {
+		in.ContainerName = s.containerName
+	}
+	if len(in.ServiceName) == 0 {
+		err = status.Error(codes.InvalidArgument, "service name is required")
+	} else if c := query.Get("count"); len(c) > 0 {
+		count, convErr := strconv.Atoi(c)
+		if convErr != nil || count <= 0 || count > MaxGatewayCount {
+			err = status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", MaxGatewayCount)
+		}
+		in.NeedCount = int32(count)
+	}
+	format := query.Get("format")
+	if err == nil && format != "" && format != "ids" && format != "ranges" {
+		err = status.Errorf(codes.InvalidArgument, "unknown format %q, want ids or ranges", format)
+	}
+	if err != nil {
+		writeGatewayError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var reply *api.FetchReply
+	reply, err = s.Fetch(ctx, in)
+	if err != nil {
+		writeGatewayError(w, httpStatus(err), err)
+		return
+	}
+
+	layout := layoutOf(reply.Layout)
+	out := &gatewayReply{Namespace: in.Namespace, Service: in.ServiceName}
+	for _, item := range reply.Items {
+		if format == "ranges" {
+			out.Ranges = append(out.Ranges, gatewayRange{ServiceID: item.ServiceId, ContainerID: item.ContainerId,
+				SequenceStart: item.SequenceIdStart, SequenceEnd: item.SequenceIdEnd,
+				FirstID: strconv.FormatInt(layout.Compose(item.ServiceId, item.ContainerId, item.SequenceIdStart), 10),
+				LastID:  strconv.FormatInt(layout.Compose(item.ServiceId, item.ContainerId, item.SequenceIdEnd), 10)})
+			continue
+		}
+		for seq := item.SequenceIdStart; seq <= item.SequenceIdEnd; seq++ {
+			out.IDs = append(out.IDs, strconv.FormatInt(layout.Compose(item.ServiceId, item.ContainerId, seq), 10))
+		}
+	}
+	if format == "ranges" {
+		out.Layout = &gatewayLayout{ServiceBits: layout.ServiceBits, ContainerBits: layout.ContainerBits, SequenceBits: layout.SequenceBits}
+	}
+	writeGatewayJSON(w, http.StatusOK, out)
+}
+
+// layoutOf returns the layout of a reply, the default layout if the reply has none.
+func layoutOf(l *api.Layout) util.Layout {
+	if l == nil {
+		return util.DefaultLayout
+	}
+	return util.Layout{ServiceBits: int(l.ServiceBits), ContainerBits: int(l.ContainerBits), SequenceBits: int(l.SequenceBits)}
+}
+
+func writeGatewayJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeGatewayError(w http.ResponseWriter, code int, err error) {
+	st := status.Convert(err)
+	writeGatewayJSON(w, code, &gatewayError{Code: st.Code().String(), Error: st.Message()})
+}
+
+// httpStatus maps the gRPC code of err to an HTTP status.
+func httpStatus(err error) int {
+	switch status.Code(err) {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/cnwinds/flake/util"
+
+	"golang.org/x/net/context"
+)
+
+func TestGateway(t *testing.T) {
+	s := newTestServer(t, &Config{Authenticator: StaticTokens{"s3cret": "orders"},
+		ACL: map[string][]string{"orders": {"order-.*"}}})
+
+	get := func(target string, token string, v interface{}) int {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if len(token) > 0 {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.handleGateway(w, r)
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%v: %v", target, err)
+		}
+		return w.Code
+	}
+
+	var reply gatewayReply
+	if code := get("/v1/ids/order-a?count=3&container=c1", "s3cret", &reply); code != http.StatusOK {
+		t.Fatalf("want 200, got %d", code)
+	}
+	if len(reply.IDs) != 3 {
+		t.Fatalf("want 3 IDs, got %v", reply.IDs)
+	}
+	// the container is scoped by the identity of the caller
+	if _, err := s.etcdWrap.Get(context.Background(), s.namespaces[""].nameKey(KeyOfContainerDir, "orders/c1")); err != nil {
+		t.Fatalf("the container of the identity is not used: %v", err)
+	}
+	if _, err := s.etcdWrap.Get(context.Background(), s.namespaces[""].nameKey(KeyOfContainerDir, "c1")); err == nil {
+		t.Fatal("the caller picked a container outside of its identity")
+	}
+	first, err := strconv.ParseInt(reply.IDs[0], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout := util.DefaultLayout
+	serviceID := first >> uint(layout.ContainerBits+layout.SequenceBits)
+	if serviceID < StartOfServerID || first&int64(layout.MaxSequence()-1) != StartOfSequence {
+		t.Fatalf("unexpected first ID %v", reply.IDs[0])
+	}
+	for i := 1; i < len(reply.IDs); i++ {
+		if reply.IDs[i] != strconv.FormatInt(first+int64(i), 10) {
+			t.Fatalf("IDs are not consecutive: %v", reply.IDs)
+		}
+	}
+
+	// the ranges continue after the IDs issued above
+	reply = gatewayReply{}
+	if code := get("/v1/ids/order-a?count=5&container=c1&format=ranges", "s3cret", &reply); code != http.StatusOK {
+		t.Fatalf("want 200, got %d", code)
+	}
+	if len(reply.Ranges) != 1 || reply.Layout == nil {
+		t.Fatalf("want one range and the layout, got %+v", reply)
+	}
+	rng := reply.Ranges[0]
+	if rng.SequenceEnd-rng.SequenceStart != 4 || rng.FirstID != strconv.FormatInt(first+3, 10) {
+		t.Fatalf("unexpected range %+v", rng)
+	}
+
+	// without a container the one read at startup is used
+	s.containerName = "host-1"
+	reply = gatewayReply{}
+	if code := get("/v1/ids/order-a", "s3cret", &reply); code != http.StatusOK {
+		t.Fatalf("want 200, got %d", code)
+	}
+	if _, err := s.etcdWrap.Get(context.Background(), s.namespaces[""].nameKey(KeyOfContainerDir, "host-1")); err != nil {
+		t.Fatalf("the container of the server is not used: %v", err)
+	}
+
+	var e gatewayError
+	for _, tc := range []struct {
+		target string
+		token  string
+		code   int
+	}{
+		{"/v1/ids/order-a", "", http.StatusUnauthorized},
+		{"/v1/ids/order-a", "wrong", http.StatusUnauthorized},
+		{"/v1/ids/billing", "s3cret", http.StatusForbidden},
+		{"/v1/ids/order-a?count=0", "s3cret", http.StatusBadRequest},
+		{"/v1/ids/order-a?count=100001", "s3cret", http.StatusBadRequest},
+		{"/v1/ids/order-a?format=xml", "s3cret", http.StatusBadRequest},
+		{"/v1/ids/", "s3cret", http.StatusBadRequest},
+	} {
+		if code := get(tc.target, tc.token, &e); code != tc.code {
+			t.Errorf("%v: want %d, got %d (%+v)", tc.target, tc.code, code, e)
+		}
+	}
+
+	s.Drain()
+	if code := get("/v1/ids/order-a", "s3cret", &e); code != http.StatusServiceUnavailable {
+		t.Fatalf("want 503 while draining, got %d", code)
+	}
+}
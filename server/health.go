@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultHealthInterval how often the store is checked if Config.HealthInterval is not set.
+const DefaultHealthInterval = 5 * time.Second
+
+// healthState the result of the last store check.
+type healthState struct {
+	lock    sync.Mutex
+	err     error
+	checked time.Time
+
+	server   *health.Server
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (s *UUIDServer) initHealth() {
+	s.health.server = health.NewServer()
+	s.health.stop = make(chan struct{})
+	s.updateHealth()
+}
+
+// checkHealth reads the counters of every namespace, the store is healthy if
+// it answers and the counters hold numbers. The capacity gauges are updated on the way.
+func (s *UUIDServer) checkHealth(ctx context.Context) error {
+	for _, ns := range s.namespaces {
+		var values [2]int
+		for i, name := range []string{KeyOfMaxServiceID, KeyOfMaxContainerID} {
+			r, err := s.etcdWrap.Get(ctx, ns.key(name))
+			if err != nil {
+				return fmt.Errorf("namespace %q: %v", ns.name, err)
+			}
+			values[i], err = strconv.Atoi(r.Node.Value)
+			if err != nil {
+				return fmt.Errorf("namespace %q: %v holds %q, not a number", ns.name, name, r.Node.Value)
+			}
+		}
+		updateCapacity(ns, values[0], values[1])
+		if err := s.watchSchema(ctx, ns); err != nil {
+			return fmt.Errorf("namespace %q: %v", ns.name, err)
+		}
+	}
+	return nil
+}
+
+// updateHealth check the store and publish the result to the gRPC health service.
+func (s *UUIDServer) updateHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.healthInterval())
+	err := s.checkHealth(ctx)
+	cancel()
+
+	s.health.lock.Lock()
+	if (err == nil) != (s.health.err == nil) || s.health.checked.IsZero() {
+		if err != nil {
+			s.logger().Error("flake store is unhealthy", "error", err)
+		} else {
+			s.logger().Info("flake store is healthy")
+		}
+	}
+	s.health.err, s.health.checked = err, time.Now()
+	s.health.lock.Unlock()
+
+	s.publishHealth()
+}
+
+// publishHealth set the status of the gRPC services from the last check, the drain mode and the fence.
+func (s *UUIDServer) publishHealth() {
+	if s.health.server == nil {
+		return
+	}
+	storeOK := s.storeHealth() == nil
+	ready := storeOK && !s.Draining() && s.fenceError() == nil
+	for _, name := range []string{"", "api.UUID", "api.Sequence"} {
+		s.health.server.SetServingStatus(name, servingStatus(ready))
+	}
+	s.health.server.SetServingStatus("api.Admin", servingStatus(storeOK))
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// storeHealth returns the error of the last store check.
+func (s *UUIDServer) storeHealth() error {
+	s.health.lock.Lock()
+	defer s.health.lock.Unlock()
+	return s.health.err
+}
+
+// watchHealth check the store periodically until the server is shut down.
+func (s *UUIDServer) watchHealth() {
+	ticker := time.NewTicker(s.healthInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.updateHealth()
+		case <-s.health.stop:
+			return
+		}
+	}
+}
+
+func (s *UUIDServer) healthInterval() time.Duration {
+	if s.cfg.HealthInterval > 0 {
+		return s.cfg.HealthInterval
+	}
+	return DefaultHealthInterval
+}
+
+// stopHealth stop the checks and report every service as not serving.
+func (s *UUIDServer) stopHealth() {
+	s.health.stopOnce.Do(func() {
+		close(s.health.stop)
+		s.health.server.Shutdown()
+	})
+}
+
+// handleHealthz the liveness endpoint, the process is alive as long as it answers.
+func (s *UUIDServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz the readiness endpoint, ready if the store is healthy and the server is neither draining nor fenced.
+func (s *UUIDServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.Draining() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	if err := s.storeHealth(); err != nil {
+		http.Error(w, "store: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err := s.fenceError(); err != nil {
+		http.Error(w, "fenced: "+status.Convert(err).Message(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealth(t *testing.T) {
+	// the health service is checked without a token while the authentication is on
+	s := newTestServer(t, &Config{ListenAddress: "127.0.0.1:0", HTTPListenAddress: "127.0.0.1:0", HealthInterval: 10 * time.Millisecond,
+		Authenticator: StaticTokens{"s3cret": "orders"}})
+	if err := s.initGRPC(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.initHTTP(); err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	defer s.Shutdown(context.Background())
+
+	conn, err := grpc.Dial(s.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	healthClient := healthpb.NewHealthClient(conn)
+	readyz := "http://" + s.HTTPAddr().String() + "/readyz"
+
+	expect := func(service string, want healthpb.HealthCheckResponse_ServingStatus, code int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			resp, err := healthClient.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := http.Get(readyz)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Body.Close()
+			if resp.Status == want && r.StatusCode == code {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("want %v and HTTP %d, got %v and HTTP %d", want, code, resp.Status, r.StatusCode)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	expect("api.UUID", healthpb.HealthCheckResponse_SERVING, http.StatusOK)
+
+	// a counter that is not a number fails the schema check
+	key := s.namespaces[""].key(KeyOfMaxServiceID)
+	if _, err := s.etcdWrap.Set(context.Background(), key, "broken", nil); err != nil {
+		t.Fatal(err)
+	}
+	expect("", healthpb.HealthCheckResponse_NOT_SERVING, http.StatusServiceUnavailable)
+	if _, err := s.etcdWrap.Set(context.Background(), key, "10", nil); err != nil {
+		t.Fatal(err)
+	}
+	expect("", healthpb.HealthCheckResponse_SERVING, http.StatusOK)
+
+	s.Drain()
+	expect("api.UUID", healthpb.HealthCheckResponse_NOT_SERVING, http.StatusServiceUnavailable)
+	expect("api.Admin", healthpb.HealthCheckResponse_SERVING, http.StatusServiceUnavailable)
+
+	r, err := http.Get("http://" + s.HTTPAddr().String() + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("healthz: want 200, got %d", r.StatusCode)
+	}
+}
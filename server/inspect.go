@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultReassignmentLimit how many reassignments ListReassignments returns if no limit is given.
+	DefaultReassignmentLimit = 100
+	// MaxReassignmentLimit the largest limit of ListReassignments.
+	MaxReassignmentLimit = 10000
+)
+
+// watermarkKey matches the "<service ID>:<container ID>" keys of the sequences.
+var watermarkKey = regexp.MustCompile(`^(\d+):(\d+)$`)
+
+// reassignment the record of a container ID reassignment.
+type reassignment struct {
+	Container string `json:"container"`
+	OldID     int    `json:"old_id"`
+	NewID     int    `json:"new_id"`
+	Time      int64  `json:"time"`
+}
+
+// logReassignment records a reassignment for ListReassignments. The reassignment is
+// done already, so a failure is only logged.
+func (s *UUIDServer) logReassignment(ctx context.Context, ns *namespace, containerName string, oldID int, newID int) {
+	value, err := json.Marshal(&reassignment{Container: containerName, OldID: oldID, NewID: newID, Time: time.Now().Unix()})
+	if err == nil {
+		_, err = s.etcdWrap.CreateInOrder(ctx, ns.key(KeyOfReassignDir), string(value), 0)
+	}
+	if err != nil {
+		s.logger().Warn("flake reassignment log", "namespace", ns.name, "container", containerName, "error", err)
+	}
+}
+
+// ListServices list the service names of the namespace with their IDs, aliases included.
+func (s *UUIDServer) ListServices(ctx context.Context, in *api.ListRequest) (*api.ListServicesReply, error) {
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := s.etcdWrap.List(ctx, ns.key(KeyOfAliasDir))
+	if err != nil {
+		return nil, err
+	}
+	canonical := make(map[string]string)
+	for _, node := range aliases {
+		canonical[nameOfKey(node.Key)] = node.Value
+	}
+
+	nodes, err := s.etcdWrap.List(ctx, ns.key(KeyOfServiceDir))
+	if err != nil {
+		return nil, err
+	}
+	reply := &api.ListServicesReply{}
+	for _, node := range nodes {
+		name := nameOfKey(node.Key)
+		serviceID, retired, err := parseServiceRecord(node.Value)
+		if err != nil {
+			return nil, err
+		}
+		info := &api.ServiceInfo{Name: name, ServiceId: int32(serviceID), CanonicalName: name, Retired: retired}
+		if c, ok := canonical[name]; ok {
+			info.CanonicalName = c
+		}
+		reply.Services = append(reply.Services, info)
+	}
+	return reply, nil
+}
+
+// ListContainers list the container names of the namespace with their current IDs.
+func (s *UUIDServer) ListContainers(ctx context.Context, in *api.ListRequest) (*api.ListContainersReply, error) {
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := s.etcdWrap.List(ctx, ns.key(KeyOfContainerDir))
+	if err != nil {
+		return nil, err
+	}
+	reply := &api.ListContainersReply{}
+	for _, node := range nodes {
+		containerID, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return nil, err
+		}
+		reply.Containers = append(reply.Containers, &api.ContainerInfo{Name: nameOfKey(node.Key), ContainerId: int32(containerID)})
+	}
+	return reply, nil
+}
+
+// ListWatermarks list the next sequence of every service and container pair of the
+// namespace and how much of the sequence space it has used.
+func (s *UUIDServer) ListWatermarks(ctx context.Context, in *api.ListRequest) (*api.ListWatermarksReply, error) {
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	services, err := s.etcdWrap.List(ctx, ns.key(KeyOfServiceIDDir))
+	if err != nil {
+		return nil, err
+	}
+	serviceNames := make(map[string]string)
+	for _, node := range services {
+		serviceNames[lastKeyPart(node.Key)] = node.Value
+	}
+	containers, err := s.etcdWrap.List(ctx, ns.key(KeyOfContainerIDDir))
+	if err != nil {
+		return nil, err
+	}
+	containerNames := make(map[string]string)
+	for _, node := range containers {
+		containerNames[lastKeyPart(node.Key)] = node.Value
+	}
+
+	nodes, err := s.etcdWrap.List(ctx, ns.key())
+	if err != nil {
+		return nil, err
+	}
+	maxOfSequence := ns.layout.MaxSequence()
+	reply := &api.ListWatermarksReply{}
+	for _, node := range nodes {
+		m := watermarkKey.FindStringSubmatch(lastKeyPart(node.Key))
+		if node.Dir || m == nil {
+			continue
+		}
+		serviceID, _ := strconv.Atoi(m[1])
+		containerID, _ := strconv.Atoi(m[2])
+		next, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return nil, err
+		}
+		reply.Watermarks = append(reply.Watermarks, &api.Watermark{
+			ServiceId:     int32(serviceID),
+			ContainerId:   int32(containerID),
+			ServiceName:   serviceNames[m[1]],
+			ContainerName: containerNames[m[2]],
+			NextSequence:  int32(next),
+			UsedPercent:   float64(next-StartOfSequence) * 100 / float64(maxOfSequence-StartOfSequence),
+		})
+	}
+	return reply, nil
+}
+
+// ListReassignments list the newest container ID reassignments of the namespace, the newest first.
+func (s *UUIDServer) ListReassignments(ctx context.Context, in *api.ListRequest) (*api.ListReassignmentsReply, error) {
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	limit := int(in.Limit)
+	if limit <= 0 {
+		limit = DefaultReassignmentLimit
+	}
+	if limit > MaxReassignmentLimit {
+		limit = MaxReassignmentLimit
+	}
+	nodes, err := s.etcdWrap.List(ctx, ns.key(KeyOfReassignDir))
+	if err != nil {
+		return nil, err
+	}
+	reply := &api.ListReassignmentsReply{}
+	for i := len(nodes) - 1; i >= 0 && len(reply.Reassignments) < limit; i-- {
+		var r reassignment
+		if err := json.Unmarshal([]byte(nodes[i].Value), &r); err != nil {
+			return nil, err
+		}
+		reply.Reassignments = append(reply.Reassignments, &api.Reassignment{ContainerName: r.Container,
+			OldContainerId: int32(r.OldID), NewContainerId: int32(r.NewID), Time: r.Time})
+	}
+	return reply, nil
+}
@@ -0,0 +1,383 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultLedgerFileSize the size at which the ledger file is rotated if Config.LedgerFileSize is not set.
+	DefaultLedgerFileSize = 64 << 20
+	// DefaultLedgerFileCount how many rotated ledger files are kept if Config.LedgerFileCount is not set.
+	DefaultLedgerFileCount = 5
+)
+
+// ledger records the issued ranges and finds the ranges that contain a sequence.
+type ledger interface {
+	record(ctx context.Context, ns *namespace, entry *api.LedgerEntry) error
+	lookup(ctx context.Context, ns *namespace, serviceID int32, containerID int32, sequenceID int32) ([]*api.LedgerEntry, error)
+	close() error
+	// kind labels the errors of the ledger
+	kind() string
+}
+
+// initLedger opens the ledgers of the config.
+func (s *UUIDServer) initLedger() error {
+	s.instance = s.cfg.InstanceName
+	if len(s.instance) == 0 {
+		host, _ := os.Hostname()
+		s.instance = host + "/" + s.cfg.ListenAddress
+	}
+	if s.cfg.LedgerStore {
+		s.ledgers = append(s.ledgers, &storeLedger{etcdWrap: s.etcdWrap, ttl: s.cfg.LedgerTTL})
+	}
+	if len(s.cfg.LedgerFile) > 0 {
+		l, err := openFileLedger(s.cfg.LedgerFile, s.cfg.LedgerFileSize, s.cfg.LedgerFileCount)
+		if err != nil {
+			return err
+		}
+		s.ledgers = append(s.ledgers, l)
+	}
+	return nil
+}
+
+// closeLedger closes the ledger files.
+func (s *UUIDServer) closeLedger() {
+	for _, l := range s.ledgers {
+		l.close()
+	}
+}
+
+// recordRange writes a range issued by Fetch to the ledgers. The range is issued
+// already, so a failure is only logged and counted.
+func (s *UUIDServer) recordRange(ctx context.Context, ns *namespace, in *api.FetchRequest, item *api.UUIDRange) {
+	if len(s.ledgers) == 0 {
+		return
+	}
+	entry := &api.LedgerEntry{
+		Namespace:       ns.name,
+		ServiceName:     in.ServiceName,
+		ServiceId:       item.ServiceId,
+		ContainerName:   in.ContainerName,
+		ContainerId:     item.ContainerId,
+		SequenceIdStart: item.SequenceIdStart,
+		SequenceIdEnd:   item.SequenceIdEnd,
+		Instance:        s.instance,
+		TimeUnixNano:    time.Now().UnixNano(),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		entry.Peer = p.Addr.String()
+	}
+	entry.Identity, _ = IdentityFromContext(ctx)
+	for _, l := range s.ledgers {
+		if err := l.record(ctx, ns, entry); err != nil {
+			ledgerErrors.WithLabelValues(l.kind()).Inc()
+			s.logger().Error("flake ledger", "namespace", ns.name, "service", in.ServiceName, "container", in.ContainerName,
+				"start", item.SequenceIdStart, "end", item.SequenceIdEnd, "error", err)
+		}
+	}
+}
+
+// LookupLedger find the issued ranges that contain a UUID.
+func (s *UUIDServer) LookupLedger(ctx context.Context, in *api.LookupLedgerRequest) (*api.LookupLedgerReply, error) {
+	if len(s.ledgers) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "the ledger is disabled")
+	}
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	serviceID, containerID, sequenceID, err := util.ParseUUID(in.Uuid, ns.layout)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// every ledger is asked, the store may have expired a range the file still has
+	// and the file may have missed a range the store has. A range recorded in both
+	// is returned once.
+	var entries []*api.LedgerEntry
+	seen := make(map[string]bool)
+	failed := 0
+	for _, l := range s.ledgers {
+		found, err := l.lookup(ctx, ns, serviceID, containerID, sequenceID)
+		if err != nil {
+			failed++
+			if failed == len(s.ledgers) {
+				return nil, err
+			}
+			s.logger().Warn("flake ledger lookup", "ledger", l.kind(), "uuid", in.Uuid, "error", err)
+			continue
+		}
+		for _, entry := range found {
+			key := fmt.Sprintf("%v/%d/%d:%d/%d-%d", entry.Instance, entry.TimeUnixNano, entry.ServiceId, entry.ContainerId,
+				entry.SequenceIdStart, entry.SequenceIdEnd)
+			if !seen[key] {
+				seen[key] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].TimeUnixNano < entries[j].TimeUnixNano })
+	return &api.LookupLedgerReply{Entries: entries}, nil
+}
+
+func containsSequence(entry *api.LedgerEntry, serviceID int32, containerID int32, sequenceID int32) bool {
+	return entry.ServiceId == serviceID && entry.ContainerId == containerID &&
+		entry.SequenceIdStart <= sequenceID && sequenceID <= entry.SequenceIdEnd
+}
+
+// storeLedger keeps the ranges in etcd, in a directory per service and container.
+type storeLedger struct {
+	etcdWrap *EtcdWrap
+	ttl      time.Duration
+}
+
+func (l *storeLedger) dir(ns *namespace, serviceID int32, containerID int32) string {
+	return ns.key(KeyOfLedgerDir, fmt.Sprintf("%d:%d", serviceID, containerID))
+}
+
+func (l *storeLedger) record(ctx context.Context, ns *namespace, entry *api.LedgerEntry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = l.etcdWrap.CreateInOrder(ctx, l.dir(ns, entry.ServiceId, entry.ContainerId), string(value), l.ttl)
+	return err
+}
+
+func (l *storeLedger) lookup(ctx context.Context, ns *namespace, serviceID int32, containerID int32, sequenceID int32) ([]*api.LedgerEntry, error) {
+	nodes, err := l.etcdWrap.List(ctx, l.dir(ns, serviceID, containerID))
+	if err != nil {
+		return nil, err
+	}
+	var entries []*api.LedgerEntry
+	for _, node := range nodes {
+		entry := &api.LedgerEntry{}
+		if err := json.Unmarshal([]byte(node.Value), entry); err != nil {
+			return nil, err
+		}
+		if containsSequence(entry, serviceID, containerID, sequenceID) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// each calls f with every range in the ledger of the namespace. It returns the
+// number of keys that do not hold a range, e.g. written by a newer version.
+func (l *storeLedger) each(ctx context.Context, ns *namespace, f func(entry *api.LedgerEntry) error) (int, error) {
+	dirs, err := l.etcdWrap.List(ctx, ns.key(KeyOfLedgerDir))
+	if err != nil {
+		return 0, err
+	}
+	skipped := 0
+	for _, dir := range dirs {
+		nodes, err := l.etcdWrap.List(ctx, dir.Key)
+		if err != nil {
+			return skipped, err
+		}
+		for _, node := range nodes {
+			entry := &api.LedgerEntry{}
+			if err := json.Unmarshal([]byte(node.Value), entry); err != nil {
+				skipped++
+				continue
+			}
+			if err := f(entry); err != nil {
+				return skipped, err
+			}
+		}
+	}
+	return skipped, nil
+}
+
+// WalkStoreLedger calls f with every range recorded by -ledgerstore in the
+// default namespace of cfg. It returns the number of keys that do not hold a range.
+func WalkStoreLedger(ctx context.Context, cfg *Config, f func(entry *api.LedgerEntry) error) (int, error) {
+	s := &UUIDServer{cfg: cfg}
+	if err := s.initNamespaces(); err != nil {
+		return 0, err
+	}
+	w, err := NewEtcdWrap(cfg.etcdWrapConfig())
+	if err != nil {
+		return 0, err
+	}
+	l := &storeLedger{etcdWrap: w}
+	return l.each(ctx, s.namespaces[""], f)
+}
+
+func (l *storeLedger) close() error {
+	return nil
+}
+
+func (l *storeLedger) kind() string {
+	return "store"
+}
+
+// fileLedger appends the ranges to a local file as JSON lines. The file is renamed
+// to file.1 when it reaches maxSize, file.1 to file.2 and so on up to maxFiles.
+type fileLedger struct {
+	lock     sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	closed   bool
+}
+
+func openFileLedger(path string, maxSize int64, maxFiles int) (*fileLedger, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultLedgerFileSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultLedgerFileCount
+	}
+	l := &fileLedger{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	return l, l.open()
+}
+
+func (l *fileLedger) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+func (l *fileLedger) rotate() error {
+	l.file.Close()
+	l.file = nil
+	os.Remove(l.path + "." + strconv.Itoa(l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(l.path+"."+strconv.Itoa(i), l.path+"."+strconv.Itoa(i+1))
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+func (l *fileLedger) record(ctx context.Context, ns *namespace, entry *api.LedgerEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return fmt.Errorf("ledger %v is closed", l.path)
+	}
+	if l.file == nil {
+		// a rotation failed, try again
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// lookup scans the rotated files and the current file, the oldest first. The files
+// are opened under the lock, so a rotation does not move a file between them, and
+// scanned without it, so recording a range does not wait for a long scan.
+func (l *fileLedger) lookup(ctx context.Context, ns *namespace, serviceID int32, containerID int32, sequenceID int32) ([]*api.LedgerEntry, error) {
+	files, err := l.openAll()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	var entries []*api.LedgerEntry
+	for _, f := range files {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			entry := &api.LedgerEntry{}
+			if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+				// a line cut short by a crash or still being written
+				continue
+			}
+			if entry.Namespace == ns.name && containsSequence(entry, serviceID, containerID, sequenceID) {
+				entries = append(entries, entry)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// openAll opens the rotated files and the current file for reading, the oldest first.
+func (l *fileLedger) openAll() ([]*os.File, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var files []*os.File
+	for i := l.maxFiles; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path += "." + strconv.Itoa(i)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// close closes the file, the ranges recorded after it fail. Closing again does nothing.
+func (l *fileLedger) close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (l *fileLedger) kind() string {
+	return "file"
+}
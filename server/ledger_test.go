@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flake-ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "ledger.log")
+
+	if _, err := newTestServer(t, nil).LookupLedger(context.Background(), &api.LookupLedgerRequest{Uuid: 1}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("disabled ledger: want FailedPrecondition, got %v", err)
+	}
+
+	// a small file is rotated after a few ranges
+	s := newTestServer(t, &Config{LedgerStore: true, LedgerFile: file, LedgerFileSize: 1000, LedgerFileCount: 2, InstanceName: "flake-1"})
+	if err := s.initLedger(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.closeLedger()
+	ctx := peer.NewContext(context.WithValue(context.Background(), identityKey{}, "orders"),
+		&peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000}})
+	var items []*api.UUIDRange
+	for i := 0; i < 20; i++ {
+		reply, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: "order", ContainerName: "c1", NeedCount: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, reply.Items...)
+	}
+	if _, err := os.Stat(file + ".2"); err != nil {
+		t.Fatalf("the ledger file is not rotated: %v", err)
+	}
+	if _, err := os.Stat(file + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("only 2 rotated files must be kept: %v", err)
+	}
+
+	item := items[len(items)-1]
+	uuid := util.GenUUID(item.ServiceId, item.ContainerId, item.SequenceIdStart+5)
+	for _, l := range s.ledgers {
+		entries, err := l.lookup(context.Background(), s.namespaces[""], item.ServiceId, item.ContainerId, item.SequenceIdStart+5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("%v ledger: want 1 entry, got %v", l.kind(), entries)
+		}
+		e := entries[0]
+		if e.ServiceName != "order" || e.ContainerName != "c1" || e.SequenceIdStart != item.SequenceIdStart ||
+			e.SequenceIdEnd != item.SequenceIdEnd || e.Peer != "10.0.0.1:4000" || e.Identity != "orders" ||
+			e.Instance != "flake-1" || e.TimeUnixNano == 0 {
+			t.Fatalf("%v ledger: unexpected entry %v", l.kind(), e)
+		}
+	}
+
+	reply, err := s.LookupLedger(context.Background(), &api.LookupLedgerRequest{Uuid: uuid})
+	if err != nil || len(reply.Entries) != 1 {
+		t.Fatalf("unexpected reply %v, %v", reply, err)
+	}
+	// the oldest ranges are rotated out of the file but stay in the store
+	first := items[0]
+	reply, err = s.LookupLedger(context.Background(), &api.LookupLedgerRequest{Uuid: util.GenUUID(first.ServiceId, first.ContainerId, first.SequenceIdStart)})
+	if err != nil || len(reply.Entries) != 1 {
+		t.Fatalf("unexpected reply of the first range %v, %v", reply, err)
+	}
+	reply, err = s.LookupLedger(context.Background(), &api.LookupLedgerRequest{Uuid: util.GenUUID(first.ServiceId, first.ContainerId, item.SequenceIdEnd+1)})
+	if err != nil || len(reply.Entries) != 0 {
+		t.Fatalf("a range that was not issued: %v, %v", reply, err)
+	}
+
+	// every range of the store ledger is walked
+	walked := 0
+	skipped, err := s.ledgers[0].(*storeLedger).each(context.Background(), s.namespaces[""], func(entry *api.LedgerEntry) error {
+		walked++
+		return nil
+	})
+	if err != nil || walked != len(items) || skipped != 0 {
+		t.Fatalf("walked %d ranges of the store ledger, want %d, skipped %d: %v", walked, len(items), skipped, err)
+	}
+
+	// a range that expired from the store is still found in the file
+	nodes, err := s.etcdWrap.List(context.Background(), s.namespaces[""].key(KeyOfLedgerDir, fmt.Sprintf("%d:%d", item.ServiceId, item.ContainerId)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes {
+		if _, err := s.etcdWrap.Delete(context.Background(), node.Key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply, err = s.LookupLedger(context.Background(), &api.LookupLedgerRequest{Uuid: uuid})
+	if err != nil || len(reply.Entries) != 1 || reply.Entries[0].SequenceIdStart != item.SequenceIdStart {
+		t.Fatalf("range only in the file: %v, %v", reply, err)
+	}
+
+	// a closed file is not opened again by record
+	l := s.ledgers[1]
+	if err := l.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+	if err := l.record(ctx, s.namespaces[""], &api.LedgerEntry{ServiceName: "order"}); err == nil {
+		t.Fatal("record after close must fail")
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// secretFields the config fields that are never logged.
+var secretFields = map[string]bool{"Password": true}
+
+// NewLogger create a logger writing to w, level is debug, info, warn or error
+// and format is text or json.
+func NewLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, want text or json", format)
+}
+
+// LogValue logs the config without its secrets.
+func (cfg Config) LogValue() slog.Value {
+	return slog.GroupValue(redactedAttrs(cfg)...)
+}
+
+// LogValue logs the config without its secrets.
+func (cfg EtcdWrapConfig) LogValue() slog.Value {
+	return slog.GroupValue(redactedAttrs(cfg)...)
+}
+
+// redactedAttrs returns the fields of a struct, secrets are masked and
+// interfaces are logged by their type.
+func redactedAttrs(v interface{}) []slog.Attr {
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	attrs := make([]slog.Attr, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		value := rv.Field(i)
+		switch {
+		case value.IsZero():
+			continue
+		case secretFields[f.Name]:
+			attrs = append(attrs, slog.String(f.Name, "******"))
+		case f.Type.Kind() == reflect.Interface || f.Type.Kind() == reflect.Ptr:
+			attrs = append(attrs, slog.String(f.Name, fmt.Sprintf("%T", value.Interface())))
+		default:
+			attrs = append(attrs, slog.Any(f.Name, value.Interface()))
+		}
+	}
+	return attrs
+}
+
+// logger returns the logger of the config, or the default logger.
+func (s *UUIDServer) logger() *slog.Logger {
+	if s.cfg.Logger != nil {
+		return s.cfg.Logger
+	}
+	return slog.Default()
+}
+
+// accessLogInterceptor logs a sample of the calls.
+func (s *UUIDServer) accessLogInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if rand.Float64() >= s.cfg.AccessLogSampleRate {
+		return handler(ctx, req)
+	}
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	attrs := []slog.Attr{
+		slog.String("method", info.FullMethod),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	if identity, ok := IdentityFromContext(ctx); ok {
+		attrs = append(attrs, slog.String("identity", identity))
+	}
+	if r, ok := req.(interface{ GetServiceName() string }); ok {
+		attrs = append(attrs, slog.String("service", r.GetServiceName()))
+	}
+	if r, ok := req.(*api.FetchRequest); ok {
+		attrs = append(attrs, slog.String("container", r.ContainerName), slog.Int("need_count", int(r.NeedCount)))
+	}
+	if r, ok := resp.(*api.FetchReply); ok && r != nil {
+		ranges := make([]string, 0, len(r.Items))
+		for _, item := range r.Items {
+			ranges = append(ranges, fmt.Sprintf("%d:%d:%d-%d", item.ServiceId, item.ContainerId, item.SequenceIdStart, item.SequenceIdEnd))
+		}
+		attrs = append(attrs, slog.String("ranges", strings.Join(ranges, ",")))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	s.logger().LogAttrs(ctx, slog.LevelInfo, "access", attrs...)
+	return resp, err
+}
+
+// chainUnaryInterceptors runs the interceptors in order, the first one is the outermost.
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
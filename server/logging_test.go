@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// syncBuffer a bytes.Buffer that can be written by the gRPC handlers.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestConfigLogValue(t *testing.T) {
+	var out syncBuffer
+	logger, err := NewLogger(&out, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{UserName: "flake", Password: "s3cret", Authenticator: StaticTokens{"t0ken": "orders"}}
+	logger.Info("flake config", "config", cfg)
+	logger.Info("etcd wrap config", "config", &EtcdWrapConfig{Password: "s3cret"})
+	if strings.Contains(out.String(), "s3cret") || strings.Contains(out.String(), "t0ken") {
+		t.Fatalf("secrets are logged: %v", out.String())
+	}
+	if !strings.Contains(out.String(), `"UserName":"flake"`) {
+		t.Fatalf("user name is missing: %v", out.String())
+	}
+
+	if _, err := NewLogger(&out, "loud", "text"); err == nil {
+		t.Fatal("unknown level must fail")
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flake-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "tokens")
+	if err := ioutil.WriteFile(tokenFile, []byte("orders s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out syncBuffer
+	logger, err := NewLogger(&out, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, &Config{ListenAddress: "127.0.0.1:0", Logger: logger, AccessLogSampleRate: 1, AuthTokenFile: tokenFile})
+	if err := s.initGRPC(); err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	defer s.Shutdown(context.Background())
+
+	conn, err := grpc.Dial(s.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+	_, err = api.NewUUIDClient(conn).Fetch(ctx, &api.FetchRequest{ServiceName: "TestAccessLog", ContainerName: "c", NeedCount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(out.String(), "\n") {
+		var entry map[string]interface{}
+		if json.Unmarshal([]byte(line), &entry) != nil || entry["msg"] != "access" {
+			continue
+		}
+		if entry["service"] != "TestAccessLog" || entry["container"] != "c" || entry["need_count"] != 5.0 ||
+			entry["identity"] != "orders" || entry["code"] != "OK" || entry["peer"] == nil || entry["ranges"] == "" {
+			t.Fatalf("unexpected access log: %v", line)
+		}
+		return
+	}
+	t.Fatalf("no access log: %v", out.String())
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// memKeys an in-memory client.KeysAPI that follows the etcd v2 semantics
+// flake relies on: canonical key paths, implicit directories, TTL, the
+// PrevExist/PrevIndex/PrevValue conditions and the hidden keys, a key part
+// starting with "_" is not listed but can be read directly.
+type memKeys struct {
+	mu    sync.Mutex
+	index uint64
+	nodes map[string]*client.Node
+}
+
+func newMemKeys() *memKeys {
+	return &memKeys{nodes: make(map[string]*client.Node)}
+}
+
+func (m *memKeys) clean(key string) string {
+	return path.Clean("/" + key)
+}
+
+func (m *memKeys) lookup(key string) *client.Node {
+	n, ok := m.nodes[key]
+	if !ok {
+		return nil
+	}
+	if n.Expiration != nil && !n.Expiration.After(time.Now()) {
+		delete(m.nodes, key)
+		return nil
+	}
+	return n
+}
+
+func (m *memKeys) children(dir string, recursive bool) (client.Nodes, bool) {
+	found := false
+	dirs := make(map[string]*client.Node)
+	var result client.Nodes
+	keys := make([]string, 0, len(m.nodes))
+	for k := range m.nodes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !strings.HasPrefix(k, strings.TrimSuffix(dir, "/")+"/") || m.lookup(k) == nil {
+			continue
+		}
+		found = true
+		rest := strings.TrimPrefix(k, strings.TrimSuffix(dir, "/")+"/")
+		if strings.HasPrefix(rest, "_") {
+			continue
+		}
+		if i := strings.Index(rest, "/"); i >= 0 {
+			sub := strings.TrimSuffix(dir, "/") + "/" + rest[:i]
+			if _, ok := dirs[sub]; !ok {
+				d := &client.Node{Key: sub, Dir: true}
+				if recursive {
+					d.Nodes, _ = m.children(sub, true)
+				}
+				dirs[sub] = d
+				result = append(result, d)
+			}
+			continue
+		}
+		n := *m.nodes[k]
+		result = append(result, &n)
+	}
+	return result, found
+}
+
+func notFound(key string) error {
+	return client.Error{Code: client.ErrorCodeKeyNotFound, Message: "Key not found", Cause: key}
+}
+
+func (m *memKeys) Get(ctx context.Context, key string, opts *client.GetOptions) (*client.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key = m.clean(key)
+	if n := m.lookup(key); n != nil {
+		c := *n
+		return &client.Response{Action: "get", Node: &c, Index: m.index}, nil
+	}
+	recursive := opts != nil && opts.Recursive
+	nodes, ok := m.children(key, recursive)
+	if !ok && key != "/" {
+		return nil, client.Error{Code: client.ErrorCodeKeyNotFound, Message: "Key not found", Cause: key, Index: m.index}
+	}
+	return &client.Response{Action: "get", Node: &client.Node{Key: key, Dir: true, Nodes: nodes}, Index: m.index}, nil
+}
+
+func (m *memKeys) Set(ctx context.Context, key, value string, opts *client.SetOptions) (*client.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key = m.clean(key)
+	if opts == nil {
+		opts = &client.SetOptions{}
+	}
+	prev := m.lookup(key)
+	switch {
+	case opts.PrevExist == client.PrevNoExist && prev != nil:
+		return nil, client.Error{Code: client.ErrorCodeNodeExist, Message: "Key already exists", Cause: key}
+	case (opts.PrevExist == client.PrevExist || opts.PrevIndex != 0 || opts.PrevValue != "") && prev == nil:
+		return nil, notFound(key)
+	case opts.PrevIndex != 0 && prev.ModifiedIndex != opts.PrevIndex,
+		opts.PrevValue != "" && prev.Value != opts.PrevValue:
+		return nil, client.Error{Code: client.ErrorCodeTestFailed, Message: "Compare failed", Cause: key}
+	}
+	m.index++
+	n := &client.Node{Key: key, Value: value, ModifiedIndex: m.index, CreatedIndex: m.index}
+	if prev != nil {
+		n.CreatedIndex = prev.CreatedIndex
+	}
+	if opts.TTL > 0 {
+		t := time.Now().Add(opts.TTL)
+		n.Expiration = &t
+	}
+	m.nodes[key] = n
+	c := *n
+	return &client.Response{Action: "set", Node: &c, PrevNode: prev, Index: m.index}, nil
+}
+
+func (m *memKeys) Delete(ctx context.Context, key string, opts *client.DeleteOptions) (*client.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key = m.clean(key)
+	if opts == nil {
+		opts = &client.DeleteOptions{}
+	}
+	prev := m.lookup(key)
+	if prev == nil {
+		if !opts.Recursive && !opts.Dir {
+			return nil, notFound(key)
+		}
+		nodes, ok := m.children(key, true)
+		if !ok {
+			return nil, notFound(key)
+		}
+		if !opts.Recursive {
+			return nil, client.Error{Code: client.ErrorCodeDirNotEmpty, Message: "Directory not empty", Cause: key}
+		}
+		for k := range m.nodes {
+			if strings.HasPrefix(k, key+"/") {
+				delete(m.nodes, k)
+			}
+		}
+		m.index++
+		return &client.Response{Action: "delete", Node: &client.Node{Key: key, Dir: true}, PrevNode: &client.Node{Key: key, Dir: true, Nodes: nodes}, Index: m.index}, nil
+	}
+	if (opts.PrevIndex != 0 && prev.ModifiedIndex != opts.PrevIndex) || (opts.PrevValue != "" && prev.Value != opts.PrevValue) {
+		return nil, client.Error{Code: client.ErrorCodeTestFailed, Message: "Compare failed", Cause: key}
+	}
+	delete(m.nodes, key)
+	m.index++
+	return &client.Response{Action: "delete", Node: &client.Node{Key: key, ModifiedIndex: m.index}, PrevNode: prev, Index: m.index}, nil
+}
+
+func (m *memKeys) Create(ctx context.Context, key, value string) (*client.Response, error) {
+	return m.Set(ctx, key, value, &client.SetOptions{PrevExist: client.PrevNoExist})
+}
+
+func (m *memKeys) CreateInOrder(ctx context.Context, dir, value string, opts *client.CreateInOrderOptions) (*client.Response, error) {
+	m.mu.Lock()
+	key := fmt.Sprintf("%s/%020d", m.clean(dir), m.index+1)
+	m.mu.Unlock()
+	setOpts := &client.SetOptions{PrevExist: client.PrevNoExist}
+	if opts != nil {
+		setOpts.TTL = opts.TTL
+	}
+	return m.Set(ctx, key, value, setOpts)
+}
+
+func (m *memKeys) Update(ctx context.Context, key, value string) (*client.Response, error) {
+	return m.Set(ctx, key, value, &client.SetOptions{PrevExist: client.PrevExist})
+}
+
+func (m *memKeys) Watcher(key string, opts *client.WatcherOptions) client.Watcher {
+	panic("memKeys: watcher is not supported")
+}
+
+// newTestServer creates a UUIDServer on top of memKeys with the data initialized.
+func newTestServer(t *testing.T, cfg *Config) *UUIDServer {
+	t.Helper()
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "/flake"
+	}
+	svr := &UUIDServer{cfg: cfg, etcdWrap: &EtcdWrap{cfg: &EtcdWrapConfig{}, etcdAPI: newMemKeys()}}
+	if err := svr.initNamespaces(); err != nil {
+		t.Fatal(err)
+	}
+	if err := svr.initPolicy(); err != nil {
+		t.Fatal(err)
+	}
+	if err := svr.initQuotas(); err != nil {
+		t.Fatal(err)
+	}
+	if err := svr.initAuth(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svr.initUUIDData(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return svr
+}
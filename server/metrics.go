@@ -0,0 +1,270 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics the registry of the flake metrics, served on /metrics of the HTTP endpoints.
+var Metrics = prometheus.NewRegistry()
+
+var (
+	fetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "flake_fetch_duration_seconds",
+		Help:    "Latency of Fetch calls by namespace, service and gRPC code.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"namespace", "service", "code"})
+	idsIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flake_ids_issued_total",
+		Help: "Number of IDs issued by Fetch.",
+	}, []string{"namespace", "service"})
+	casConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flake_cas_conflicts_total",
+		Help: "Number of compare-and-swap retries caused by concurrent writers, by operation.",
+	}, []string{"op"})
+	containerReassignments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flake_container_reassignments_total",
+		Help: "Number of containers that got a new container ID because their sequence ran out.",
+	}, []string{"namespace"})
+	storeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flake_store_errors_total",
+		Help: "Number of failed etcd requests, conditions that are not met are not counted.",
+	}, []string{"op"})
+	quotaRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flake_quota_rejections_total",
+		Help: "Number of Fetch calls rejected by a quota, by the kind of the quota.",
+	}, []string{"kind"})
+	ledgerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flake_ledger_errors_total",
+		Help: "Number of issued ranges that could not be written to the ledger, by the kind of the ledger.",
+	}, []string{"ledger"})
+	serviceIDsRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flake_service_ids_remaining",
+		Help: "Number of service IDs that can still be assigned.",
+	}, []string{"namespace"})
+	containerIDsRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flake_container_ids_remaining",
+		Help: "Number of container IDs that can still be assigned.",
+	}, []string{"namespace"})
+	sequenceRemaining = newSequenceCapacity()
+)
+
+func init() {
+	Metrics.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		fetchDuration, idsIssued, casConflicts, containerReassignments, storeErrors, quotaRejections, ledgerErrors,
+		serviceIDsRemaining, containerIDsRemaining, sequenceRemaining)
+}
+
+// maxMetricServices how many service names get their own series, the others are
+// labeled without the name.
+var maxMetricServices int32 = 1000
+
+// knownServices the services this process issued IDs for. Failed calls of other
+// names are labeled without the name, so clients can not create series at will.
+var (
+	knownServices     sync.Map
+	knownServiceCount atomic.Int32
+)
+
+// metricService returns the service label of a call, "" for the names that
+// failed or came after maxMetricServices.
+func metricService(ns *namespace, serviceName string, err error) string {
+	key := ns.name + "/" + serviceName
+	if _, ok := knownServices.Load(key); ok {
+		return serviceName
+	}
+	if err != nil {
+		return ""
+	}
+	if knownServiceCount.Add(1) > maxMetricServices {
+		knownServiceCount.Add(-1)
+		return ""
+	}
+	if _, loaded := knownServices.LoadOrStore(key, true); loaded {
+		knownServiceCount.Add(-1)
+	}
+	return serviceName
+}
+
+// observeFetch records a finished Fetch.
+func observeFetch(ns *namespace, serviceName string, start time.Time, err error) {
+	if ns == nil {
+		ns = &namespace{}
+	}
+	service := metricService(ns, serviceName, err)
+	fetchDuration.WithLabelValues(ns.name, service, status.Code(err).String()).Observe(time.Since(start).Seconds())
+}
+
+// countConflict counts the retry of a conditional write, err is the reason of the retry.
+func countConflict(op string, err error) {
+	if isConditionFailed(err) {
+		casConflicts.WithLabelValues(op).Inc()
+	}
+}
+
+// countStoreError counts err if it is not a condition that is not met.
+func countStoreError(op string, err error) error {
+	if err != nil && !isConditionFailed(err) && !client.IsKeyNotFound(err) {
+		storeErrors.WithLabelValues(op).Inc()
+	}
+	return err
+}
+
+func isConditionFailed(err error) bool {
+	if cErr, ok := err.(client.Error); ok {
+		return cErr.Code == client.ErrorCodeNodeExist || cErr.Code == client.ErrorCodeTestFailed
+	}
+	return false
+}
+
+// observeSegment records a segment [startID, endID) issued to a service.
+func (s *UUIDServer) observeSegment(ns *namespace, serviceName string, startID int, endID int) {
+	service := metricService(ns, serviceName, nil)
+	idsIssued.WithLabelValues(ns.name, service).Add(float64(endID - startID))
+	sequenceRemaining.observe(ns, service, remaining(ns.layout.MaxSequence(), endID-1))
+}
+
+// updateCapacity sets the gauges of the service and container IDs left in the namespace.
+func updateCapacity(ns *namespace, maxServiceID int, maxContainerID int) {
+	serviceIDsRemaining.WithLabelValues(ns.name).Set(float64(remaining(ns.layout.MaxServiceID(), maxServiceID)))
+	containerIDsRemaining.WithLabelValues(ns.name).Set(float64(remaining(ns.layout.MaxContainerID(), maxContainerID)))
+}
+
+// remaining returns how many values are left below the exclusive bound after last.
+func remaining(bound int, last int) int {
+	if last >= bound-1 {
+		return 0
+	}
+	return bound - 1 - last
+}
+
+// sequenceWindow how long the lowest sequence left of a service is kept.
+const sequenceWindow = time.Minute
+
+// sequenceCapacity reports the lowest sequence left in a container of each service
+// seen in the current window, a service that is not fetched for two windows is dropped.
+type sequenceCapacity struct {
+	desc *prometheus.Desc
+	lock sync.Mutex
+	lows map[[2]string]*sequenceLow
+}
+
+type sequenceLow struct {
+	left  int
+	start time.Time
+}
+
+func newSequenceCapacity() *sequenceCapacity {
+	return &sequenceCapacity{
+		desc: prometheus.NewDesc("flake_sequence_remaining",
+			"Lowest number of sequence values left in a container of the service in the last minute.",
+			[]string{"namespace", "service"}, nil),
+		lows: make(map[[2]string]*sequenceLow),
+	}
+}
+
+func (c *sequenceCapacity) observe(ns *namespace, serviceName string, left int) {
+	key := [2]string{ns.name, serviceName}
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	low, ok := c.lows[key]
+	if !ok || now.Sub(low.start) > sequenceWindow {
+		c.lows[key] = &sequenceLow{left: left, start: now}
+	} else if left < low.left {
+		low.left = left
+	}
+}
+
+func (c *sequenceCapacity) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *sequenceCapacity) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, low := range c.lows {
+		if now.Sub(low.start) > 2*sequenceWindow {
+			delete(c.lows, key)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(low.left), key[0], key[1])
+	}
+}
+
+// instrumentedKeys traces each request of the etcd keys API and counts the failed ones.
+type instrumentedKeys struct {
+	client.KeysAPI
+	tracer trace.Tracer
+}
+
+func newInstrumentedKeys(keys client.KeysAPI, tp trace.TracerProvider) instrumentedKeys {
+	return instrumentedKeys{KeysAPI: keys, tracer: tracerFrom(tp)}
+}
+
+// start begins the span of a request, requests outside of a trace such as the
+// health checks are not traced.
+func (k instrumentedKeys) start(ctx context.Context, op string, key string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return k.tracer.Start(ctx, "etcd."+op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "etcd"), attribute.String("etcd.key", key)))
+}
+
+// end counts and records the error of a request, conditions that are not met
+// are normal for compare-and-swap and do not fail the span.
+func (k instrumentedKeys) end(span trace.Span, op string, err error) error {
+	if err != nil && (isConditionFailed(err) || client.IsKeyNotFound(err)) {
+		span.SetAttributes(attribute.String("etcd.condition", err.Error()))
+		span.End()
+		return err
+	}
+	endSpan(span, countStoreError(op, err))
+	return err
+}
+
+func (k instrumentedKeys) Get(ctx context.Context, key string, opts *client.GetOptions) (*client.Response, error) {
+	ctx, span := k.start(ctx, "get", key)
+	r, err := k.KeysAPI.Get(ctx, key, opts)
+	return r, k.end(span, "get", err)
+}
+
+func (k instrumentedKeys) Set(ctx context.Context, key, value string, opts *client.SetOptions) (*client.Response, error) {
+	ctx, span := k.start(ctx, "set", key)
+	r, err := k.KeysAPI.Set(ctx, key, value, opts)
+	return r, k.end(span, "set", err)
+}
+
+func (k instrumentedKeys) Delete(ctx context.Context, key string, opts *client.DeleteOptions) (*client.Response, error) {
+	ctx, span := k.start(ctx, "delete", key)
+	r, err := k.KeysAPI.Delete(ctx, key, opts)
+	return r, k.end(span, "delete", err)
+}
+
+func (k instrumentedKeys) Create(ctx context.Context, key, value string) (*client.Response, error) {
+	ctx, span := k.start(ctx, "create", key)
+	r, err := k.KeysAPI.Create(ctx, key, value)
+	return r, k.end(span, "create", err)
+}
+
+func (k instrumentedKeys) CreateInOrder(ctx context.Context, dir, value string, opts *client.CreateInOrderOptions) (*client.Response, error) {
+	ctx, span := k.start(ctx, "create_in_order", dir)
+	r, err := k.KeysAPI.CreateInOrder(ctx, dir, value, opts)
+	return r, k.end(span, "create_in_order", err)
+}
+
+func (k instrumentedKeys) Update(ctx context.Context, key, value string) (*client.Response, error) {
+	ctx, span := k.start(ctx, "update", key)
+	r, err := k.KeysAPI.Update(ctx, key, value)
+	return r, k.end(span, "update", err)
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/net/context"
+)
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t, &Config{ListenAddress: "127.0.0.1:0", HTTPListenAddress: "127.0.0.1:0",
+		Namespaces: []NamespaceConfig{{Name: "tiny", Prefix: "/tiny", Layout: util.Layout{ServiceBits: 31, ContainerBits: 29, SequenceBits: 3}}}})
+
+	issued := testutil.ToFloat64(idsIssued.WithLabelValues("", "TestMetrics"))
+	_, err := s.Fetch(context.Background(), &api.FetchRequest{ServiceName: "TestMetrics", ContainerName: "c", NeedCount: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(idsIssued.WithLabelValues("", "TestMetrics")) - issued; got != 25 {
+		t.Fatalf("want 25 IDs issued, got %v", got)
+	}
+
+	// 8 sequence values per container, 10 IDs need a new container
+	reassigned := testutil.ToFloat64(containerReassignments.WithLabelValues("tiny"))
+	_, err = s.Fetch(context.Background(), &api.FetchRequest{ServiceName: "TestMetrics", ContainerName: "c", NeedCount: 10, Namespace: "tiny"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(containerReassignments.WithLabelValues("tiny")) - reassigned; got != 1 {
+		t.Fatalf("want 1 reassignment, got %v", got)
+	}
+
+	s.updateHealth()
+	if got := testutil.ToFloat64(containerIDsRemaining.WithLabelValues("tiny")); got != float64(1<<29-1-StartOfContainerID-2) {
+		t.Fatalf("unexpected container capacity %v", got)
+	}
+
+	failed := testutil.ToFloat64(storeErrors.WithLabelValues("set"))
+	keys := s.etcdWrap.etcdAPI
+	s.etcdWrap.etcdAPI = newInstrumentedKeys(readOnlyKeys{keys.(*memKeys)}, nil)
+	s.etcdWrap.Set(context.Background(), "/flake/x", "1", nil)
+	s.etcdWrap.etcdAPI = keys
+	if got := testutil.ToFloat64(storeErrors.WithLabelValues("set")) - failed; got != 1 {
+		t.Fatalf("want 1 store error, got %v", got)
+	}
+
+	if err := s.initGRPC(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.initHTTP(); err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	defer s.Shutdown(context.Background())
+	r, err := http.Get("http://" + s.HTTPAddr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"flake_fetch_duration_seconds_count", "flake_ids_issued_total", "flake_service_ids_remaining", "flake_sequence_remaining"} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("%v is missing from /metrics", name)
+		}
+	}
+}
+
+func TestMetricServiceCap(t *testing.T) {
+	s := newTestServer(t, nil)
+	ns := s.namespaces[""]
+	defer func(max int32) { maxMetricServices = max }(maxMetricServices)
+	maxMetricServices = knownServiceCount.Load() + 1
+
+	if got := metricService(ns, "TestMetricServiceCap-a", nil); got != "TestMetricServiceCap-a" {
+		t.Fatalf("the first name below the cap: got %q", got)
+	}
+	if got := metricService(ns, "TestMetricServiceCap-b", nil); got != "" {
+		t.Fatalf("a name above the cap: got %q", got)
+	}
+	if got := metricService(ns, "TestMetricServiceCap-a", errors.New("failed")); got != "TestMetricServiceCap-a" {
+		t.Fatalf("a known name: got %q", got)
+	}
+
+	// the IDs of the names above the cap are counted without the name
+	issued := testutil.ToFloat64(idsIssued.WithLabelValues("", ""))
+	if _, err := s.Fetch(context.Background(), &api.FetchRequest{ServiceName: "TestMetricServiceCap-c", ContainerName: "c", NeedCount: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(idsIssued.WithLabelValues("", "")) - issued; got != 5 {
+		t.Fatalf("want 5 IDs without the name, got %v", got)
+	}
+}
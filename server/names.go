@@ -0,0 +1,376 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxNameLength the maximum length in bytes of a service, alias or container name.
+const MaxNameLength = 256
+
+// emptyNameKey the key of the empty name, a single "%" is never the escape of a name.
+const emptyNameKey = "%"
+
+// checkName rejects names that are too long, not UTF-8 or hold control characters.
+func checkName(kind string, name string) error {
+	switch {
+	case len(name) > MaxNameLength:
+		return status.Errorf(codes.InvalidArgument, "%v name is longer than %d bytes", kind, MaxNameLength)
+	case !utf8.ValidString(name):
+		return status.Errorf(codes.InvalidArgument, "%v name %q is not valid UTF-8", kind, name)
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return status.Errorf(codes.InvalidArgument, "%v name %q holds control characters", kind, name)
+	}
+	return nil
+}
+
+// escapeName turns a name into a single key part. "%", "/" and a leading "_" are
+// escaped as %XX, etcd hides the keys starting with "_" from the lists. The
+// names "." and ".." are %2E and %2E%2E, the empty name is "%". Other names are
+// kept as they are, so the keys of the names stored before stay valid.
+func escapeName(name string) string {
+	switch name {
+	case "":
+		return emptyNameKey
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	}
+	if !strings.ContainsAny(name, "%/") && name[0] != '_' {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '%', c == '/', c == '_' && i == 0:
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// unescapeName returns the name of a key part written by escapeName.
+func unescapeName(part string) string {
+	if part == emptyNameKey {
+		return ""
+	}
+	if !strings.Contains(part, "%") {
+		return part
+	}
+	var b strings.Builder
+	for i := 0; i < len(part); i++ {
+		if part[i] == '%' && i+2 < len(part) && isHex(part[i+1]) && isHex(part[i+2]) {
+			b.WriteByte(unhex(part[i+1])<<4 | unhex(part[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(part[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'A' <= c && c <= 'F' || 'a' <= c && c <= 'f'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
+
+// nameKey returns the key of a name in a directory of the namespace.
+func (ns *namespace) nameKey(dir string, name string) string {
+	return ns.key(dir, escapeName(name))
+}
+
+// nameOfKey returns the name of a key returned by nameKey.
+func nameOfKey(key string) string {
+	return unescapeName(lastKeyPart(key))
+}
+
+// normalizePrefix cleans a key prefix, "/flake/" and "flake" are "/flake" and the root is "".
+func normalizePrefix(prefix string) string {
+	return strings.TrimSuffix(path.Clean("/"+prefix), "/")
+}
+
+// escapeNames the upgrade to schema 2. The names of schema 1 are the raw key
+// parts, a name with "/" is a nested key. Every key of a name is rewritten
+// through escapeName: the keys are copied to a staging directory first, then
+// the copied keys are deleted and the staging directory is moved in place. etcd
+// does not list a key part starting with "_", such names are found through the
+// ID directories and the aliases, a key the upgrade does not find is kept. The
+// staging directory records the phases that are done, so a run after a failure
+// never escapes a key twice.
+func escapeNames(ctx context.Context, w *EtcdWrap, ns *namespace) error {
+	staging := ns.key(KeyOfUpgradeDir, "2")
+	_, err := w.Get(ctx, staging+"/copied")
+	if err != nil && !w.IsKeyNotFound(err) {
+		return err
+	}
+	if err != nil {
+		// every copied key is recorded, only those are deleted afterwards.
+		// An empty key drops the node, it is deleted without a copy.
+		stage := func(node *client.Node, key string) error {
+			source := strings.TrimPrefix(node.Key, ns.prefix+"/")
+			if _, err := w.Set(ctx, staging+"/source/"+escapeName(source), "", nil); err != nil {
+				return err
+			}
+			if key == "" {
+				return nil
+			}
+			return copyKey(ctx, w, node, key)
+		}
+		services, containers, err := hiddenNames(ctx, w, ns)
+		if err != nil {
+			return err
+		}
+		for _, dir := range []string{KeyOfServiceDir, KeyOfAliasDir} {
+			err := walkNames(ctx, w, ns.key(dir), "", func(name string, node *client.Node) error {
+				return stage(node, staging+"/"+dir+"/"+escapeName(name))
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, name := range services {
+			r, err := w.Get(ctx, ns.key(KeyOfServiceDir)+"/"+name)
+			if err != nil && !w.IsKeyNotFound(err) {
+				return err
+			}
+			if err == nil && !r.Node.Dir {
+				if err := stage(r.Node, staging+"/"+KeyOfServiceDir+"/"+escapeName(name)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := copyContainers(ctx, w, ns, containers, staging+"/"+KeyOfContainerDir, stage); err != nil {
+			return err
+		}
+		gapless := func(name string, sub string, node *client.Node) error {
+			return stage(node, staging+"/"+KeyOfGaplessDir+"/"+escapeName(name)+"/"+sub)
+		}
+		if err := walkGapless(ctx, w, ns.key(KeyOfGaplessDir), "", gapless); err != nil {
+			return err
+		}
+		for _, name := range services {
+			if err := walkGapless(ctx, w, ns.key(KeyOfGaplessDir)+"/"+name, name, gapless); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Set(ctx, staging+"/copied", "true", nil); err != nil {
+			return err
+		}
+	}
+
+	err = walkNames(ctx, w, staging+"/source", "", func(name string, node *client.Node) error {
+		source := unescapeName(name)
+		if _, err := w.Delete(ctx, ns.prefix+"/"+source); err != nil && !w.IsKeyNotFound(err) {
+			return err
+		}
+		// a name of schema 2 may not be a directory left by the nested keys
+		for dir := path.Dir(source); strings.Contains(dir, "/"); dir = path.Dir(dir) {
+			if err := w.DeleteEmptyDir(ctx, ns.prefix+"/"+dir); err != nil {
+				return err
+			}
+		}
+		_, err := w.Delete(ctx, node.Key)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{KeyOfServiceDir, KeyOfAliasDir, KeyOfContainerDir, KeyOfGaplessDir} {
+		err = walkNames(ctx, w, staging+"/"+dir, "", func(name string, node *client.Node) error {
+			return moveKey(ctx, w, node, ns.key(dir)+"/"+name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return w.DeleteDir(ctx, staging)
+}
+
+// hiddenNames returns the service and container names of schema 1 that hold a
+// key part starting with "_", etcd does not list them. The services are taken
+// from the owners of the service IDs and the canonical names of the aliases,
+// the containers from the owners of the container IDs.
+func hiddenNames(ctx context.Context, w *EtcdWrap, ns *namespace) ([]string, []string, error) {
+	values := func(dirs ...string) ([]string, error) {
+		var names []string
+		seen := make(map[string]bool)
+		for _, dir := range dirs {
+			err := walkNames(ctx, w, ns.key(dir), "", func(_ string, node *client.Node) error {
+				if !seen[node.Value] && isHiddenName(node.Value) {
+					seen[node.Value] = true
+					names = append(names, node.Value)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return names, nil
+	}
+	services, err := values(KeyOfServiceIDDir, KeyOfAliasDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	containers, err := values(KeyOfContainerIDDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return services, containers, nil
+}
+
+// isHiddenName reports whether a name of schema 1 holds a key part starting with "_".
+func isHiddenName(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, "_") {
+			return true
+		}
+	}
+	return false
+}
+
+// copyContainers copies the container keys and the hidden container names to
+// dir. Clients of schema 1 sent the newline of the shell output, such a name is
+// trimmed as Fetch does now, so the container keeps its ID. The key of the
+// trimmed name wins if both exist, the other one is dropped.
+func copyContainers(ctx context.Context, w *EtcdWrap, ns *namespace, hidden []string, dir string, stage func(node *client.Node, key string) error) error {
+	var names []string
+	nodes := make(map[string]*client.Node)
+	err := walkNames(ctx, w, ns.key(KeyOfContainerDir), "", func(name string, node *client.Node) error {
+		names = append(names, name)
+		nodes[name] = node
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range hidden {
+		for _, name := range []string{name, strings.TrimRight(name, "\r\n")} {
+			if _, ok := nodes[name]; ok {
+				continue
+			}
+			r, err := w.Get(ctx, ns.key(KeyOfContainerDir)+"/"+name)
+			if err != nil && !w.IsKeyNotFound(err) {
+				return err
+			}
+			if err == nil && !r.Node.Dir {
+				names = append(names, name)
+				nodes[name] = r.Node
+			}
+		}
+	}
+	copied := make(map[string]bool)
+	for _, name := range names {
+		trimmed := strings.TrimRight(name, "\r\n")
+		if trimmed != name {
+			if _, ok := nodes[trimmed]; ok || copied[trimmed] {
+				if err := stage(nodes[name], ""); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		copied[trimmed] = true
+		if err := stage(nodes[name], dir+"/"+escapeName(trimmed)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkNames calls f with every key under dir, the name is the path below dir.
+func walkNames(ctx context.Context, w *EtcdWrap, dir string, parent string, f func(name string, node *client.Node) error) error {
+	nodes, err := w.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		name := path.Join(parent, lastKeyPart(node.Key))
+		if node.Dir {
+			err = walkNames(ctx, w, node.Key, name, f)
+		} else {
+			err = f(name, node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkGapless calls f with every key of the gapless numbers of schema 1 under dir,
+// the service name is the path of the directory that holds max_number or
+// reserved, sub is the path of the key below it.
+func walkGapless(ctx context.Context, w *EtcdWrap, dir string, parent string, f func(name string, sub string, node *client.Node) error) error {
+	nodes, err := w.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		part := lastKeyPart(node.Key)
+		switch {
+		case len(parent) > 0 && part == KeyOfMaxNumber && !node.Dir:
+			err = f(parent, part, node)
+		case len(parent) > 0 && part == KeyOfReservedDir && node.Dir:
+			err = walkNames(ctx, w, node.Key, KeyOfReservedDir, func(sub string, leaf *client.Node) error {
+				return f(parent, sub, leaf)
+			})
+		case node.Dir:
+			err = walkGapless(ctx, w, node.Key, path.Join(parent, part), f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyKey writes the value and the TTL of node to key.
+func copyKey(ctx context.Context, w *EtcdWrap, node *client.Node, key string) error {
+	_, err := w.Set(ctx, key, node.Value, &client.SetOptions{TTL: time.Duration(node.TTL) * time.Second})
+	return err
+}
+
+// moveKey writes the value of node to key and deletes node. A key that holds
+// the same value already is kept, another value is an error.
+func moveKey(ctx context.Context, w *EtcdWrap, node *client.Node, key string) error {
+	_, err := w.Set(ctx, key, node.Value, &client.SetOptions{PrevExist: client.PrevNoExist, TTL: time.Duration(node.TTL) * time.Second})
+	if err != nil {
+		if !w.IsKeyExist(err) {
+			return err
+		}
+		r, err := w.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if r.Node.Value != node.Value {
+			return fmt.Errorf("can not move %v to %v, it holds %q", node.Key, key, r.Node.Value)
+		}
+	}
+	_, err = w.CompareAndDelete(ctx, node.Key, node.ModifiedIndex)
+	if err != nil && !w.IsKeyNotFound(err) {
+		return err
+	}
+	return nil
+}
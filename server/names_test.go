@@ -0,0 +1,257 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEscapeName(t *testing.T) {
+	for name, want := range map[string]string{
+		"order":    "order",
+		"订单":       "订单",
+		"":         "%",
+		".":        "%2E",
+		"..":       "%2E%2E",
+		"...":      "...",
+		"a/b":      "a%2Fb",
+		"50%":      "50%25",
+		"%2F":      "%252F",
+		"a/../b":   "a%2F..%2Fb",
+		"user:1@x": "user:1@x",
+		"_billing": "%5Fbilling",
+		"a/_b":     "a%2F_b",
+		"a_b":      "a_b",
+	} {
+		if got := escapeName(name); got != want {
+			t.Errorf("escapeName(%q) = %q, want %q", name, got, want)
+		}
+		if got := unescapeName(escapeName(name)); got != name {
+			t.Errorf("unescapeName(escapeName(%q)) = %q", name, got)
+		}
+	}
+
+	for name, ok := range map[string]bool{
+		"order":                              true,
+		"a/b":                                true,
+		"bad\x00":                            false,
+		"tab\t":                              false,
+		"\xff":                               false,
+		strings.Repeat("x", MaxNameLength):   true,
+		strings.Repeat("x", MaxNameLength+1): false,
+	} {
+		if err := checkName("service", name); (err == nil) != ok {
+			t.Errorf("checkName(%q) = %v", name, err)
+		}
+	}
+
+	for prefix, want := range map[string]string{"/flake/": "/flake", "flake": "/flake", "//a//b/": "/a/b", "/": "", "": ""} {
+		if got := normalizePrefix(prefix); got != want {
+			t.Errorf("normalizePrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, &Config{Prefix: "/flake/"})
+	ns := s.namespaces[""]
+	if ns.prefix != "/flake" {
+		t.Fatalf("prefix %q is not normalized", ns.prefix)
+	}
+
+	// names that used to nest or collide get their own IDs
+	ids := make(map[int32]string)
+	for _, name := range []string{"a", "a/b", "..", ".", "50%", "50%25"} {
+		reply, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: name, ContainerName: name, NeedCount: 1})
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		id := reply.Items[0].ServiceId
+		if other, ok := ids[id]; ok {
+			t.Fatalf("%q and %q have service ID %d", name, other, id)
+		}
+		ids[id] = name
+	}
+	if _, err := s.etcdWrap.Get(ctx, "/flake/service/a%2Fb"); err != nil {
+		t.Fatalf("escaped key: %v", err)
+	}
+	reply, err := s.ListServices(ctx, &api.ListRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Services) != len(ids) {
+		t.Fatalf("want %d services, got %v", len(ids), reply.Services)
+	}
+	for _, info := range reply.Services {
+		if ids[info.ServiceId] != info.Name {
+			t.Fatalf("service %q has ID %d, want %q", info.Name, info.ServiceId, ids[info.ServiceId])
+		}
+	}
+
+	// the empty container name has its own key
+	if _, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: "order", NeedCount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.etcdWrap.Get(ctx, ns.key(KeyOfContainerDir, emptyNameKey)); err != nil {
+		t.Fatalf("empty container name: %v", err)
+	}
+	if _, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: "bad\x01", NeedCount: 1}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("control characters: want InvalidArgument, got %v", err)
+	}
+	if _, err := s.Reserve(ctx, &api.ReserveRequest{ServiceName: strings.Repeat("x", MaxNameLength+1)}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("long name: want InvalidArgument, got %v", err)
+	}
+}
+
+// failingKeys fails the writes after the given number, a negative number never fails.
+type failingKeys struct {
+	client.KeysAPI
+	writes int
+}
+
+func (k *failingKeys) write() error {
+	if k.writes == 0 {
+		return fmt.Errorf("injected failure")
+	}
+	k.writes--
+	return nil
+}
+
+func (k *failingKeys) Set(ctx context.Context, key, value string, opts *client.SetOptions) (*client.Response, error) {
+	if err := k.write(); err != nil {
+		return nil, err
+	}
+	return k.KeysAPI.Set(ctx, key, value, opts)
+}
+
+func (k *failingKeys) Delete(ctx context.Context, key string, opts *client.DeleteOptions) (*client.Response, error) {
+	if err := k.write(); err != nil {
+		return nil, err
+	}
+	return k.KeysAPI.Delete(ctx, key, opts)
+}
+
+func TestEscapeNamesUpgrade(t *testing.T) {
+	ctx := context.Background()
+	// keys of schema 1, the names were written as they are
+	schema1 := map[string]string{
+		"service/order":                 "11",
+		"service/a/b":                   "12",
+		"service/50%":                   "13",
+		"service/a%2Fb":                 "14",
+		"service/x/y":                   "12",
+		"alias/x/y":                     "a/b",
+		"container/c/1":                 "11",
+		"container/c2\n":                "12",
+		"container/c3\r\n":              "13",
+		"container/c3":                  "14",
+		"gapless/order/max_number":      "3",
+		"gapless/a/max_number":          "7",
+		"gapless/a/b/max_number":        "5",
+		"gapless/a/b/reserved/4":        `{"token":"t"}`,
+		"gapless/reserved/x/max_number": "2",
+		// etcd does not list the hidden keys, a part starting with "_"
+		"service/_billing":            "15",
+		"serviceid/15":                "_billing",
+		"gapless/_billing/max_number": "9",
+		"container/_c4":               "15",
+		"containerid/15":              "_c4",
+		"gapless/_unknown/max_number": "1",
+	}
+	want := map[string]string{
+		"service/order":                   "11",
+		"service/a%2Fb":                   "12",
+		"service/50%25":                   "13",
+		"service/a%252Fb":                 "14",
+		"service/x%2Fy":                   "12",
+		"alias/x%2Fy":                     "a/b",
+		"container/c%2F1":                 "11",
+		"container/c2":                    "12",
+		"container/c3":                    "14",
+		"gapless/order/max_number":        "3",
+		"gapless/a/max_number":            "7",
+		"gapless/a%2Fb/max_number":        "5",
+		"gapless/a%2Fb/reserved/4":        `{"token":"t"}`,
+		"gapless/reserved%2Fx/max_number": "2",
+		"service/%5Fbilling":              "15",
+		"gapless/%5Fbilling/max_number":   "9",
+		"container/%5Fc4":                 "15",
+	}
+
+	// the step fails after every number of writes in turn and is run again
+	var s *UUIDServer
+	for fail := 0; ; fail++ {
+		s = newTestServer(t, nil)
+		ns := s.namespaces[""]
+		for key, value := range schema1 {
+			if _, err := s.etcdWrap.Set(ctx, ns.prefix+"/"+key, value, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		keys := &failingKeys{KeysAPI: s.etcdWrap.etcdAPI, writes: fail}
+		s.etcdWrap.etcdAPI = keys
+		err := escapeNames(ctx, s.etcdWrap, ns)
+		keys.writes = -1
+		if err != nil {
+			if err := escapeNames(ctx, s.etcdWrap, ns); err != nil {
+				t.Fatalf("failure after %d writes: %v", fail, err)
+			}
+		}
+		for key, value := range want {
+			r, err := s.etcdWrap.Get(ctx, ns.prefix+"/"+key)
+			if err != nil {
+				t.Fatalf("failure after %d writes, %v: %v", fail, key, err)
+			}
+			if r.Node.Value != value {
+				t.Fatalf("failure after %d writes, %v holds %q, want %q", fail, key, r.Node.Value, value)
+			}
+		}
+		for _, dir := range []string{KeyOfServiceDir, KeyOfAliasDir, KeyOfContainerDir, KeyOfGaplessDir, KeyOfUpgradeDir} {
+			err := walkNames(ctx, s.etcdWrap, ns.key(dir), dir, func(name string, node *client.Node) error {
+				if _, ok := want[name]; !ok {
+					return fmt.Errorf("%v is left", node.Key)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failure after %d writes: %v", fail, err)
+			}
+		}
+		// a hidden key the upgrade does not find is kept
+		if _, err := s.etcdWrap.Get(ctx, ns.key(KeyOfGaplessDir, "_unknown", KeyOfMaxNumber)); err != nil {
+			t.Fatalf("failure after %d writes: %v", fail, err)
+		}
+		if err == nil {
+			break
+		}
+	}
+
+	// the moved names are found again
+	info, err := s.GetService(ctx, &api.GetServiceRequest{ServiceName: "x/y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CanonicalName != "a/b" {
+		t.Fatalf("alias x/y: got %+v", info)
+	}
+	if info, err := s.GetService(ctx, &api.GetServiceRequest{ServiceName: "a%2Fb"}); err != nil || info.ServiceId != 14 {
+		t.Fatalf("the literal name a%%2Fb of schema 1: got %+v %v", info, err)
+	}
+	if reply, err := s.Reserve(ctx, &api.ReserveRequest{ServiceName: "a/b"}); err != nil || reply.Number != 6 && reply.Number != 4 {
+		t.Fatalf("gapless a/b: got %v %v", reply, err)
+	}
+	if info, err := s.GetService(ctx, &api.GetServiceRequest{ServiceName: "_billing"}); err != nil || info.ServiceId != 15 {
+		t.Fatalf("hidden service _billing: got %+v %v", info, err)
+	}
+	if reply, err := s.Reserve(ctx, &api.ReserveRequest{ServiceName: "_billing"}); err != nil || reply.Number != 10 {
+		t.Fatalf("hidden gapless _billing: got %v %v", reply, err)
+	}
+}
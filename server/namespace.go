@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cnwinds/flake/util"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NamespaceConfig maps a namespace to its own key prefix and layout.
+// Every namespace has independent service and container ID spaces.
+type NamespaceConfig struct {
+	// Name the namespace given in the requests.
+	Name string
+	// Prefix path prefix saved in the etcd.
+	Prefix string
+	// Layout the bits of the UUID parts, the server layout is used if it is zero.
+	Layout util.Layout
+}
+
+// ParseNamespace parse a namespace written as "name=prefix" or "name=prefix@service/container/sequence".
+func ParseNamespace(spec string) (NamespaceConfig, error) {
+	nc := NamespaceConfig{}
+	i := strings.Index(spec, "=")
+	if i <= 0 || i == len(spec)-1 {
+		return nc, fmt.Errorf("invalid namespace %q, want name=prefix[@layout]", spec)
+	}
+	nc.Name, nc.Prefix = spec[:i], spec[i+1:]
+	if j := strings.LastIndex(nc.Prefix, "@"); j >= 0 {
+		layout, err := util.ParseLayout(nc.Prefix[j+1:])
+		if err != nil {
+			return nc, err
+		}
+		nc.Prefix, nc.Layout = nc.Prefix[:j], layout
+	}
+	return nc, nil
+}
+
+type namespace struct {
+	name   string
+	prefix string
+	layout util.Layout
+	// schema the schema checked at the start, 0 if it was not checked
+	schema int
+}
+
+// key joins the parts to a key under the namespace prefix.
+func (ns *namespace) key(parts ...string) string {
+	return ns.prefix + "/" + strings.Join(parts, "/")
+}
+
+func (s *UUIDServer) initNamespaces() error {
+	layout := s.cfg.Layout
+	if layout.IsZero() {
+		layout = util.DefaultLayout
+	}
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+
+	s.namespaces = map[string]*namespace{"": {prefix: normalizePrefix(s.cfg.Prefix), layout: layout}}
+	for _, nc := range s.cfg.Namespaces {
+		if len(nc.Name) == 0 || len(nc.Prefix) == 0 {
+			return fmt.Errorf("namespace %q needs a name and a prefix", nc.Name)
+		}
+		if _, ok := s.namespaces[nc.Name]; ok {
+			return fmt.Errorf("namespace %q is defined twice", nc.Name)
+		}
+		ns := &namespace{name: nc.Name, prefix: normalizePrefix(nc.Prefix), layout: nc.Layout}
+		if ns.layout.IsZero() {
+			ns.layout = layout
+		}
+		if err := ns.layout.Validate(); err != nil {
+			return fmt.Errorf("namespace %q: %v", nc.Name, err)
+		}
+		for _, other := range s.namespaces {
+			if prefixOverlap(ns.prefix, other.prefix) {
+				return fmt.Errorf("prefix %q of namespace %q overlaps prefix %q of namespace %q", ns.prefix, ns.name, other.prefix, other.name)
+			}
+		}
+		s.namespaces[nc.Name] = ns
+	}
+	return nil
+}
+
+// namespace returns the namespace of a request, "" is the default namespace.
+func (s *UUIDServer) namespace(name string) (*namespace, error) {
+	ns, ok := s.namespaces[name]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown namespace %q", name)
+	}
+	return ns, nil
+}
+
+// prefixOverlap returns true if the keys under one prefix can be reached from the other.
+func prefixOverlap(a, b string) bool {
+	a, b = path.Clean("/"+a), path.Clean("/"+b)
+	return a == b || a == "/" || b == "/" || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
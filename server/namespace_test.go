@@ -0,0 +1,56 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNamespaces(t *testing.T) {
+	tenant, err := ParseNamespace("tenant=/flake-tenant@12/20/31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each part needs room above the reserved IDs
+	for _, spec := range []string{"tiny=/tiny@3/29/31", "tiny=/tiny@29/3/31", "tiny=/tiny@31/31/1"} {
+		if _, err := ParseNamespace(spec); err == nil {
+			t.Fatalf("%v: the layout must be rejected", spec)
+		}
+	}
+	s := newTestServer(t, &Config{Namespaces: []NamespaceConfig{tenant}})
+	ctx := context.Background()
+
+	fetch := func(namespace string, service string) *api.FetchReply {
+		r, err := s.Fetch(ctx, &api.FetchRequest{Namespace: namespace, ServiceName: service, ContainerName: "c1", NeedCount: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	// the first service of each namespace gets the same IDs from its own space
+	d1, t1 := fetch("", "order"), fetch("tenant", "user")
+	if d1.Items[0].ServiceId != t1.Items[0].ServiceId || d1.Items[0].ContainerId != t1.Items[0].ContainerId {
+		t.Fatalf("namespaces must have independent ID spaces: %v, %v", d1.Items[0], t1.Items[0])
+	}
+	if d1.Layout.ServiceBits != int32(util.DefaultLayout.ServiceBits) || t1.Layout.ServiceBits != 12 {
+		t.Fatalf("unexpected layouts %v, %v", d1.Layout, t1.Layout)
+	}
+	if t2 := fetch("tenant", "order"); t2.Items[0].ServiceId == t1.Items[0].ServiceId {
+		t.Fatalf("order and user share service ID %v in tenant", t2.Items[0].ServiceId)
+	}
+
+	if _, err := s.Fetch(ctx, &api.FetchRequest{Namespace: "unknown", ServiceName: "order", NeedCount: 1}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unknown namespace: want InvalidArgument, got %v", err)
+	}
+
+	overlap := &UUIDServer{cfg: &Config{Prefix: "/flake/", Namespaces: []NamespaceConfig{{Name: "nested", Prefix: "/flake/nested"}}}}
+	if err := overlap.initNamespaces(); err == nil {
+		t.Fatal("nested prefixes must be rejected")
+	}
+}
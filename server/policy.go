@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// PolicyOpen any service name gets an ID on its first fetch.
+	PolicyOpen = "open"
+	// PolicyAllowlist only names matching ServiceAllowlist get an ID on their first fetch.
+	PolicyAllowlist = "allowlist"
+	// PolicyRegistered only names registered through the admin API can be fetched.
+	PolicyRegistered = "registered"
+)
+
+// namePatterns a set of regular expressions a whole name must match.
+type namePatterns []*regexp.Regexp
+
+func compileNamePatterns(patterns []string) (namePatterns, error) {
+	result := make(namePatterns, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %v", p, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// Match returns true if name matches one of the patterns.
+func (p namePatterns) Match(name string) bool {
+	for _, re := range p {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *UUIDServer) initPolicy() (err error) {
+	switch s.cfg.RegistrationPolicy {
+	case "", PolicyOpen, PolicyRegistered:
+	case PolicyAllowlist:
+		if len(s.cfg.ServiceAllowlist) == 0 {
+			return fmt.Errorf("registration policy %q needs at least one allowed service pattern", PolicyAllowlist)
+		}
+	default:
+		return fmt.Errorf("unknown registration policy %q", s.cfg.RegistrationPolicy)
+	}
+	s.allowlist, err = compileNamePatterns(s.cfg.ServiceAllowlist)
+	return err
+}
+
+// checkRegistration decides whether a service name seen for the first time
+// may take a new service ID.
+func (s *UUIDServer) checkRegistration(serviceName string) error {
+	switch s.cfg.RegistrationPolicy {
+	case PolicyRegistered:
+		return status.Errorf(codes.PermissionDenied, "service %q is not registered, ask an administrator to register it", serviceName)
+	case PolicyAllowlist:
+		if !s.allowlist.Match(serviceName) {
+			return status.Errorf(codes.PermissionDenied, "service %q is not in the allowlist of new services", serviceName)
+		}
+	}
+	return nil
+}
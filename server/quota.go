@@ -0,0 +1,263 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AnyService the key of Config.ServiceQuotas that applies to the services not listed.
+const AnyService = "*"
+
+// quotaIdle how long the state of an unused service or container is kept.
+const quotaIdle = 10 * time.Minute
+
+// Quota limits enforced in Fetch, a zero field means no limit.
+// Limits are counted by every server on its own.
+type Quota struct {
+	// MaxNeedCount the largest segment one Fetch may ask for.
+	MaxNeedCount int
+	// FetchRate the sustained number of Fetch calls per second.
+	FetchRate float64
+	// FetchBurst the number of Fetch calls allowed at once, at least 1 if FetchRate is set.
+	FetchBurst int
+	// WindowIDs the number of IDs that may be issued in each Window.
+	WindowIDs int64
+	// Window the length of the window WindowIDs applies to.
+	Window time.Duration
+}
+
+// ParseQuota parse a quota written as comma separated limits,
+// e.g. "segment=10000,rate=50,burst=100,ids=1000000/1m".
+func ParseQuota(spec string) (Quota, error) {
+	q := Quota{}
+	for _, item := range strings.Split(spec, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return q, fmt.Errorf("invalid quota %q, want key=value", item)
+		}
+		var err error
+		switch kv[0] {
+		case "segment":
+			q.MaxNeedCount, err = strconv.Atoi(kv[1])
+		case "rate":
+			q.FetchRate, err = strconv.ParseFloat(kv[1], 64)
+		case "burst":
+			q.FetchBurst, err = strconv.Atoi(kv[1])
+		case "ids":
+			parts := strings.SplitN(kv[1], "/", 2)
+			if len(parts) != 2 {
+				return q, fmt.Errorf("invalid quota %q, want ids=count/window", item)
+			}
+			q.WindowIDs, err = strconv.ParseInt(parts[0], 10, 64)
+			if err == nil {
+				q.Window, err = time.ParseDuration(parts[1])
+			}
+		default:
+			return q, fmt.Errorf("unknown quota %q", kv[0])
+		}
+		if err != nil {
+			return q, fmt.Errorf("invalid quota %q: %v", item, err)
+		}
+	}
+	return q, q.Validate()
+}
+
+// ParseServiceQuota parse a quota of a service written as "service:limits",
+// without the service name the quota applies to AnyService.
+func ParseServiceQuota(spec string) (string, Quota, error) {
+	name := AnyService
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, spec = spec[:i], spec[i+1:]
+	}
+	q, err := ParseQuota(spec)
+	return name, q, err
+}
+
+// Validate check the limits are consistent.
+func (q Quota) Validate() error {
+	if q.MaxNeedCount < 0 || q.FetchRate < 0 || q.FetchBurst < 0 || q.WindowIDs < 0 || q.Window < 0 {
+		return fmt.Errorf("quota limits must not be negative")
+	}
+	if (q.WindowIDs > 0) != (q.Window > 0) {
+		return fmt.Errorf("quota needs both the number of IDs and the window")
+	}
+	return nil
+}
+
+func (q Quota) isZero() bool {
+	return q == Quota{}
+}
+
+type quotaState struct {
+	limiter     *rate.Limiter
+	windowStart time.Time
+	windowIDs   int64
+	lastUsed    time.Time
+}
+
+// quotaLimiter tracks the usage of one quota per key.
+type quotaLimiter struct {
+	kind      string
+	quota     Quota
+	lock      sync.Mutex
+	states    map[string]*quotaState
+	lastSweep time.Time
+}
+
+func newQuotaLimiter(kind string, quota Quota) *quotaLimiter {
+	return &quotaLimiter{kind: kind, quota: quota, states: make(map[string]*quotaState)}
+}
+
+// quotaUse the quota of a name that a Fetch takes from.
+type quotaUse struct {
+	limiter *quotaLimiter
+	name    string
+}
+
+// allow take needCount IDs from the quota of name in the namespace.
+func (l *quotaLimiter) allow(ns *namespace, name string, needCount int) error {
+	_, err := allowQuotas(ns, needCount, quotaUse{limiter: l, name: name})
+	return err
+}
+
+// allowQuotas take needCount IDs from every quota, or from none of them if one is
+// exhausted. The limiters are locked until all quotas are checked, the limiter of
+// the exhausted quota is returned with the error.
+func allowQuotas(ns *namespace, needCount int, uses ...quotaUse) (*quotaLimiter, error) {
+	for _, u := range uses {
+		q := u.limiter.quota
+		if q.MaxNeedCount > 0 && needCount > q.MaxNeedCount {
+			return u.limiter, status.Errorf(codes.ResourceExhausted, "%s %q: need count %d exceeds the limit of %d",
+				u.limiter.kind, u.name, needCount, q.MaxNeedCount)
+		}
+	}
+
+	now := time.Now()
+	var states []*quotaState
+	var reservations []*rate.Reservation
+	for _, u := range uses {
+		l, q := u.limiter, u.limiter.quota
+		if q.FetchRate == 0 && q.WindowIDs == 0 {
+			continue
+		}
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		st := l.state(ns, u.name, now)
+
+		var err error
+		if q.WindowIDs > 0 && st.windowIDs+int64(needCount) > q.WindowIDs {
+			err = status.Errorf(codes.ResourceExhausted, "%s %q: quota of %d IDs per %v is exhausted", l.kind, u.name, q.WindowIDs, q.Window)
+		} else if st.limiter != nil {
+			// the fetch is given back if another quota is exhausted
+			r := st.limiter.ReserveN(now, 1)
+			if r.OK() && r.DelayFrom(now) == 0 {
+				reservations = append(reservations, r)
+			} else {
+				r.CancelAt(now)
+				err = status.Errorf(codes.ResourceExhausted, "%s %q: fetch rate limit of %v/s is exceeded", l.kind, u.name, q.FetchRate)
+			}
+		}
+		if err != nil {
+			for _, r := range reservations {
+				r.CancelAt(now)
+			}
+			return l, err
+		}
+		states = append(states, st)
+	}
+	for _, st := range states {
+		st.windowIDs += int64(needCount)
+	}
+	return nil, nil
+}
+
+// state returns the state of name in the namespace, the caller holds the lock.
+func (l *quotaLimiter) state(ns *namespace, name string, now time.Time) *quotaState {
+	q := l.quota
+	l.sweep(now)
+	key := ns.name + "/" + name
+	st, ok := l.states[key]
+	if !ok {
+		st = &quotaState{windowStart: now}
+		if q.FetchRate > 0 {
+			burst := q.FetchBurst
+			if burst < 1 {
+				burst = 1
+			}
+			st.limiter = rate.NewLimiter(rate.Limit(q.FetchRate), burst)
+		}
+		l.states[key] = st
+	}
+	st.lastUsed = now
+	if q.WindowIDs > 0 && now.Sub(st.windowStart) >= q.Window {
+		st.windowStart, st.windowIDs = now, 0
+	}
+	return st
+}
+
+// sweep drops the state of the keys that are not used for a while.
+func (l *quotaLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	idle := quotaIdle
+	if l.quota.Window > idle {
+		idle = l.quota.Window
+	}
+	for key, st := range l.states {
+		if now.Sub(st.lastUsed) > idle {
+			delete(l.states, key)
+		}
+	}
+}
+
+func (s *UUIDServer) initQuotas() error {
+	s.serviceQuotas = make(map[string]*quotaLimiter)
+	for name, q := range s.cfg.ServiceQuotas {
+		if err := q.Validate(); err != nil {
+			return fmt.Errorf("quota of service %q: %v", name, err)
+		}
+		if !q.isZero() {
+			s.serviceQuotas[name] = newQuotaLimiter("service", q)
+		}
+	}
+	if err := s.cfg.ContainerQuota.Validate(); err != nil {
+		return fmt.Errorf("quota of containers: %v", err)
+	}
+	if !s.cfg.ContainerQuota.isZero() {
+		s.containerQuota = newQuotaLimiter("container", s.cfg.ContainerQuota)
+	}
+	return nil
+}
+
+// checkQuota enforces the quotas of the service and the container of a Fetch.
+func (s *UUIDServer) checkQuota(ns *namespace, serviceName string, containerName string, needCount int) error {
+	if needCount <= 0 {
+		return status.Errorf(codes.InvalidArgument, "need count must be positive, got %d", needCount)
+	}
+	var uses []quotaUse
+	l, ok := s.serviceQuotas[serviceName]
+	if !ok {
+		l = s.serviceQuotas[AnyService]
+	}
+	if l != nil {
+		uses = append(uses, quotaUse{limiter: l, name: serviceName})
+	}
+	if s.containerQuota != nil {
+		uses = append(uses, quotaUse{limiter: s.containerQuota, name: containerName})
+	}
+	// both quotas are checked before either is charged
+	if l, err := allowQuotas(ns, needCount, uses...); err != nil {
+		quotaRejections.WithLabelValues(l.kind).Inc()
+		return err
+	}
+	return nil
+}
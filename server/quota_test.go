@@ -0,0 +1,88 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQuota(t *testing.T) {
+	name, order, err := ParseServiceQuota("order:segment=100,ids=150/1h")
+	if err != nil || name != "order" {
+		t.Fatalf("parse: %v, %v", name, err)
+	}
+	container, err := ParseQuota("rate=0.001,burst=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseQuota("ids=10"); err == nil {
+		t.Fatal("ids without a window must be rejected")
+	}
+
+	s := newTestServer(t, &Config{ServiceQuotas: map[string]Quota{name: order}, ContainerQuota: container})
+	fetch := func(service string, container string, needCount int32) error {
+		_, err := s.Fetch(context.Background(), &api.FetchRequest{ServiceName: service, ContainerName: container, NeedCount: needCount})
+		return err
+	}
+
+	if err := fetch("order", "c1", 101); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("segment limit: want ResourceExhausted, got %v", err)
+	}
+	if err := fetch("order", "c1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch("order", "c2", 100); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("window limit: want ResourceExhausted, got %v", err)
+	}
+	if err := fetch("order", "c2", 50); err != nil {
+		t.Fatal(err)
+	}
+
+	// the burst of c3 is 3 fetches, services without quota are not limited
+	for i := 0; i < 3; i++ {
+		if err := fetch("user", "c3", 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fetch("user", "c3", 1); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("rate limit: want ResourceExhausted, got %v", err)
+	}
+	if err := fetch("user", "c4", 0); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("zero need count: want InvalidArgument, got %v", err)
+	}
+
+	// a fetch rejected by the container charges neither the window nor the rate of the service
+	s = newTestServer(t, &Config{ServiceQuotas: map[string]Quota{"bill": {WindowIDs: 10, Window: time.Hour, FetchRate: 0.001, FetchBurst: 2}},
+		ContainerQuota: Quota{FetchRate: 0.001, FetchBurst: 1}})
+	for i, tc := range []struct {
+		service   string
+		container string
+		needCount int32
+		code      codes.Code
+	}{
+		{"bill", "c1", 5, codes.OK},
+		{"bill", "c1", 5, codes.ResourceExhausted}, // the rate of c1
+		{"bill", "c2", 5, codes.OK},                // bill still has 5 IDs and a fetch
+		{"bill", "c3", 1, codes.ResourceExhausted}, // bill is exhausted
+		{"user", "c3", 1, codes.OK},                // the fetch of c3 was not taken
+	} {
+		if err := fetch(tc.service, tc.container, tc.needCount); status.Code(err) != tc.code {
+			t.Fatalf("%d: want %v, got %v", i, tc.code, err)
+		}
+	}
+
+	l := newQuotaLimiter("service", Quota{WindowIDs: 1, Window: time.Millisecond})
+	ns := s.namespaces[""]
+	if err := l.allow(ns, "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := l.allow(ns, "x", 1); err != nil {
+		t.Fatalf("a new window must reset the quota: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Resolve split a UUID with the layout of the namespace and look up the service
+// and the container that own its parts. The names are empty if they are unknown.
+func (s *UUIDServer) Resolve(ctx context.Context, in *api.ResolveRequest) (*api.ResolveReply, error) {
+	ns, err := s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	serviceID, containerID, sequenceID, err := util.ParseUUID(in.Uuid, ns.layout)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	reply := &api.ResolveReply{ServiceId: serviceID, ContainerId: containerID, SequenceId: sequenceID}
+
+	// service ID 1 is used by the fetches without a service name
+	if serviceID != 1 {
+		reply.ServiceName, err = s.serviceIDOwner(ctx, ns, int(serviceID))
+		if err != nil {
+			return nil, err
+		}
+	}
+	var current bool
+	reply.ContainerName, current, err = s.containerIDOwner(ctx, ns, int(containerID))
+	if err != nil {
+		return nil, err
+	}
+	reply.ContainerReassigned = len(reply.ContainerName) > 0 && !current
+
+	r, err := s.etcdWrap.Get(ctx, ns.key(fmt.Sprintf("%d:%d", serviceID, containerID)))
+	if err == nil {
+		next, err := strconv.Atoi(r.Node.Value)
+		if err != nil {
+			return nil, err
+		}
+		reply.Allocated = sequenceID >= StartOfSequence && int(sequenceID) < next
+	} else if !s.etcdWrap.IsKeyNotFound(err) {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// containerIDOwner returns the container that got containerID and whether it still has it.
+// Containers that got their ID before the owners were recorded are found by scanning the names.
+func (s *UUIDServer) containerIDOwner(ctx context.Context, ns *namespace, containerID int) (name string, current bool, err error) {
+	r, err := s.etcdWrap.Get(ctx, ns.key(KeyOfContainerIDDir, strconv.Itoa(containerID)))
+	if err == nil {
+		name = r.Node.Value
+		r, err = s.etcdWrap.Get(ctx, ns.nameKey(KeyOfContainerDir, name))
+		if err != nil {
+			if s.etcdWrap.IsKeyNotFound(err) {
+				return name, false, nil
+			}
+			return "", false, err
+		}
+		return name, r.Node.Value == strconv.Itoa(containerID), nil
+	}
+	if !s.etcdWrap.IsKeyNotFound(err) {
+		return "", false, err
+	}
+
+	nodes, err := s.etcdWrap.List(ctx, ns.key(KeyOfContainerDir))
+	if err != nil {
+		return "", false, err
+	}
+	for _, node := range nodes {
+		if node.Value == strconv.Itoa(containerID) {
+			return nameOfKey(node.Key), true, nil
+		}
+	}
+	return "", false, nil
+}
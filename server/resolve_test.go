@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"golang.org/x/net/context"
+)
+
+func TestResolve(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	reply, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: "order", ContainerName: "c1", NeedCount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := reply.Items[0]
+	uuid := util.GenUUID(item.ServiceId, item.ContainerId, item.SequenceIdStart+3)
+
+	serviceID, containerID, sequenceID, err := util.ParseUUID(uuid, util.Layout{})
+	if err != nil || serviceID != item.ServiceId || containerID != item.ContainerId || sequenceID != item.SequenceIdStart+3 {
+		t.Fatalf("ParseUUID: %v %v %v %v", serviceID, containerID, sequenceID, err)
+	}
+	if _, _, _, err := util.ParseUUID(-1, util.Layout{}); err == nil {
+		t.Fatal("ParseUUID must reject a negative UUID")
+	}
+
+	resolved, err := s.Resolve(ctx, &api.ResolveRequest{Uuid: uuid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.ServiceName != "order" || resolved.ContainerName != "c1" || resolved.ContainerReassigned || !resolved.Allocated {
+		t.Fatalf("unexpected reply %v", resolved)
+	}
+
+	// an ID above the watermark was never handed out
+	resolved, err = s.Resolve(ctx, &api.ResolveRequest{Uuid: util.GenUUID(item.ServiceId, item.ContainerId, item.SequenceIdEnd+1)})
+	if err != nil || resolved.Allocated {
+		t.Fatalf("unexpected reply %v, %v", resolved, err)
+	}
+
+	// the old container ID still resolves after a reassignment
+	if err := s.ReassignContainerID("c1"); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err = s.Resolve(ctx, &api.ResolveRequest{Uuid: uuid})
+	if err != nil || resolved.ContainerName != "c1" || !resolved.ContainerReassigned {
+		t.Fatalf("unexpected reply after the reassignment %v, %v", resolved, err)
+	}
+
+	// containers that got their ID before the owners were recorded
+	if _, err := s.etcdWrap.Set(ctx, s.namespaces[""].key(KeyOfContainerDir, "legacy"), "999", nil); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err = s.Resolve(ctx, &api.ResolveRequest{Uuid: util.GenUUID(item.ServiceId, 999, 1)})
+	if err != nil || resolved.ContainerName != "legacy" || resolved.ContainerReassigned {
+		t.Fatalf("unexpected reply of a legacy container %v, %v", resolved, err)
+	}
+
+	resolved, err = s.Resolve(ctx, &api.ResolveRequest{Uuid: util.GenUUID(500, 5000, 1)})
+	if err != nil || resolved.ServiceName != "" || resolved.ContainerName != "" {
+		t.Fatalf("unknown parts must resolve to empty names, got %v, %v", resolved, err)
+	}
+}
@@ -0,0 +1,432 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRESPCacheCount how many IDs of a service the Redis frontend fetches at once
+// if Config.RESPCacheCount is not set.
+const DefaultRESPCacheCount = 1000
+
+// MaxRESPCount the largest number of IDs one INCRN command may ask for.
+const MaxRESPCount = 10000
+
+const (
+	maxRESPArgs = 16
+	maxRESPBulk = 64 * 1024
+)
+
+var errRESPProtocol = errors.New("invalid request")
+
+// respState the Redis protocol frontend.
+type respState struct {
+	listen net.Listener
+	lock   sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+	// caches holds a *respCache for each identity and service name
+	caches sync.Map
+}
+
+// respCacheKey the key of the cache of an identity and a service. Each identity
+// has its own cache, so the ledger records the identity the IDs are handed out
+// to. The peer of a ledger entry is the connection that caused the refill. The
+// Redis frontend serves the default namespace only, it is not part of the key.
+type respCacheKey struct {
+	identity string
+	service  string
+}
+
+// respCache the IDs fetched for a service and not handed out yet.
+type respCache struct {
+	lock   sync.Mutex
+	layout util.Layout
+	items  []*api.UUIDRange
+}
+
+// respConn the state of a client connection.
+type respConn struct {
+	ctx    context.Context
+	authed bool
+}
+
+// initRESP listen on the address of the Redis frontend.
+func (s *UUIDServer) initRESP() (err error) {
+	if len(s.cfg.RESPListenAddress) == 0 {
+		return nil
+	}
+	s.resp.listen, err = net.Listen("tcp", s.cfg.RESPListenAddress)
+	if err != nil {
+		return err
+	}
+	s.resp.conns = make(map[net.Conn]bool)
+	s.initContainerName()
+	s.logger().Info("flake resp listen", "address", s.resp.listen.Addr().String())
+	return nil
+}
+
+// RESPAddr returns the address of the Redis frontend, nil if it is disabled.
+func (s *UUIDServer) RESPAddr() net.Addr {
+	if s.resp.listen == nil {
+		return nil
+	}
+	return s.resp.listen.Addr()
+}
+
+func (s *UUIDServer) respCacheCount() int {
+	if s.cfg.RESPCacheCount > 0 {
+		return int(s.cfg.RESPCacheCount)
+	}
+	return DefaultRESPCacheCount
+}
+
+// serveRESP accepts the connections of the Redis frontend until stopRESP is called.
+func (s *UUIDServer) serveRESP() {
+	for {
+		conn, err := s.resp.listen.Accept()
+		if err != nil {
+			if s.trackRESPConn(nil, false) {
+				return
+			}
+			s.logger().Error("flake resp accept", "error", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		if s.trackRESPConn(conn, true) {
+			conn.Close()
+			return
+		}
+		go s.serveRESPConn(conn)
+	}
+}
+
+// trackRESPConn adds or removes an open connection and returns true once the frontend is stopped.
+func (s *UUIDServer) trackRESPConn(conn net.Conn, add bool) (closed bool) {
+	s.resp.lock.Lock()
+	defer s.resp.lock.Unlock()
+	if conn != nil {
+		if add && !s.resp.closed {
+			s.resp.conns[conn] = true
+		} else {
+			delete(s.resp.conns, conn)
+		}
+	}
+	return s.resp.closed
+}
+
+// stopRESP closes the listener and the connections of the Redis frontend.
+func (s *UUIDServer) stopRESP() {
+	if s.resp.listen == nil {
+		return
+	}
+	s.resp.lock.Lock()
+	defer s.resp.lock.Unlock()
+	if s.resp.closed {
+		return
+	}
+	s.resp.closed = true
+	s.resp.listen.Close()
+	for conn := range s.resp.conns {
+		conn.Close()
+	}
+}
+
+func (s *UUIDServer) serveRESPConn(conn net.Conn) {
+	defer func() {
+		s.trackRESPConn(conn, false)
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	w := respWriter{bufio.NewWriter(conn)}
+	c := &respConn{ctx: peer.NewContext(context.Background(), &peer.Peer{Addr: conn.RemoteAddr()}), authed: s.authenticator == nil}
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			if err == errRESPProtocol {
+				w.error("ERR Protocol error: " + err.Error())
+				w.Flush()
+			} else if err != io.EOF {
+				s.logger().Debug("flake resp read", "peer", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execRESP(c, w, conn.RemoteAddr(), args)
+		// pipelined commands are answered together
+		if quit || r.Buffered() == 0 {
+			if w.Flush() != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// execRESP runs a command and writes its reply, it returns true if the connection is to be closed.
+func (s *UUIDServer) execRESP(c *respConn, w respWriter, peer net.Addr, args []string) (quit bool) {
+	name := strings.ToUpper(args[0])
+	wrongArgs := func() {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+	}
+	switch name {
+	case "PING":
+		switch len(args) {
+		case 1:
+			w.simple("PONG")
+		case 2:
+			w.bulk(args[1])
+		default:
+			wrongArgs()
+		}
+	case "ECHO":
+		if len(args) != 2 {
+			wrongArgs()
+			break
+		}
+		w.bulk(args[1])
+	case "QUIT":
+		w.simple("OK")
+		return true
+	case "AUTH":
+		// AUTH token or AUTH user token, the user is ignored
+		if len(args) != 2 && len(args) != 3 {
+			wrongArgs()
+			break
+		}
+		if s.authenticator == nil {
+			w.error("ERR AUTH called without any password configured")
+			break
+		}
+		identity, err := s.authenticator.Authenticate(args[len(args)-1])
+		if err != nil {
+			s.logger().Warn("authentication failed", "method", "resp.AUTH", "error", err.Error(), "peer", peer.String())
+			w.error("WRONGPASS invalid token")
+			break
+		}
+		c.ctx = context.WithValue(c.ctx, identityKey{}, identity)
+		c.authed = true
+		w.simple("OK")
+	case "INCR":
+		if len(args) != 2 {
+			wrongArgs()
+			break
+		}
+		ids, err := s.respIDs(c, args[1], 1)
+		if err != nil {
+			w.error(respError(err))
+			break
+		}
+		w.integer(ids[0])
+	case "INCRN":
+		if len(args) != 3 {
+			wrongArgs()
+			break
+		}
+		count, err := strconv.Atoi(args[2])
+		if err != nil || count <= 0 || count > MaxRESPCount {
+			w.error(fmt.Sprintf("ERR count must be between 1 and %d", MaxRESPCount))
+			break
+		}
+		ids, err := s.respIDs(c, args[1], count)
+		if err != nil {
+			w.error(respError(err))
+			break
+		}
+		w.array(len(ids))
+		for _, id := range ids {
+			w.integer(id)
+		}
+	default:
+		w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+// respIDs hands out count IDs of the service from the cache, the cache is
+// refilled through Fetch when it runs out.
+func (s *UUIDServer) respIDs(c *respConn, serviceName string, count int) ([]int64, error) {
+	if !c.authed {
+		return nil, status.Error(codes.Unauthenticated, "Authentication required.")
+	}
+	// Fetch checks these on a refill, the cached IDs need the same checks
+	if err := s.checkDraining(); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(c.ctx, serviceName); err != nil {
+		return nil, err
+	}
+	identity, _ := IdentityFromContext(c.ctx)
+	key := respCacheKey{identity: identity, service: serviceName}
+	v, _ := s.resp.caches.LoadOrStore(key, &respCache{})
+	cache := v.(*respCache)
+	ids, err := cache.take(c.ctx, s, serviceName, count)
+	if err != nil {
+		// do not keep caches for names that can not be fetched
+		s.resp.caches.CompareAndDelete(key, cache)
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (c *respCache) take(ctx context.Context, s *UUIDServer, serviceName string, count int) ([]int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// the service may be retired since the cached IDs were fetched, Fetch checks it on a refill
+	if len(c.items) > 0 {
+		if err := s.checkRetired(ctx, s.namespaces[""], serviceName); err != nil {
+			c.items = nil
+			return nil, err
+		}
+	}
+	ids := make([]int64, 0, count)
+	for len(ids) < count {
+		if len(c.items) == 0 {
+			needCount := s.respCacheCount()
+			if left := count - len(ids); left > needCount {
+				needCount = left
+			}
+			reply, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: serviceName,
+				ContainerName: s.containerName, NeedCount: int32(needCount)})
+			if err != nil {
+				return nil, err
+			}
+			c.layout = layoutOf(reply.Layout)
+			c.items = reply.Items
+		}
+		item := c.items[0]
+		n := int(item.SequenceIdEnd) - int(item.SequenceIdStart) + 1
+		if left := count - len(ids); n > left {
+			n = left
+		}
+		for i := 0; i < n; i++ {
+			ids = append(ids, c.layout.Compose(item.ServiceId, item.ContainerId, item.SequenceIdStart+int32(i)))
+		}
+		if int(item.SequenceIdStart)+n > int(item.SequenceIdEnd) {
+			c.items = c.items[1:]
+		} else {
+			item.SequenceIdStart += int32(n)
+		}
+	}
+	return ids, nil
+}
+
+// checkRetired returns FailedPrecondition if the service is retired.
+func (s *UUIDServer) checkRetired(ctx context.Context, ns *namespace, serviceName string) error {
+	r, err := s.etcdWrap.Get(ctx, ns.nameKey(KeyOfServiceDir, serviceName))
+	if err != nil {
+		return err
+	}
+	_, retired, err := parseServiceRecord(r.Node.Value)
+	if err != nil {
+		return err
+	}
+	if retired {
+		return status.Errorf(codes.FailedPrecondition, "service %q is retired", serviceName)
+	}
+	return nil
+}
+
+// respError returns the Redis error of a gRPC error.
+func respError(err error) string {
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.Unauthenticated:
+		return "NOAUTH " + st.Message()
+	case codes.PermissionDenied:
+		return "NOPERM " + st.Message()
+	}
+	return "ERR " + st.Message()
+}
+
+// readRESPCommand reads a command sent as an array of bulk strings, or inline as
+// words separated by spaces.
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	// the null array "*-1" is not a command, "*0" is a command without arguments
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxRESPArgs {
+		return nil, errRESPProtocol
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err = readRESPLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errRESPProtocol
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxRESPBulk {
+			return nil, errRESPProtocol
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, errRESPProtocol
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readRESPLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", errRESPProtocol
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// respWriter writes the replies of the Redis protocol.
+type respWriter struct {
+	*bufio.Writer
+}
+
+func (w respWriter) simple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func (w respWriter) error(s string) {
+	w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(s) + "\r\n")
+}
+
+func (w respWriter) integer(i int64) {
+	w.WriteString(":" + strconv.FormatInt(i, 10) + "\r\n")
+}
+
+func (w respWriter) bulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func (w respWriter) array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+func TestRESP(t *testing.T) {
+	s := newTestServer(t, &Config{RESPListenAddress: "127.0.0.1:0", RESPCacheCount: 10,
+		Authenticator: StaticTokens{"s3cret": "orders"}, ACL: map[string][]string{"orders": {"order-.*"}}})
+	if err := s.initRESP(); err != nil {
+		t.Fatal(err)
+	}
+	go s.serveRESP()
+	defer s.stopRESP()
+
+	conn, err := redis.Dial("tcp", s.RESPAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	expectError := func(prefix string, commandName string, args ...interface{}) {
+		t.Helper()
+		_, err := conn.Do(commandName, args...)
+		if err == nil || !strings.HasPrefix(err.Error(), prefix) {
+			t.Fatalf("%v %v: want %v error, got %v", commandName, args, prefix, err)
+		}
+	}
+
+	if pong, err := redis.String(conn.Do("PING")); err != nil || pong != "PONG" {
+		t.Fatalf("PING: %v %v", pong, err)
+	}
+	expectError("NOAUTH", "INCR", "order-a")
+	expectError("WRONGPASS", "AUTH", "wrong")
+	if _, err := conn.Do("AUTH", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := redis.Int64(conn.Do("INCR", "order-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := redis.Int64(conn.Do("INCR", "order-a"))
+	if err != nil || second != first+1 {
+		t.Fatalf("want %d, got %d %v", first+1, second, err)
+	}
+	// the cache belongs to the identity, the IDs are fetched for the container of the server
+	if _, ok := s.resp.caches.Load(respCacheKey{identity: "orders", service: "order-a"}); !ok {
+		t.Fatal("no cache of the identity")
+	}
+	if _, err := s.etcdWrap.Get(context.Background(), s.namespaces[""].nameKey(KeyOfContainerDir, s.containerName)); err != nil {
+		t.Fatalf("the container of the server is not used: %v", err)
+	}
+
+	// the batch is larger than the cache and needs several fetches
+	ids, err := redis.Int64s(conn.Do("INCRN", "order-a", 25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 25 {
+		t.Fatalf("want 25 IDs, got %d", len(ids))
+	}
+	last := second
+	for _, id := range ids {
+		if id <= last {
+			t.Fatalf("IDs are not increasing: %v after %v", id, last)
+		}
+		last = id
+	}
+
+	// pipelined commands
+	conn.Send("INCR", "order-b")
+	conn.Send("INCR", "order-b")
+	conn.Send("PING")
+	if err := conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := redis.Int64(conn.Receive())
+	b, _ := redis.Int64(conn.Receive())
+	if pong, err := redis.String(conn.Receive()); err != nil || pong != "PONG" || b != a+1 {
+		t.Fatalf("pipeline: %v %v %v %v", a, b, pong, err)
+	}
+
+	expectError("NOPERM", "INCR", "billing")
+	expectError("ERR count", "INCRN", "order-a", 0)
+	expectError("ERR wrong number", "INCR")
+	expectError("ERR unknown command", "GET", "order-a")
+
+	// a retired service is refused although IDs are cached
+	if _, err := s.RetireService(context.Background(), &api.RetireServiceRequest{ServiceName: "order-b"}); err != nil {
+		t.Fatal(err)
+	}
+	expectError(`ERR service "order-b" is retired`, "INCR", "order-b")
+
+	s.Drain()
+	expectError("ERR flake server is draining", "INCR", "order-a")
+}
+
+func TestReadRESPCommand(t *testing.T) {
+	for input, want := range map[string]string{
+		"*2\r\n$4\r\nINCR\r\n$1\r\na\r\n": "INCR a",
+		"INCR a\r\n":                      "INCR a",
+		"*0\r\n":                          "",
+	} {
+		args, err := readRESPCommand(bufio.NewReader(strings.NewReader(input)))
+		if err != nil || strings.Join(args, " ") != want {
+			t.Fatalf("%q: got %q %v", input, args, err)
+		}
+	}
+	for _, input := range []string{"*-1\r\n", "*-5\r\n", "*x\r\n", "*1\r\n$-1\r\n"} {
+		if _, err := readRESPCommand(bufio.NewReader(strings.NewReader(input))); err != errRESPProtocol {
+			t.Fatalf("%q: want a protocol error, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Version the version of flake, written to the schema record by the server that created or upgraded it.
+	Version = "1.2.0"
+	// SchemaVersion the version of the keys this server reads and writes.
+	SchemaVersion = 2
+
+	// KeyOfSchema holds the schema record of the namespace.
+	KeyOfSchema = "schema"
+	// KeyOfUpgradeDir holds the keys of an upgrade step while it runs, in a directory per schema.
+	KeyOfUpgradeDir = "upgrade"
+)
+
+// schemaRecord the schema record saved under the prefix of every namespace.
+type schemaRecord struct {
+	// Schema the version of the keys, see SchemaVersion.
+	Schema int `json:"schema"`
+	// Layout the layout of the UUIDs issued from the keys.
+	Layout string `json:"layout"`
+	// Version the flake version that created or last upgraded the keys.
+	Version string `json:"version"`
+	// Time when the record was written, in RFC 3339.
+	Time string `json:"time"`
+}
+
+// schemaUpgrade moves the keys of a namespace from schema To-1 to schema To.
+type schemaUpgrade struct {
+	To          int
+	Description string
+	Run         func(ctx context.Context, w *EtcdWrap, ns *namespace) error
+}
+
+// schemaUpgrades every upgrade step in order. A step must be safe to run again,
+// the record is only raised after the step is done.
+var schemaUpgrades = []schemaUpgrade{
+	{
+		To:          1,
+		Description: "record the schema and the layout, the keys are not changed",
+		Run:         func(ctx context.Context, w *EtcdWrap, ns *namespace) error { return nil },
+	},
+	{
+		To:          2,
+		Description: "escape \"%\" and \"/\" in the keys of the service, alias, container and gapless names",
+		Run:         escapeNames,
+	},
+}
+
+// SchemaStatus the schema of the keys of a namespace.
+type SchemaStatus struct {
+	Namespace string
+	Prefix    string
+	// Schema the version of the keys, 0 if they were written before the schema was recorded.
+	Schema int
+	// Layout and Version from the record, empty if there is none.
+	Layout  string
+	Version string
+	// Empty true if the namespace has no keys yet.
+	Empty bool
+	// Upgraded the descriptions of the upgrade steps that were run.
+	Upgraded []string
+}
+
+// readSchema reads the schema of a namespace.
+func readSchema(ctx context.Context, w *EtcdWrap, ns *namespace) (*SchemaStatus, *client.Response, error) {
+	st := &SchemaStatus{Namespace: ns.name, Prefix: ns.prefix}
+	r, err := w.Get(ctx, ns.key(KeyOfSchema))
+	if err == nil {
+		record := &schemaRecord{}
+		if err := json.Unmarshal([]byte(r.Node.Value), record); err != nil {
+			return nil, nil, fmt.Errorf("%v: %v", ns.key(KeyOfSchema), err)
+		}
+		st.Schema, st.Layout, st.Version = record.Schema, record.Layout, record.Version
+		return st, r, nil
+	}
+	if !w.IsKeyNotFound(err) {
+		return nil, nil, err
+	}
+	// keys written before the schema was recorded have the counters
+	_, err = w.Get(ctx, ns.key(KeyOfMaxServiceID))
+	if err != nil && !w.IsKeyNotFound(err) {
+		return nil, nil, err
+	}
+	st.Empty = err != nil
+	return st, nil, nil
+}
+
+// writeSchema writes the record of schema, prev is the record it replaces or nil.
+// The layout of the keys is kept, the layout of ns is recorded if there is none.
+func writeSchema(ctx context.Context, w *EtcdWrap, ns *namespace, schema int, layout string, prev *client.Response) error {
+	if len(layout) == 0 {
+		layout = ns.layout.String()
+	}
+	value, err := json.Marshal(&schemaRecord{Schema: schema, Layout: layout, Version: Version, Time: time.Now().Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+	opts := &client.SetOptions{PrevExist: client.PrevNoExist}
+	if prev != nil {
+		opts = &client.SetOptions{PrevIndex: prev.Node.ModifiedIndex}
+	}
+	_, err = w.Set(ctx, ns.key(KeyOfSchema), string(value), opts)
+	return err
+}
+
+// checkSchema returns why this server must not use the keys of the namespace, nil if it may.
+func checkSchema(st *SchemaStatus, ns *namespace) error {
+	switch {
+	case st.Schema > SchemaVersion:
+		return fmt.Errorf("namespace %q has schema %d written by flake %v, this flake %v supports schema %d, upgrade the server",
+			ns.name, st.Schema, st.Version, Version, SchemaVersion)
+	case st.Schema < SchemaVersion:
+		return fmt.Errorf("namespace %q has schema %d, this flake %v needs schema %d, run \"flake schema upgrade\" after the old servers are stopped",
+			ns.name, st.Schema, Version, SchemaVersion)
+	case st.Layout != ns.layout.String():
+		return fmt.Errorf("namespace %q was written with layout %v, the config has %v", ns.name, st.Layout, ns.layout)
+	}
+	return nil
+}
+
+// initSchema creates the schema record of the new namespaces and refuses to
+// start if the keys of a namespace have another schema or layout.
+func (s *UUIDServer) initSchema(ctx context.Context) error {
+	for _, ns := range s.namespaces {
+		for {
+			st, _, err := readSchema(ctx, s.etcdWrap, ns)
+			if err != nil {
+				return err
+			}
+			if st.Empty {
+				err = writeSchema(ctx, s.etcdWrap, ns, SchemaVersion, "", nil)
+				if s.etcdWrap.IsKeyExist(err) {
+					// created by another server, check it
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				st.Schema, st.Layout, st.Version = SchemaVersion, ns.layout.String(), Version
+			}
+			if err := checkSchema(st, ns); err != nil {
+				return err
+			}
+			ns.schema = st.Schema
+			break
+		}
+	}
+	return nil
+}
+
+// watchSchema is called by the health check. The server stops issuing IDs if the
+// schema was changed since it started, so an old server never writes keys of a new schema.
+func (s *UUIDServer) watchSchema(ctx context.Context, ns *namespace) error {
+	if ns.schema == 0 {
+		return nil
+	}
+	st, _, err := readSchema(ctx, s.etcdWrap, ns)
+	if err != nil {
+		return err
+	}
+	if err := checkSchema(st, ns); err != nil {
+		s.fenceWith(status.Error(codes.FailedPrecondition, err.Error()))
+		return err
+	}
+	return nil
+}
+
+// SchemaStatuses returns the schema of the keys of every namespace of the config.
+func SchemaStatuses(ctx context.Context, cfg *Config) ([]*SchemaStatus, error) {
+	return schemaRun(ctx, cfg, false)
+}
+
+// UpgradeSchema run the upgrade steps of every namespace of the config up to
+// SchemaVersion. The servers of older versions must be stopped first, they stop
+// issuing IDs anyway on the next health check.
+func UpgradeSchema(ctx context.Context, cfg *Config) ([]*SchemaStatus, error) {
+	return schemaRun(ctx, cfg, true)
+}
+
+func schemaRun(ctx context.Context, cfg *Config, upgrade bool) ([]*SchemaStatus, error) {
+	s := &UUIDServer{cfg: cfg}
+	if err := s.initNamespaces(); err != nil {
+		return nil, err
+	}
+	w, err := NewEtcdWrap(cfg.etcdWrapConfig())
+	if err != nil {
+		return nil, err
+	}
+	var result []*SchemaStatus
+	names := make([]string, 0, len(s.namespaces))
+	for name := range s.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		st, err := upgradeSchema(ctx, w, s.namespaces[name], upgrade)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, st)
+	}
+	return result, nil
+}
+
+// upgradeSchema reads the schema of a namespace, with upgrade the missing upgrade steps are run first.
+func upgradeSchema(ctx context.Context, w *EtcdWrap, ns *namespace, upgrade bool) (*SchemaStatus, error) {
+	st, r, err := readSchema(ctx, w, ns)
+	if err != nil || !upgrade || st.Empty {
+		return st, err
+	}
+	if st.Schema > SchemaVersion {
+		return nil, checkSchema(st, ns)
+	}
+	var upgraded []string
+	for _, step := range schemaUpgrades {
+		if step.To <= st.Schema {
+			continue
+		}
+		if err := step.Run(ctx, w, ns); err != nil {
+			return nil, fmt.Errorf("namespace %q, upgrade to schema %d: %v", ns.name, step.To, err)
+		}
+		if err := writeSchema(ctx, w, ns, step.To, st.Layout, r); err != nil {
+			return nil, fmt.Errorf("namespace %q, upgrade to schema %d: %v", ns.name, step.To, err)
+		}
+		upgraded = append(upgraded, fmt.Sprintf("%d: %v", step.To, step.Description))
+		if st, r, err = readSchema(ctx, w, ns); err != nil {
+			return nil, err
+		}
+	}
+	st.Upgraded = upgraded
+	return st, nil
+}
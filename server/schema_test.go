@@ -0,0 +1,80 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSchema(t *testing.T) {
+	ctx := context.Background()
+
+	// keys written before the schema was recorded need the upgrade
+	s := newTestServer(t, nil)
+	ns := s.namespaces[""]
+	if err := s.initSchema(ctx); err == nil || !strings.Contains(err.Error(), "flake schema upgrade") {
+		t.Fatalf("old keys: want an upgrade error, got %v", err)
+	}
+	st, err := upgradeSchema(ctx, s.etcdWrap, ns, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Schema != 0 || st.Empty {
+		t.Fatalf("old keys: got %+v", st)
+	}
+	st, err = upgradeSchema(ctx, s.etcdWrap, ns, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Schema != SchemaVersion || st.Layout != util.DefaultLayout.String() || st.Version != Version || len(st.Upgraded) != SchemaVersion {
+		t.Fatalf("upgraded: got %+v", st)
+	}
+	if err := s.initSchema(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if st, err = upgradeSchema(ctx, s.etcdWrap, ns, true); err != nil || len(st.Upgraded) != 0 {
+		t.Fatalf("a second upgrade must do nothing: %+v %v", st, err)
+	}
+
+	// a newer schema stops the running server
+	if err := s.watchSchema(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.etcdWrap.Set(ctx, ns.key(KeyOfSchema), `{"schema":99,"layout":"10/22/31","version":"9.0.0"}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.watchSchema(ctx, ns); err == nil || !strings.Contains(err.Error(), "9.0.0") {
+		t.Fatalf("newer schema: want an error, got %v", err)
+	}
+	if _, err := s.Fetch(ctx, &api.FetchRequest{ServiceName: "order", NeedCount: 1}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("newer schema: want FailedPrecondition, got %v", err)
+	}
+	if err := s.initSchema(ctx); err == nil {
+		t.Fatal("newer schema: the server must not start")
+	}
+	if _, err := upgradeSchema(ctx, s.etcdWrap, ns, true); err == nil {
+		t.Fatal("newer schema: the upgrade must fail")
+	}
+
+	// a new store gets the record, another layout is refused
+	w := &EtcdWrap{cfg: &EtcdWrapConfig{}, etcdAPI: newMemKeys()}
+	for i, layout := range []util.Layout{{}, {ServiceBits: 12, ContainerBits: 20, SequenceBits: 31}} {
+		s := &UUIDServer{cfg: &Config{Prefix: "/flake", Layout: layout}, etcdWrap: w}
+		if err := s.initNamespaces(); err != nil {
+			t.Fatal(err)
+		}
+		err := s.initSchema(ctx)
+		if i == 0 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && (err == nil || !strings.Contains(err.Error(), "layout")) {
+			t.Fatalf("another layout: want an error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/client"
+	"github.com/cnwinds/flake/util"
+
+	"google.golang.org/grpc"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA if parent is nil.
+func newTestCert(t *testing.T, parent *testCert, serial int64, name string) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// write saves the certificate and key as PEM files, modified at modTime.
+func (c *testCert) write(t *testing.T, dir string, name string, modTime time.Time) (certFile string, keyFile string) {
+	t.Helper()
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: c.cert.Raw},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDer},
+	} {
+		if err := ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flake-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	ca := newTestCert(t, nil, 1, "flake test ca")
+	caFile, _ := ca.write(t, dir, "ca", now)
+	serverCert, serverKey := newTestCert(t, ca, 2, "flake server").write(t, dir, "server", now)
+	clientCert, clientKey := newTestCert(t, ca, 3, "flake client").write(t, dir, "client", now)
+
+	s := newTestServer(t, &Config{TLSCertFile: serverCert, TLSKeyFile: serverKey, TLSClientCAFile: caFile})
+	opts, err := s.serverOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(opts...)
+	api.RegisterUUIDServer(grpcServer, s)
+	go grpcServer.Serve(listen)
+	defer grpcServer.Stop()
+	endpoint := listen.Addr().String()
+
+	genUUID := func(cfg *client.Config) error {
+		cfg.Endpoint = endpoint
+		c, err := client.NewClient(cfg)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		_, err = c.GenUUID("TestMutualTLS")
+		return err
+	}
+
+	if err := genUUID(&client.Config{TLSCAFile: caFile, TLSCertFile: clientCert, TLSKeyFile: clientKey}); err != nil {
+		t.Fatal(err)
+	}
+	if err := genUUID(&client.Config{TLSCAFile: caFile}); err == nil {
+		t.Fatal("a client without certificate must be rejected")
+	}
+	if err := genUUID(&client.Config{}); err == nil {
+		t.Fatal("a client without TLS must be rejected")
+	}
+
+	// replace the server certificate, new connections get the new one
+	newTestCert(t, ca, 4, "flake server").write(t, dir, "server", now.Add(time.Second))
+	tlsCfg, err := util.ClientTLSConfig(caFile, clientCert, clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := tls.Dial("tcp", endpoint, tlsCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	conn.Close()
+	if serial != 4 {
+		t.Fatalf("want the reloaded certificate 4, got %v", serial)
+	}
+	if err := genUUID(&client.Config{TLSCAFile: caFile, TLSCertFile: clientCert, TLSKeyFile: clientKey}); err != nil {
+		t.Fatal(err)
+	}
+}
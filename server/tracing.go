@@ -0,0 +1,83 @@
+package server
+
+import (
+	"io"
+	"strings"
+
+	"github.com/cnwinds/flake/util"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tracerName the instrumentation name of the flake server spans.
+const tracerName = "github.com/cnwinds/flake/server"
+
+// propagator reads the W3C trace context sent by the clients.
+var propagator = propagation.TraceContext{}
+
+// NewWriterTracerProvider create a tracer provider exporting the spans as JSON lines to w,
+// sampleRate is the share of the new traces recorded, traces started by a client follow its decision.
+func NewWriterTracerProvider(w io.Writer, sampleRate float64) (*sdktrace.TracerProvider, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewSchemaless(attribute.String("service.name", "flake"))
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRate))),
+	), nil
+}
+
+// tracerFrom returns the tracer of the provider, or of the global provider if it is nil.
+func tracerFrom(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+func (s *UUIDServer) tracer() trace.Tracer {
+	return tracerFrom(s.cfg.TracerProvider)
+}
+
+// endSpan records the error of the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceUnaryInterceptor continues the trace of the client and wraps the call in a server span.
+func (s *UUIDServer) traceUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = propagator.Extract(ctx, util.MetadataCarrier(md))
+
+	name := strings.TrimPrefix(info.FullMethod, "/")
+	attrs := []attribute.KeyValue{attribute.String("rpc.system", "grpc")}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		attrs = append(attrs, attribute.String("rpc.service", name[:i]), attribute.String("rpc.method", name[i+1:]))
+	}
+	if r, ok := req.(interface{ GetServiceName() string }); ok {
+		attrs = append(attrs, attribute.String("flake.service", r.GetServiceName()))
+	}
+	ctx, span := s.tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	resp, err := handler(ctx, req)
+	span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+	endSpan(span, err)
+	return resp, err
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/cnwinds/flake/client"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	s := newTestServer(t, &Config{ListenAddress: "127.0.0.1:0", TracerProvider: tp})
+	s.etcdWrap.etcdAPI = newInstrumentedKeys(s.etcdWrap.etcdAPI, tp)
+	if err := s.initGRPC(); err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	defer s.Shutdown(context.Background())
+
+	c, err := client.NewClient(&client.Config{Endpoint: s.Addr().String(), TracerProvider: tp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, root := tp.Tracer("test").Start(context.Background(), "TestTracing")
+	if _, err := c.GenUUIDContext(ctx, "TestTracing"); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Fatalf("span %v is not in the trace of the call", span.Name())
+		}
+		if _, ok := spans[span.Name()]; !ok || span.SpanKind() == trace.SpanKindServer {
+			spans[span.Name()] = span
+		}
+	}
+	for _, name := range []string{"api.UUID/Fetch", "getUUIDSegment", "getServieID", "getContainerID", "etcd.get", "etcd.set"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("span %v is missing", name)
+		}
+	}
+	server := spans["api.UUID/Fetch"]
+	if server == nil || server.SpanKind() != trace.SpanKindServer || !server.Parent().IsRemote() {
+		t.Fatalf("the server span must continue the trace of the client: %v", server)
+	}
+	if spans["getUUIDSegment"].Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatal("getUUIDSegment must be a child of the server span")
+	}
+}
@@ -1,28 +1,42 @@
 package server
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net"
+	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/util"
 
 	"github.com/coreos/etcd/client"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	// StartOfContainerID the first ID that the container starts to assgin.
-	StartOfContainerID = 10
+	StartOfContainerID = util.FirstContainerID
 	// StartOfServerID the first ID that the server starts to assgin.
-	StartOfServerID = 10
+	StartOfServerID = util.FirstServiceID
 	// StartOfSequence the first ID that the sequence starts to assgin.
-	StartOfSequence = 1
-	// MaxOfSequence the maximum of the sequence.
+	StartOfSequence = util.FirstSequence
+	// MaxOfServiceID the upper bound (exclusive) of the service ID with the default layout.
+	MaxOfServiceID = 1 << 10
+	// MaxOfSequence the maximum of the sequence with the default layout.
 	MaxOfSequence = 1 << 31
 	// the following line used for the test of "TestOverRang"
 	// MaxOfSequence = 1 << 10
@@ -36,6 +50,18 @@ const (
 	KeyOfContainerDir = "container"
 	// KeyOfServiceDir the directory where the key value is saved.
 	KeyOfServiceDir = "service"
+	// KeyOfServiceIDDir the directory where the owner of each service ID is saved.
+	KeyOfServiceIDDir = "serviceid"
+	// KeyOfContainerIDDir the directory where the container that got each container ID is saved.
+	KeyOfContainerIDDir = "containerid"
+	// KeyOfReassignDir the directory where the container ID reassignments are logged in order.
+	KeyOfReassignDir = "reassign"
+	// KeyOfLedgerDir the directory of the ranges recorded by the store ledger.
+	KeyOfLedgerDir = "ledger"
+	// KeyOfAliasDir the directory where the canonical name of each alias is saved.
+	KeyOfAliasDir = "alias"
+	// KeyOfProbeDir the directory of the temporary keys written by the startup check.
+	KeyOfProbeDir = "probe"
 )
 
 // Config the config used to create the server.
@@ -47,11 +73,154 @@ type Config struct {
 	// Password is the password for the specified user to add as an authorization header
 	// to the request.
 	Password string
+	// EtcdCAFile the CA that signs the certificates of the etcd servers.
+	EtcdCAFile string
+	// EtcdCertFile the client certificate presented to the etcd servers.
+	EtcdCertFile string
+	// EtcdKeyFile the private key of EtcdCertFile.
+	EtcdKeyFile string
 
 	// ListenAddress the server listens for local address.
 	ListenAddress string
+	// HTTPListenAddress the address of the HTTP endpoints /healthz, /readyz and /metrics, disabled if empty.
+	HTTPListenAddress string
+	// HTTPGateway serve the HTTP/JSON API /v1/ids/{service} on HTTPListenAddress.
+	HTTPGateway bool
+	// RESPListenAddress the address of the Redis protocol frontend, disabled if empty.
+	RESPListenAddress string
+	// RESPCacheCount how many IDs of a service the Redis frontend fetches at once, DefaultRESPCacheCount if zero.
+	RESPCacheCount int32
+	// HealthInterval how often the store is checked for the health service, DefaultHealthInterval if zero.
+	HealthInterval time.Duration
+	// TLSCertFile the certificate of the server, TLS is disabled if it is empty.
+	TLSCertFile string
+	// TLSKeyFile the private key of TLSCertFile.
+	TLSKeyFile string
+	// TLSClientCAFile the CA that signs client certificates, clients must present one if it is set.
+	TLSClientCAFile string
 	// Prefix path prefix saved in the etcd.
 	Prefix string
+
+	// ReservationTTL how long a gapless number stays reserved before it is re-issued.
+	ReservationTTL time.Duration
+
+	// RegistrationPolicy decides which new service names get an ID: PolicyOpen (default),
+	// PolicyAllowlist or PolicyRegistered.
+	RegistrationPolicy string
+	// ServiceAllowlist regular expressions of the new service names accepted by PolicyAllowlist.
+	ServiceAllowlist []string
+
+	// Layout the bits of the UUID parts, util.DefaultLayout is used if it is zero.
+	Layout util.Layout
+	// Namespaces the namespaces served besides the default one under Prefix.
+	Namespaces []NamespaceConfig
+
+	// ServiceQuotas limits per service name, the quota of AnyService applies to the services not listed.
+	ServiceQuotas map[string]Quota
+	// ContainerQuota limits applied to each container.
+	ContainerQuota Quota
+
+	// AuthTokenFile static bearer tokens, one "identity token" pair per line.
+	AuthTokenFile string
+	// AuthJWTKeyFile the key that verifies JWT bearer tokens, a PEM public key or an HMAC secret.
+	AuthJWTKeyFile string
+	// AdminTokenFile static bearer tokens of the admin service, one "identity token" pair per line.
+	// The Admin RPCs accept only these tokens and are refused if it is not set.
+	AdminTokenFile string
+	// Authenticator a custom authenticator tried before the token file and the JWT key.
+	Authenticator Authenticator
+	// Logger the logger of the server, slog.Default() is used if it is nil.
+	Logger *slog.Logger
+	// TracerProvider traces the calls and the etcd requests, the global provider is used if it is nil.
+	TracerProvider trace.TracerProvider
+	// AccessLogSampleRate the share of the calls written to the access log, from 0 (none) to 1 (all).
+	AccessLogSampleRate float64
+
+	// LedgerStore record every range issued by Fetch in etcd, see LookupLedger.
+	LedgerStore bool
+	// LedgerTTL how long the ranges stay in etcd, forever if zero.
+	LedgerTTL time.Duration
+	// LedgerFile record every range issued by Fetch in a local file, one JSON object per line.
+	LedgerFile string
+	// LedgerFileSize the size in bytes at which the ledger file is rotated, DefaultLedgerFileSize if zero.
+	LedgerFileSize int64
+	// LedgerFileCount how many rotated ledger files are kept, DefaultLedgerFileCount if zero.
+	LedgerFileCount int
+	// InstanceName the name of the server in the ledger, "hostname/listen address" if empty.
+	InstanceName string
+	// StateFile the local record of the highest counters and watermarks seen. If it is set
+	// the server refuses to start, or stops issuing IDs, when the store is behind the record.
+	StateFile string
+
+	// ACL the service name patterns each identity may use, the patterns of AnyService apply
+	// to every identity. Without ACL any authenticated caller may use any service.
+	ACL map[string][]string
+}
+
+// String hides the secrets when the config is logged.
+func (cfg Config) String() string {
+	if len(cfg.Password) > 0 {
+		cfg.Password = "******"
+	}
+	type plain Config
+	return fmt.Sprintf("%+v", plain(cfg))
+}
+
+// Validate checks the config and the files it names without connecting to etcd,
+// every problem found is returned.
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(failed bool, format string, a ...interface{}) {
+		if failed {
+			errs = append(errs, fmt.Errorf(format, a...))
+		}
+	}
+	check(len(cfg.Endpoints) == 0, "no etcd endpoints")
+	check(len(cfg.ListenAddress) == 0, "no listen address")
+	check(cfg.HTTPGateway && len(cfg.HTTPListenAddress) == 0, "the HTTP gateway needs an HTTP listen address")
+	check(cfg.RESPCacheCount < 0, "invalid RESP cache count %d", cfg.RESPCacheCount)
+	check(cfg.HealthInterval < 0, "invalid health interval %v", cfg.HealthInterval)
+	check(cfg.ReservationTTL < 0, "invalid reservation TTL %v", cfg.ReservationTTL)
+	check(cfg.LedgerTTL < 0, "invalid ledger TTL %v", cfg.LedgerTTL)
+	check(cfg.LedgerFileSize < 0, "invalid ledger file size %d", cfg.LedgerFileSize)
+	check(cfg.LedgerFileCount < 0, "invalid ledger file count %d", cfg.LedgerFileCount)
+	check(cfg.AccessLogSampleRate < 0 || cfg.AccessLogSampleRate > 1, "access log sample rate %v is not between 0 and 1", cfg.AccessLogSampleRate)
+	check(len(cfg.TLSKeyFile) > 0 && len(cfg.TLSCertFile) == 0, "the TLS key needs the TLS certificate")
+	check(len(cfg.TLSClientCAFile) > 0 && len(cfg.TLSCertFile) == 0, "client certificates need the server certificate and key")
+	check(len(cfg.EtcdKeyFile) > 0 && len(cfg.EtcdCertFile) == 0, "the etcd client key needs the etcd client certificate")
+	if len(cfg.TLSCertFile) > 0 {
+		if _, err := util.ServerTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile); err != nil {
+			errs = append(errs, fmt.Errorf("TLS: %v", err))
+		}
+	}
+	if len(cfg.EtcdCAFile) > 0 || len(cfg.EtcdCertFile) > 0 {
+		if _, err := util.ClientTLSConfig(cfg.EtcdCAFile, cfg.EtcdCertFile, cfg.EtcdKeyFile, ""); err != nil {
+			errs = append(errs, fmt.Errorf("etcd TLS: %v", err))
+		}
+	}
+
+	s := &UUIDServer{cfg: cfg}
+	for _, init := range []func() error{s.initNamespaces, s.initPolicy, s.initQuotas, s.initAuth} {
+		if err := init(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// etcdWrapConfig the etcd client config of the server.
+func (cfg *Config) etcdWrapConfig() *EtcdWrapConfig {
+	return &EtcdWrapConfig{
+		Endpoints: cfg.Endpoints,
+		UserName:  cfg.UserName,
+		Password:  cfg.Password,
+		Logger:    cfg.Logger,
+
+		TracerProvider: cfg.TracerProvider,
+		CAFile:         cfg.EtcdCAFile,
+		CertFile:       cfg.EtcdCertFile,
+		KeyFile:        cfg.EtcdKeyFile,
+	}
 }
 
 // UUIDServer UUID server.
@@ -60,19 +229,78 @@ type UUIDServer struct {
 	etcdWrap   *EtcdWrap
 	listen     net.Listener
 	grpcServer *grpc.Server
+	allowlist  namePatterns
+	namespaces map[string]*namespace
+
+	serviceQuotas  map[string]*quotaLimiter
+	containerQuota *quotaLimiter
+
+	authenticator      Authenticator
+	adminAuthenticator Authenticator
+	acl                map[string]namePatterns
+
+	draining int32
+	health   healthState
+
+	httpListen net.Listener
+	httpServer *http.Server
+	httpMux    *http.ServeMux
+
+	resp respState
+
+	ledgers  []ledger
+	instance string
+
+	// containerName the container of the server host, the container of the
+	// requests of the HTTP gateway without one and of the RESP frontend. Read
+	// once, it forks a shell.
+	containerName string
+
+	fence fenceState
 }
 
 // Fetch get UUID range through the server.
-func (s *UUIDServer) Fetch(ctx context.Context, in *api.FetchRequest) (*api.FetchReply, error) {
-	result := &api.FetchReply{}
-	leftCount := int(in.NeedCount)
+func (s *UUIDServer) Fetch(ctx context.Context, in *api.FetchRequest) (reply *api.FetchReply, err error) {
+	var ns *namespace
+	start := time.Now()
+	defer func() { observeFetch(ns, in.ServiceName, start, err) }()
 
-	// t1 := time.Now()
-	// log.Printf("Fetch request: %v", in)
-	// defer log.Printf("Fetch response: %v, cost time: %v", result, time.Since(t1))
+	err = s.checkDraining()
+	if err != nil {
+		return nil, err
+	}
+	err = s.authorize(ctx, in.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ns, err = s.namespace(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	err = checkName("service", in.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	// clients before the names were checked sent the newline of the shell output,
+	// the schema upgrade moved the keys of those names to the trimmed names
+	if name := strings.TrimRight(in.ContainerName, "\r\n"); name != in.ContainerName {
+		s.logger().Debug("flake container name trimmed", "container", in.ContainerName, "trimmed", name)
+		in.ContainerName = name
+	}
+	err = checkName("container", in.ContainerName)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkQuota(ns, in.ServiceName, in.ContainerName, int(in.NeedCount))
+	if err != nil {
+		return nil, err
+	}
+	result := &api.FetchReply{Layout: &api.Layout{ServiceBits: int32(ns.layout.ServiceBits),
+		ContainerBits: int32(ns.layout.ContainerBits), SequenceBits: int32(ns.layout.SequenceBits)}}
+	leftCount := int(in.NeedCount)
 
 	for {
-		serviceID, containerID, startID, endID, err := s.getUUIDSegment(in.ServiceName, in.ContainerName, int(leftCount))
+		serviceID, containerID, startID, endID, err := s.getUUIDSegment(ctx, ns, in.ServiceName, in.ContainerName, int(leftCount))
 		if err != nil {
 			return nil, err
 		}
@@ -80,6 +308,7 @@ func (s *UUIDServer) Fetch(ctx context.Context, in *api.FetchRequest) (*api.Fetc
 		item := &api.UUIDRange{ContainerId: int32(containerID), ServiceId: int32(serviceID),
 			SequenceIdStart: int32(startID), SequenceIdEnd: int32(endID)}
 		result.Items = append(result.Items, item)
+		s.recordRange(ctx, ns, in, item)
 
 		leftCount = leftCount - (endID - startID + 1)
 		if leftCount == 0 {
@@ -88,59 +317,99 @@ func (s *UUIDServer) Fetch(ctx context.Context, in *api.FetchRequest) (*api.Fetc
 	}
 }
 
-func (s *UUIDServer) getServieID(serviceName string) (id int, err error) {
-	key := s.cfg.Prefix + "/" + KeyOfServiceDir + "/" + serviceName
+func (s *UUIDServer) getServieID(ctx context.Context, ns *namespace, serviceName string) (id int, err error) {
+	ctx, span := s.tracer().Start(ctx, "getServieID", trace.WithAttributes(attribute.String("flake.service", serviceName)))
+	defer func() { endSpan(span, err) }()
+
+	key := ns.nameKey(KeyOfServiceDir, serviceName)
 	serviceID := 0
 	for {
-		r, err := s.etcdWrap.Get(key)
+		r, err := s.etcdWrap.Get(ctx, key)
 		if err != nil {
 			if s.etcdWrap.IsKeyNotFound(err) {
 				if serviceID == 0 {
-					serviceID, err = s.nextServiceID()
+					err = s.checkRegistration(serviceName)
+					if err != nil {
+						return 0, err
+					}
+					serviceID, err = s.nextServiceID(ctx, ns, serviceName)
 					if err != nil {
 						return 0, err
 					}
 				}
-				resp, err := s.etcdWrap.Set(key, strconv.Itoa(serviceID), &client.SetOptions{PrevExist: "false"})
+				resp, err := s.etcdWrap.Set(ctx, key, strconv.Itoa(serviceID), &client.SetOptions{PrevExist: "false"})
 				if err != nil {
 					// create conflict, again
+					countConflict("service", err)
 					continue
 				}
 				// create success
 				return strconv.Atoi(resp.Node.Value)
 			}
-			return 0, nil
+			return 0, err
+		}
+		if serviceID != 0 {
+			// the name was created by someone else, give the claimed ID back
+			s.releaseServiceID(ctx, ns, serviceID, serviceName)
 		}
 		// get success
-		return strconv.Atoi(r.Node.Value)
+		id, retired, err := parseServiceRecord(r.Node.Value)
+		if err != nil {
+			return 0, err
+		}
+		if retired {
+			return 0, status.Errorf(codes.FailedPrecondition, "service %q is retired", serviceName)
+		}
+		return id, nil
 	}
 }
 
-func (s *UUIDServer) nextServiceID() (id int, err error) {
-	key := s.cfg.Prefix + "/" + KeyOfMaxServiceID
-	result, err := s.etcdWrap.AtomAdd(key, 1)
-	if err != nil {
-		return 0, err
+// nextServiceID assigns the next free service ID to serviceName.
+// IDs pinned through the admin API are skipped.
+func (s *UUIDServer) nextServiceID(ctx context.Context, ns *namespace, serviceName string) (id int, err error) {
+	key := ns.key(KeyOfMaxServiceID)
+	for {
+		result, err := s.etcdWrap.AtomAdd(ctx, key, 1)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.observeKey(ns, KeyOfMaxServiceID, result-1, true); err != nil {
+			return 0, err
+		}
+		s.recordKey(ns, KeyOfMaxServiceID, result, 0)
+		if result >= ns.layout.MaxServiceID() {
+			return 0, status.Errorf(codes.ResourceExhausted, "service ID space is exhausted (max %d)", ns.layout.MaxServiceID()-1)
+		}
+		ok, err := s.claimServiceID(ctx, ns, result, serviceName)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return result, nil
+		}
 	}
-	return result, nil
 }
 
-func (s *UUIDServer) getContainerID(containerName string) (id int, err error) {
-	key := s.cfg.Prefix + "/" + KeyOfContainerDir + "/" + containerName
+func (s *UUIDServer) getContainerID(ctx context.Context, ns *namespace, containerName string) (id int, err error) {
+	ctx, span := s.tracer().Start(ctx, "getContainerID", trace.WithAttributes(attribute.String("flake.container", containerName)))
+	defer func() { endSpan(span, err) }()
+
+	key := ns.nameKey(KeyOfContainerDir, containerName)
 	containerID := 0
 	for {
-		r, err := s.etcdWrap.Get(key)
+		r, err := s.etcdWrap.Get(ctx, key)
 		if err != nil {
 			if s.etcdWrap.IsKeyNotFound(err) {
 				if containerID == 0 {
-					containerID, err = s.nextContainerID()
+					containerID, err = s.nextContainerID(ctx, ns, containerName)
 					if err != nil {
 						return 0, err
 					}
 				}
-				resp, err := s.etcdWrap.Set(key, strconv.Itoa(containerID), &client.SetOptions{PrevExist: "false"})
+				resp, err := s.etcdWrap.Set(ctx, key, strconv.Itoa(containerID), &client.SetOptions{PrevExist: "false"})
 				if err != nil {
 					// create conflict, again
+					countConflict("container", err)
 					continue
 				}
 				return strconv.Atoi(resp.Node.Value)
@@ -151,72 +420,107 @@ func (s *UUIDServer) getContainerID(containerName string) (id int, err error) {
 	}
 }
 
-func (s *UUIDServer) nextContainerID() (id int, err error) {
-	key := s.cfg.Prefix + "/" + KeyOfMaxContainerID
-	result, err := s.etcdWrap.AtomAdd(key, 1)
+// nextContainerID assigns the next container ID to containerName. The owner of
+// the ID is recorded before it is used, so IDs can be resolved after a reassignment.
+func (s *UUIDServer) nextContainerID(ctx context.Context, ns *namespace, containerName string) (id int, err error) {
+	key := ns.key(KeyOfMaxContainerID)
+	result, err := s.etcdWrap.AtomAdd(ctx, key, 1)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.observeKey(ns, KeyOfMaxContainerID, result-1, true); err != nil {
+		return 0, err
+	}
+	s.recordKey(ns, KeyOfMaxContainerID, result, 0)
+	if result >= ns.layout.MaxContainerID() {
+		return 0, status.Errorf(codes.ResourceExhausted, "container ID space is exhausted (max %d)", ns.layout.MaxContainerID()-1)
+	}
+	_, err = s.etcdWrap.Set(ctx, ns.key(KeyOfContainerIDDir, strconv.Itoa(result)), containerName, nil)
 	if err != nil {
 		return 0, err
 	}
 	return result, nil
 }
 
-// ReassignContainerID reassign an ID to the container.
+// ReassignContainerID reassign an ID to the container of the default namespace.
 func (s *UUIDServer) ReassignContainerID(containerName string) error {
-	key := s.cfg.Prefix + "/" + KeyOfContainerDir + "/" + containerName
-	containerID, err := s.nextContainerID()
+	return s.reassignContainerID(context.Background(), s.namespaces[""], containerName)
+}
+
+func (s *UUIDServer) reassignContainerID(ctx context.Context, ns *namespace, containerName string) error {
+	key := ns.nameKey(KeyOfContainerDir, containerName)
+	containerID, err := s.nextContainerID(ctx, ns, containerName)
 	if err != nil {
 		return err
 	}
 	for {
-		r, err := s.etcdWrap.Get(key)
+		r, err := s.etcdWrap.Get(ctx, key)
 		if err != nil {
 			return err
 		}
-		_, err = s.etcdWrap.Set(key, strconv.Itoa(containerID), &client.SetOptions{PrevIndex: r.Node.ModifiedIndex})
+		_, err = s.etcdWrap.Set(ctx, key, strconv.Itoa(containerID), &client.SetOptions{PrevIndex: r.Node.ModifiedIndex})
 		if err != nil {
 			// modify conflict, again
+			countConflict("reassign", err)
 			continue
 		}
+		containerReassignments.WithLabelValues(ns.name).Inc()
+		oldID, _ := strconv.Atoi(r.Node.Value)
+		s.logReassignment(ctx, ns, containerName, oldID, containerID)
 		return nil
 	}
 }
 
-func (s *UUIDServer) getUUIDSegment(serviceName string, containerName string, needCount int) (serviceID int, containerID int, startID int, endID int, err error) {
+func (s *UUIDServer) getUUIDSegment(ctx context.Context, ns *namespace, serviceName string, containerName string, needCount int) (serviceID int, containerID int, startID int, endID int, err error) {
+	ctx, span := s.tracer().Start(ctx, "getUUIDSegment", trace.WithAttributes(attribute.String("flake.namespace", ns.name),
+		attribute.String("flake.service", serviceName), attribute.String("flake.container", containerName), attribute.Int("flake.need_count", needCount)))
+	defer func() { endSpan(span, err) }()
+
 	// if unuse serviceName then serviceID = 1
 	serviceID = 1
 	containerID = 1
 	if len(serviceName) > 0 {
-		serviceID, err = s.getServieID(serviceName)
+		serviceID, err = s.getServieID(ctx, ns, serviceName)
 		if err != nil {
 			return 0, 0, 0, 0, err
 		}
 	}
 
-	containerID, err = s.getContainerID(containerName)
+	containerID, err = s.getContainerID(ctx, ns, containerName)
 	if err != nil {
 		return 0, 0, 0, 0, err
 	}
 
-	key := fmt.Sprintf("%s/%d:%d", s.cfg.Prefix, serviceID, containerID)
+	maxOfSequence := ns.layout.MaxSequence()
+	name := fmt.Sprintf("%d:%d", serviceID, containerID)
+	key := ns.key(name)
 	for {
-		resp, err := s.etcdWrap.Get(key)
+		resp, err := s.etcdWrap.Get(ctx, key)
 		if err != nil {
 			if s.etcdWrap.IsKeyNotFound(err) {
+				if err := s.observeKey(ns, name, 0, false); err != nil {
+					return 0, 0, 0, 0, err
+				}
 				startID = 1
 				endID = startID + needCount
-				if endID > MaxOfSequence {
-					err := s.ReassignContainerID(containerName)
+				if endID > maxOfSequence {
+					err := s.reassignContainerID(ctx, ns, containerName)
 					if err != nil {
 						return 0, 0, 0, 0, err
 					}
-					endID = MaxOfSequence
+					endID = maxOfSequence
 				}
-				resp, err = s.etcdWrap.Set(key, strconv.Itoa(endID), &client.SetOptions{PrevExist: "false"})
-				if err != nil && endID != MaxOfSequence {
+				resp, err = s.etcdWrap.Set(ctx, key, strconv.Itoa(endID), &client.SetOptions{PrevExist: "false"})
+				if err != nil && endID != maxOfSequence {
 					// create conflict, again
+					countConflict("sequence", err)
 					continue
 				}
 				// create success
+				if resp != nil {
+					s.recordKey(ns, name, endID, resp.Index)
+				}
+				s.observeSegment(ns, serviceName, startID, endID)
 				return serviceID, containerID, startID, endID - 1, nil
 			}
 			return 0, 0, 0, 0, err
@@ -226,91 +530,314 @@ func (s *UUIDServer) getUUIDSegment(serviceName string, containerName string, ne
 		if err != nil {
 			return 0, 0, 0, 0, err
 		}
+		if err := s.observeKey(ns, name, startID, true); err != nil {
+			return 0, 0, 0, 0, err
+		}
 
-		if startID == MaxOfSequence {
+		if startID == maxOfSequence {
 			// deadlock prevention
-			err := s.ReassignContainerID(containerName)
+			err := s.reassignContainerID(ctx, ns, containerName)
 			if err != nil {
 				return 0, 0, 0, 0, err
 			}
 			// container id reassigned, relaunch function
-			return s.getUUIDSegment(serviceName, containerName, needCount)
+			return s.getUUIDSegment(ctx, ns, serviceName, containerName, needCount)
 		}
 
 		endID = startID + needCount
-		if endID > MaxOfSequence {
-			err := s.ReassignContainerID(containerName)
+		if endID > maxOfSequence {
+			err := s.reassignContainerID(ctx, ns, containerName)
 			if err != nil {
 				return 0, 0, 0, 0, err
 			}
-			endID = MaxOfSequence
+			endID = maxOfSequence
 		}
-		resp, err = s.etcdWrap.Set(key, strconv.Itoa(endID), &client.SetOptions{PrevIndex: resp.Node.ModifiedIndex})
+		resp, err = s.etcdWrap.Set(ctx, key, strconv.Itoa(endID), &client.SetOptions{PrevIndex: resp.Node.ModifiedIndex})
 		if err != nil {
 			// modify conflict, again
+			countConflict("sequence", err)
 			continue
 		}
 		// modify success
+		s.recordKey(ns, name, endID, resp.Index)
+		s.observeSegment(ns, serviceName, startID, endID)
 		return serviceID, containerID, startID, endID - 1, nil
 	}
 }
 
-func (s *UUIDServer) initUUIDData() (success bool, err error) {
-	serviceResp, err := s.etcdWrap.GetNCreate(s.cfg.Prefix+"/"+KeyOfMaxServiceID, StartOfServerID)
-	if err != nil {
-		return false, err
+func (s *UUIDServer) initUUIDData(ctx context.Context) (success bool, err error) {
+	for _, ns := range s.namespaces {
+		serviceResp, err := s.etcdWrap.GetNCreate(ctx, ns.key(KeyOfMaxServiceID), StartOfServerID)
+		if err != nil {
+			return false, err
+		}
+		containerResp, err := s.etcdWrap.GetNCreate(ctx, ns.key(KeyOfMaxContainerID), StartOfContainerID)
+		if err != nil {
+			return false, err
+		}
+
+		s.logger().Info("flake namespace", "namespace", ns.name, "prefix", ns.prefix, "layout", ns.layout.String(),
+			"max_serviceid", serviceResp.Node.Value, "max_containerid", containerResp.Node.Value)
 	}
-	containerResp, err := s.etcdWrap.GetNCreate(s.cfg.Prefix+"/"+KeyOfMaxContainerID, StartOfContainerID)
-	if err != nil {
-		return false, err
+	return true, nil
+}
+
+// checkStore makes sure the etcd credentials can read and write under the prefix of every namespace.
+func (s *UUIDServer) checkStore(ctx context.Context) error {
+	for _, ns := range s.namespaces {
+		err := s.etcdWrap.Probe(ctx, ns.key(KeyOfProbeDir, strconv.FormatInt(time.Now().UnixNano(), 10)))
+		if err != nil {
+			return fmt.Errorf("etcd check of namespace %q: %v", ns.name, err)
+		}
 	}
+	return nil
+}
 
-	log.Printf("flake max_serviceid:%v, max_containerid:%v", serviceResp.Node.Value, containerResp.Node.Value)
-	return true, nil
+func (s *UUIDServer) serverOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+	if len(s.cfg.TLSCertFile) > 0 {
+		tlsCfg, err := util.ServerTLSConfig(s.cfg.TLSCertFile, s.cfg.TLSKeyFile, s.cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	} else if len(s.cfg.TLSClientCAFile) > 0 {
+		return nil, fmt.Errorf("client certificates need the server certificate and key")
+	}
+	// the access log runs after the authentication to see the identity,
+	// rejected tokens are logged by the authentication itself.
+	// the admin service is closed without admin tokens, so the authentication always runs.
+	interceptors := []grpc.UnaryServerInterceptor{s.traceUnaryInterceptor, s.authUnaryInterceptor}
+	if s.cfg.AccessLogSampleRate > 0 {
+		interceptors = append(interceptors, s.accessLogInterceptor)
+	}
+	opts = append(opts, grpc.UnaryInterceptor(chainUnaryInterceptors(interceptors...)))
+	return opts, nil
 }
 
-// StartServer create a server and run it.
-func StartServer(cfg *Config) (*UUIDServer, error) {
+// NewServer create a server listening on cfg.ListenAddress, call Serve to run it.
+func NewServer(cfg *Config) (*UUIDServer, error) {
 	rand.Seed(time.Now().UnixNano())
 
+	ctx := context.Background()
 	svr := &UUIDServer{cfg: cfg}
-	log.Printf("flake config: %v", cfg)
+	svr.logger().Info("flake config", "version", Version, "schema", SchemaVersion, "config", cfg)
+
+	err := svr.initNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	err = svr.initPolicy()
+	if err != nil {
+		return nil, err
+	}
+	err = svr.initQuotas()
+	if err != nil {
+		return nil, err
+	}
+	err = svr.initAuth()
+	if err != nil {
+		return nil, err
+	}
 
 	// init etcdclient
-	etcdWrapCfg := &EtcdWrapConfig{
-		Endpoints: svr.cfg.Endpoints,
-		UserName:  svr.cfg.UserName,
-		Password:  svr.cfg.Password,
+	svr.etcdWrap, err = NewEtcdWrap(cfg.etcdWrapConfig())
+	if err != nil {
+		return nil, err
 	}
 
-	var err error
-	svr.etcdWrap, err = NewEtcdWrap(etcdWrapCfg)
+	ver, err := svr.etcdWrap.GetVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
+	svr.logger().Info("etcd version", "server", ver.Server, "cluster", ver.Cluster)
 
-	ver, err := svr.etcdWrap.GetVersion()
+	err = svr.checkStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = svr.initSchema(ctx)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("etcd version: %v", ver)
 
 	// init uuid server
-	svr.initUUIDData()
+	_, err = svr.initUUIDData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = svr.initFence(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// start gRpc service
-	svr.listen, err = net.Listen("tcp", cfg.ListenAddress)
+	err = svr.initLedger()
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("flake listen on %v", cfg.ListenAddress)
+	err = svr.initGRPC()
+	if err != nil {
+		svr.closeLedger()
+		return nil, err
+	}
+	err = svr.initHTTP()
+	if err != nil {
+		svr.listen.Close()
+		svr.closeLedger()
+		return nil, err
+	}
+	err = svr.initRESP()
+	if err != nil {
+		svr.listen.Close()
+		if svr.httpListen != nil {
+			svr.httpListen.Close()
+		}
+		svr.closeLedger()
+		return nil, err
+	}
+	return svr, nil
+}
+
+// initGRPC listen on the address and register the services.
+func (s *UUIDServer) initGRPC() error {
+	opts, err := s.serverOptions()
+	if err != nil {
+		return err
+	}
+	s.listen, err = net.Listen("tcp", s.cfg.ListenAddress)
+	if err != nil {
+		return err
+	}
+	s.logger().Info("flake listen", "address", s.listen.Addr().String())
+
+	s.initHealth()
+	s.grpcServer = grpc.NewServer(opts...)
+	api.RegisterUUIDServer(s.grpcServer, s)
+	api.RegisterSequenceServer(s.grpcServer, s)
+	api.RegisterAdminServer(s.grpcServer, s)
+	healthpb.RegisterHealthServer(s.grpcServer, s.health.server)
+	return nil
+}
+
+// initHTTP listen on the HTTP address and register the HTTP endpoints.
+func (s *UUIDServer) initHTTP() (err error) {
+	if len(s.cfg.HTTPListenAddress) == 0 {
+		return nil
+	}
+	s.httpListen, err = net.Listen("tcp", s.cfg.HTTPListenAddress)
+	if err != nil {
+		return err
+	}
+	s.logger().Info("flake http listen", "address", s.httpListen.Addr().String())
+
+	s.httpMux = http.NewServeMux()
+	s.httpMux.HandleFunc("/healthz", s.handleHealthz)
+	s.httpMux.HandleFunc("/readyz", s.handleReadyz)
+	s.httpMux.Handle("/metrics", promhttp.HandlerFor(Metrics, promhttp.HandlerOpts{}))
+	if s.cfg.HTTPGateway {
+		s.initContainerName()
+		s.httpMux.HandleFunc(gatewayPath, s.handleGateway)
+	}
+	s.httpServer = &http.Server{Handler: s.httpMux}
+	return nil
+}
 
-	svr.grpcServer = grpc.NewServer()
-	api.RegisterUUIDServer(svr.grpcServer, svr)
-	svr.grpcServer.Serve(svr.listen)
+func (s *UUIDServer) initContainerName() {
+	if len(s.containerName) == 0 {
+		s.containerName = util.GetContainerName()
+	}
+}
 
-	return svr, nil
+// Addr returns the address the server listens on.
+func (s *UUIDServer) Addr() net.Addr {
+	return s.listen.Addr()
+}
+
+// HTTPAddr returns the address of the HTTP endpoints, nil if they are disabled.
+func (s *UUIDServer) HTTPAddr() net.Addr {
+	if s.httpListen == nil {
+		return nil
+	}
+	return s.httpListen.Addr()
+}
+
+// Serve accept connections until the server is shut down.
+func (s *UUIDServer) Serve() error {
+	go s.watchHealth()
+	if s.fence.state != nil {
+		go s.saveStateLoop()
+	}
+	if s.httpServer != nil {
+		go func() {
+			err := s.httpServer.Serve(s.httpListen)
+			if err != nil && err != http.ErrServerClosed {
+				s.logger().Error("flake http", "error", err)
+			}
+		}()
+	}
+	if s.resp.listen != nil {
+		go s.serveRESP()
+	}
+	return s.grpcServer.Serve(s.listen)
+}
+
+// Drain refuse new Fetch and Reserve calls with Unavailable, so clients move to
+// other servers, the calls in flight and the other RPCs go on.
+func (s *UUIDServer) Drain() {
+	if atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+		s.logger().Warn("flake is draining")
+		s.publishHealth()
+	}
+}
+
+// Draining returns true once Drain is called.
+func (s *UUIDServer) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// checkDraining rejects new work while the server drains or is fenced.
+func (s *UUIDServer) checkDraining() error {
+	if s.Draining() {
+		return status.Error(codes.Unavailable, "flake server is draining")
+	}
+	return s.fenceError()
+}
+
+// Shutdown drain the server and wait for the calls in flight to finish.
+// If ctx is done first the remaining connections are closed and ctx.Err() is returned.
+func (s *UUIDServer) Shutdown(ctx context.Context) error {
+	s.Drain()
+	s.stopHealth()
+	if s.httpServer != nil {
+		s.httpServer.Shutdown(ctx)
+	}
+	s.stopRESP()
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		// the listener is only closed by gRPC if Serve was called
+		s.listen.Close()
+		s.closeLedger()
+		s.stopFence()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		<-stopped
+		return ctx.Err()
+	}
+}
+
+// StartServer create a server and run it until it is shut down.
+//
+// Deprecated: use NewServer and Serve, which allow to shut the server down.
+func StartServer(cfg *Config) (*UUIDServer, error) {
+	svr, err := NewServer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return svr, svr.Serve()
 }
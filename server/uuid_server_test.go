@@ -0,0 +1,116 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/client"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blockingKeys blocks every Get once block is set, until release is closed.
+type blockingKeys struct {
+	*memKeys
+	block   chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingKeys) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	select {
+	case b.block <- struct{}{}:
+		<-b.release
+	default:
+	}
+	return b.memKeys.Get(ctx, key, opts)
+}
+
+func TestShutdown(t *testing.T) {
+	s := newTestServer(t, &Config{ListenAddress: "127.0.0.1:0"})
+	keys := &blockingKeys{memKeys: s.etcdWrap.etcdAPI.(*memKeys), block: make(chan struct{}), release: make(chan struct{})}
+	s.etcdWrap.etcdAPI = keys
+	if err := s.initGRPC(); err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve() }()
+
+	conn, err := grpc.Dial(s.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	uuid := api.NewUUIDClient(conn)
+
+	// a fetch in flight when the shutdown starts
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := uuid.Fetch(context.Background(), &api.FetchRequest{ServiceName: "TestShutdown", ContainerName: "c", NeedCount: 10})
+		fetched <- err
+	}()
+	<-keys.block
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	for !s.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned before the fetch finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(keys.release)
+	if err := <-fetched; err != nil {
+		t.Fatalf("the fetch in flight must finish, got %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	s := newTestServer(t, &Config{ListenAddress: "127.0.0.1:0"})
+	if err := s.initGRPC(); err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	defer s.Shutdown(context.Background())
+
+	c, err := client.NewClient(&client.Config{Endpoint: s.Addr().String(), NeedCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.GenUUID("TestDrain"); err != nil {
+		t.Fatal(err)
+	}
+	s.Drain()
+	if _, err := c.GenUUID("TestDrain"); status.Code(err) != codes.Unavailable {
+		t.Fatalf("want Unavailable while draining, got %v", err)
+	}
+	_, err = s.Reserve(context.Background(), &api.ReserveRequest{ServiceName: "TestDrain"})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("want Unavailable while draining, got %v", err)
+	}
+
+	// stopping a server that never served does not block
+	idle := newTestServer(t, &Config{ListenAddress: "127.0.0.1:0"})
+	if err := idle.initGRPC(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := idle.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
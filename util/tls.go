@@ -0,0 +1,180 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate and its key from files,
+// the files are loaded again when they are modified.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	lock    sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader create a reloader and load the certificate.
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Certificate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Certificate returns the current certificate.
+// If the files are modified but can not be loaded, the previous certificate is kept.
+func (r *CertReloader) Certificate() (*tls.Certificate, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err == nil && modTime.After(r.modTime) {
+		cert, loadErr := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if loadErr == nil {
+			r.cert, r.modTime = &cert, modTime
+		}
+		err = loadErr
+	}
+	if r.cert == nil {
+		return nil, fmt.Errorf("load certificate %v: %v", r.certFile, err)
+	}
+	return r.cert, nil
+}
+
+// GetCertificate used as tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate()
+}
+
+// GetClientCertificate used as tls.Config.GetClientCertificate.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate()
+}
+
+// CAReloader serves a pool of CA certificates from a PEM file,
+// the file is loaded again when it is modified.
+type CAReloader struct {
+	caFile string
+
+	lock    sync.Mutex
+	pool    *x509.CertPool
+	modTime time.Time
+}
+
+// NewCAReloader create a reloader and load the CA certificates.
+func NewCAReloader(caFile string) (*CAReloader, error) {
+	r := &CAReloader{caFile: caFile}
+	if _, err := r.Pool(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Pool returns the current pool.
+// If the file is modified but can not be loaded, the previous pool is kept.
+func (r *CAReloader) Pool() (*x509.CertPool, error) {
+	modTime, err := latestModTime(r.caFile)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err == nil && modTime.After(r.modTime) {
+		var pool *x509.CertPool
+		pool, err = LoadCertPool(r.caFile)
+		if err == nil {
+			r.pool, r.modTime = pool, modTime
+		}
+	}
+	if r.pool == nil {
+		return nil, err
+	}
+	return r.pool, nil
+}
+
+// LoadCertPool load the PEM encoded certificates of a file into a pool.
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %v", caFile)
+	}
+	return pool, nil
+}
+
+// ServerTLSConfig build the TLS config of a server.
+// If clientCAFile is set, clients must present a certificate signed by one of its CAs.
+func ServerTLSConfig(certFile string, keyFile string, clientCAFile string) (*tls.Config, error) {
+	certs, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(clientCAFile) == 0 {
+		return &tls.Config{GetCertificate: certs.GetCertificate, MinVersion: tls.VersionTLS12}, nil
+	}
+
+	cas, err := NewCAReloader(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			pool, err := cas.Pool()
+			if err != nil {
+				return nil, err
+			}
+			return &tls.Config{
+				GetCertificate: certs.GetCertificate,
+				ClientCAs:      pool,
+				ClientAuth:     tls.RequireAndVerifyClientCert,
+				MinVersion:     tls.VersionTLS12,
+			}, nil
+		},
+	}, nil
+}
+
+// ClientTLSConfig build the TLS config of a client.
+// Without caFile the system roots are used, without certFile no client certificate is sent.
+func ClientTLSConfig(caFile string, certFile string, keyFile string, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if len(caFile) > 0 {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if len(certFile) > 0 {
+		certs, err := NewCertReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = certs.GetClientCertificate
+	}
+	return cfg, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataCarrier carries the trace context in gRPC metadata.
+type MetadataCarrier metadata.MD
+
+// Get returns the first value of the key.
+func (c MetadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Set sets the value of the key.
+func (c MetadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys returns the keys of the metadata.
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
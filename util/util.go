@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"fmt"
 	"os/exec"
 	"strings"
 )
@@ -12,7 +13,7 @@ func GetContainerName() string {
 	if err != nil {
 		return ""
 	}
-	items := strings.Split(out, "/")
+	items := strings.Split(strings.TrimSpace(out), "/")
 	if l := len(items); l > 0 {
 		return items[l-1]
 	}
@@ -30,6 +31,111 @@ func execShell(s string) (string, error) {
 	return out.String(), err
 }
 
+const (
+	// FirstServiceID the first service ID assigned by the server, the IDs below are reserved.
+	FirstServiceID = 10
+	// FirstContainerID the first container ID assigned by the server, the IDs below are reserved.
+	FirstContainerID = 10
+	// FirstSequence the first sequence of a container.
+	FirstSequence = 1
+)
+
+// Layout the number of bits of each part of a UUID.
+// The three parts take the 63 bits after the sign bit.
+type Layout struct {
+	ServiceBits   int
+	ContainerBits int
+	SequenceBits  int
+}
+
+// DefaultLayout the layout of the UUID generated by GenUUID.
+var DefaultLayout = Layout{ServiceBits: 10, ContainerBits: 22, SequenceBits: 31}
+
+// ParseLayout parse a layout written as "service/container/sequence" bits, e.g. "10/22/31".
+func ParseLayout(s string) (Layout, error) {
+	l := Layout{}
+	_, err := fmt.Sscanf(s, "%d/%d/%d", &l.ServiceBits, &l.ContainerBits, &l.SequenceBits)
+	if err != nil {
+		return l, fmt.Errorf("invalid layout %q, want service/container/sequence bits: %v", s, err)
+	}
+	return l, l.Validate()
+}
+
+// String returns the layout as "service/container/sequence" bits.
+func (l Layout) String() string {
+	return fmt.Sprintf("%d/%d/%d", l.ServiceBits, l.ContainerBits, l.SequenceBits)
+}
+
+// Validate check that every part fits in an int32, all parts take 63 bits and
+// each part has room above the IDs the server reserves.
+func (l Layout) Validate() error {
+	for _, bits := range []int{l.ServiceBits, l.ContainerBits, l.SequenceBits} {
+		if bits < 1 || bits > 31 {
+			return fmt.Errorf("invalid layout %v, every part must take 1 to 31 bits", l)
+		}
+	}
+	if l.ServiceBits+l.ContainerBits+l.SequenceBits != 63 {
+		return fmt.Errorf("invalid layout %v, the parts must take 63 bits", l)
+	}
+	// the server needs at least two IDs above the reserved ones of each part
+	switch {
+	case l.MaxServiceID() <= FirstServiceID+1:
+		return fmt.Errorf("invalid layout %v, the service IDs start at %d, the service part needs more bits", l, FirstServiceID)
+	case l.MaxContainerID() <= FirstContainerID+1:
+		return fmt.Errorf("invalid layout %v, the container IDs start at %d, the container part needs more bits", l, FirstContainerID)
+	case l.MaxSequence() <= FirstSequence+1:
+		return fmt.Errorf("invalid layout %v, the sequences start at %d, the sequence part needs more bits", l, FirstSequence)
+	}
+	return nil
+}
+
+// IsZero returns true if no bits are set.
+func (l Layout) IsZero() bool {
+	return l == Layout{}
+}
+
+// MaxServiceID the upper bound (exclusive) of the service ID.
+func (l Layout) MaxServiceID() int {
+	return 1 << uint(l.ServiceBits)
+}
+
+// MaxContainerID the upper bound (exclusive) of the container ID.
+func (l Layout) MaxContainerID() int {
+	return 1 << uint(l.ContainerBits)
+}
+
+// MaxSequence the upper bound (exclusive) of the sequence.
+func (l Layout) MaxSequence() int {
+	return 1 << uint(l.SequenceBits)
+}
+
+// Compose generate a 64bit UUID with the layout.
+func (l Layout) Compose(serviceID int32, containerID int32, sequenceID int32) int64 {
+	var uuid uint64
+	uuid |= uint64(serviceID) << uint(l.ContainerBits+l.SequenceBits)
+	uuid |= uint64(containerID) << uint(l.SequenceBits)
+	uuid |= uint64(sequenceID)
+	return int64(uuid)
+}
+
+// ParseUUID split a UUID into the service ID, container ID and sequence with the layout,
+// util.DefaultLayout is used if the layout is zero.
+func ParseUUID(uuid int64, layout Layout) (serviceID int32, containerID int32, sequenceID int32, err error) {
+	if layout.IsZero() {
+		layout = DefaultLayout
+	}
+	if err := layout.Validate(); err != nil {
+		return 0, 0, 0, err
+	}
+	if uuid < 0 {
+		return 0, 0, 0, fmt.Errorf("invalid UUID %d, the sign bit is set", uuid)
+	}
+	serviceID = int32(uuid >> uint(layout.ContainerBits+layout.SequenceBits))
+	containerID = int32((uuid >> uint(layout.SequenceBits)) & int64(layout.MaxContainerID()-1))
+	sequenceID = int32(uuid & int64(layout.MaxSequence()-1))
+	return serviceID, containerID, sequenceID, nil
+}
+
 // GenUUID generate a 64bit UUID.
 //
 // Detail format:
@@ -39,11 +145,7 @@ func execShell(s string) (string, error) {
 // | (1bit) | (10bit) serviceID | (22bit) containerID    | (31bit) sequenceID              |
 // |--------|-------------------|------------------------|---------------------------------|
 func GenUUID(serviceID int32, containerID int32, sequenceID int32) int64 {
-	var uuid uint64
-	uuid |= uint64(serviceID) << 53
-	uuid |= uint64(containerID) << 31
-	uuid |= uint64(sequenceID)
-	return int64(uuid)
+	return DefaultLayout.Compose(serviceID, containerID, sequenceID)
 }
 
 // Min return the smallest number of x, y
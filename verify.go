@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cnwinds/flake/api"
+	"github.com/cnwinds/flake/client"
+	"github.com/cnwinds/flake/server"
+	"github.com/cnwinds/flake/util"
+	"github.com/cnwinds/flake/verify"
+	cli "github.com/urfave/cli/v2"
+)
+
+func verifyCommand() *cli.Command {
+	layoutFlag := func() cli.Flag {
+		return &cli.StringFlag{
+			Name:  "layout",
+			Value: util.DefaultLayout.String(),
+			Usage: "bits of the service/container/sequence parts of the IDs",
+		}
+	}
+	ledgerFlags := append(pickFlags(serverFlags(), "etcdkeyprefix", "etcdhosts", "etcduser", "etcdpassword", "etcdca", "etcdcert", "etcdkey"),
+		&cli.StringFlag{
+			Name:  "namespace",
+			Usage: "namespace of the ranges in the files, the default namespace if empty",
+		},
+		&cli.BoolFlag{
+			Name:  "store",
+			Usage: "also check the ranges of the store ledger (-ledgerstore) under -etcdkeyprefix",
+		},
+	)
+	return &cli.Command{
+		Name:  "verify",
+		Usage: "check that IDs are unique, exits with 1 if any ID was issued twice",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "ledger",
+				Usage:     "check the ranges of ledger files written by -ledgerfile and of the store ledger with -store",
+				ArgsUsage: "FILE...",
+				Flags:     ledgerFlags,
+				Action:    verifyLedger,
+			},
+			{
+				Name:      "ids",
+				Usage:     "check files with one ID per line, - reads the standard input",
+				ArgsUsage: "FILE...",
+				Flags: []cli.Flag{
+					layoutFlag(),
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "dec",
+						Usage: "format of the IDs, dec, hex or base62",
+					},
+				},
+				Action: verifyIDs,
+			},
+			{
+				Name:  "live",
+				Usage: "fetch IDs from the server and check them",
+				Flags: append(clientFlags(),
+					layoutFlag(),
+					&cli.StringFlag{
+						Name:     "service",
+						Aliases:  []string{"s"},
+						Required: true,
+						Usage:    "service name",
+					},
+					&cli.IntFlag{
+						Name:    "count",
+						Aliases: []string{"n"},
+						Value:   1000000,
+						Usage:   "number of IDs",
+					},
+					&cli.IntFlag{
+						Name:  "workers",
+						Value: 8,
+						Usage: "number of clients fetching at the same time",
+					},
+				),
+				Action: verifyLive,
+			},
+		},
+	}
+}
+
+func verifyLedger(c *cli.Context) error {
+	if c.NArg() == 0 && !c.Bool("store") {
+		return fmt.Errorf("want at least one ledger file or -store")
+	}
+	// the ledger does not record the layout, the ranges are kept as sequences
+	v := verify.New(util.Layout{})
+	if c.Bool("store") {
+		cfg := &server.Config{
+			Endpoints:    c.StringSlice("etcdhosts"),
+			Prefix:       c.String("etcdkeyprefix"),
+			UserName:     c.String("etcduser"),
+			Password:     c.String("etcdpassword"),
+			EtcdCAFile:   c.String("etcdca"),
+			EtcdCertFile: c.String("etcdcert"),
+			EtcdKeyFile:  c.String("etcdkey"),
+		}
+		// the prefix selects the namespace, the ranges of the store are not filtered by name
+		skipped, err := server.WalkStoreLedger(context.Background(), cfg, func(entry *api.LedgerEntry) error {
+			return v.AddRange(entry.ServiceId, entry.ContainerId, entry.SequenceIdStart, entry.SequenceIdEnd)
+		})
+		if err != nil {
+			return err
+		}
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "store: skipped %d malformed keys\n", skipped)
+		}
+	}
+	for _, file := range c.Args().Slice() {
+		skipped := 0
+		err := readLines(file, func(line string) error {
+			entry := &api.LedgerEntry{}
+			if err := json.Unmarshal([]byte(line), entry); err != nil {
+				// a line cut short by a crash, the server skips it too
+				skipped++
+				return nil
+			}
+			if entry.Namespace != c.String("namespace") {
+				return nil
+			}
+			return v.AddRange(entry.ServiceId, entry.ContainerId, entry.SequenceIdStart, entry.SequenceIdEnd)
+		})
+		if err != nil {
+			return err
+		}
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "%v: skipped %d malformed lines\n", file, skipped)
+		}
+	}
+	return printResult(v.Result())
+}
+
+func verifyIDs(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("want at least one file")
+	}
+	layout, err := util.ParseLayout(c.String("layout"))
+	if err != nil {
+		return err
+	}
+	if _, err := parseID("0", c.String("format")); err != nil {
+		return err
+	}
+	v := verify.New(layout)
+	for _, file := range c.Args().Slice() {
+		err := readLines(file, func(line string) error {
+			id, err := parseID(line, c.String("format"))
+			if err != nil {
+				return err
+			}
+			return v.AddID(id)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return printResult(v.Result())
+}
+
+func verifyLive(c *cli.Context) error {
+	count, workers := c.Int("count"), c.Int("workers")
+	if count <= 0 || workers <= 0 {
+		return fmt.Errorf("count and workers must be positive")
+	}
+	layout, err := util.ParseLayout(c.String("layout"))
+	if err != nil {
+		return err
+	}
+	v := verify.New(layout)
+	left := int64(count)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- verifyWorker(c, v, &left)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return printResult(v.Result())
+}
+
+// verifyWorker fetches IDs with its own client until left runs out.
+func verifyWorker(c *cli.Context, v *verify.Verifier, left *int64) error {
+	cl, err := client.NewClient(clientConfig(c))
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	for atomic.AddInt64(left, -1) >= 0 {
+		// the timeout applies to each ID, the run may take longer
+		ctx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+		id, err := cl.GenUUIDContext(ctx, c.String("service"))
+		cancel()
+		if err != nil {
+			return err
+		}
+		if err := v.AddID(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLines calls f with every line of the file that is not empty.
+func readLines(file string, f func(line string) error) error {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		fd, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		r = fd
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if err := f(line); err != nil {
+			return fmt.Errorf("%v:%d: %v", file, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func printResult(r verify.Result) error {
+	fmt.Printf("ids:        %d\n", r.IDs)
+	fmt.Printf("spans:      %d\n", r.Spans)
+	fmt.Printf("duplicates: %d\n", r.DuplicateIDs)
+	for _, d := range r.Duplicates {
+		fmt.Printf("duplicate:  %v\n", d)
+	}
+	if !r.OK() {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
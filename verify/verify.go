@@ -0,0 +1,275 @@
+// Package verify checks that IDs are unique without keeping every ID in memory.
+//
+// The IDs are kept as spans of sequences per service and container. The spans
+// issued by flake are contiguous, so adjacent spans are merged and the memory
+// grows with the gaps between the spans instead of the number of IDs.
+//
+// The spans of a service and container are kept in a treap, a balanced search
+// tree, so a span is added in logarithmic time in whatever order the IDs arrive.
+// IDs with many gaps, e.g. a sample of a larger set, need memory for every gap.
+package verify
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cnwinds/flake/util"
+)
+
+const (
+	// DefaultBatchSize how many single IDs are buffered and sorted before they are added as spans.
+	DefaultBatchSize = 1 << 20
+	// DefaultMaxDuplicates how many duplicates are kept for the result, the rest are only counted.
+	DefaultMaxDuplicates = 1000
+)
+
+// Duplicate a span of sequences that was seen more than once.
+type Duplicate struct {
+	ServiceID   int32
+	ContainerID int32
+	// Start and End the first and the last sequence seen again.
+	Start int32
+	End   int32
+}
+
+func (d Duplicate) String() string {
+	if d.Start == d.End {
+		return fmt.Sprintf("service %d container %d sequence %d", d.ServiceID, d.ContainerID, d.Start)
+	}
+	return fmt.Sprintf("service %d container %d sequences %d-%d", d.ServiceID, d.ContainerID, d.Start, d.End)
+}
+
+// Result the summary of the verification.
+type Result struct {
+	// IDs the number of IDs added, duplicates included.
+	IDs uint64
+	// Spans the number of disjoint spans kept in memory.
+	Spans int
+	// Duplicates the first duplicates found, DuplicateIDs counts all of them.
+	Duplicates   []Duplicate
+	DuplicateIDs uint64
+}
+
+// OK returns true if no ID was seen twice.
+func (r Result) OK() bool {
+	return r.DuplicateIDs == 0
+}
+
+type spanKey struct {
+	serviceID   int32
+	containerID int32
+}
+
+// span the sequences from start to end, both included.
+type span struct {
+	start int32
+	end   int32
+}
+
+// Verifier collects ranges and IDs and finds the ones seen more than once.
+// It is safe for concurrent use.
+type Verifier struct {
+	lock   sync.Mutex
+	layout util.Layout
+	spans  map[spanKey]*spanNode
+	count  int
+	// seed the state of the generator of the treap priorities
+	seed uint32
+
+	pending   []int64
+	batchSize int
+
+	ids           uint64
+	duplicates    []Duplicate
+	duplicateIDs  uint64
+	maxDuplicates int
+}
+
+// New create a verifier for the IDs of a layout, util.DefaultLayout is used if it is zero.
+func New(layout util.Layout) *Verifier {
+	if layout.IsZero() {
+		layout = util.DefaultLayout
+	}
+	return &Verifier{
+		layout:        layout,
+		spans:         make(map[spanKey]*spanNode),
+		seed:          1,
+		batchSize:     DefaultBatchSize,
+		maxDuplicates: DefaultMaxDuplicates,
+	}
+}
+
+// SetBatchSize set how many single IDs are buffered before they are added as spans.
+func (v *Verifier) SetBatchSize(n int) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if n > 0 {
+		v.batchSize = n
+	}
+}
+
+// SetMaxDuplicates set how many duplicates are kept for the result.
+func (v *Verifier) SetMaxDuplicates(n int) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.maxDuplicates = n
+}
+
+// AddRange add the sequences from start to end of a service and container, both included.
+func (v *Verifier) AddRange(serviceID int32, containerID int32, start int32, end int32) error {
+	if start > end || start < 0 {
+		return fmt.Errorf("invalid range %d-%d", start, end)
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.ids += uint64(end-start) + 1
+	v.insert(spanKey{serviceID, containerID}, span{start, end})
+	return nil
+}
+
+// AddID add a single ID. The IDs are buffered and sorted, so the runs of
+// consecutive IDs are added as one span.
+func (v *Verifier) AddID(id int64) error {
+	if _, _, _, err := util.ParseUUID(id, v.layout); err != nil {
+		return err
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.ids++
+	v.pending = append(v.pending, id)
+	if len(v.pending) >= v.batchSize {
+		v.flush()
+	}
+	return nil
+}
+
+// Result add the buffered IDs and returns the summary.
+func (v *Verifier) Result() Result {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.flush()
+	return Result{IDs: v.ids, Spans: v.count, Duplicates: append([]Duplicate(nil), v.duplicates...), DuplicateIDs: v.duplicateIDs}
+}
+
+// flush sorts the buffered IDs and adds the runs of consecutive IDs.
+func (v *Verifier) flush() {
+	if len(v.pending) == 0 {
+		return
+	}
+	sort.Slice(v.pending, func(i, j int) bool { return v.pending[i] < v.pending[j] })
+	runStart := 0
+	for i := 1; i <= len(v.pending); i++ {
+		if i < len(v.pending) && v.pending[i] == v.pending[i-1]+1 && v.sameKey(v.pending[i], v.pending[i-1]) {
+			continue
+		}
+		serviceID, containerID, start, _ := util.ParseUUID(v.pending[runStart], v.layout)
+		_, _, end, _ := util.ParseUUID(v.pending[i-1], v.layout)
+		v.insert(spanKey{serviceID, containerID}, span{start, end})
+		runStart = i
+	}
+	v.pending = v.pending[:0]
+}
+
+func (v *Verifier) sameKey(a int64, b int64) bool {
+	shift := uint(v.layout.SequenceBits)
+	return a>>shift == b>>shift
+}
+
+// insert adds s to the disjoint spans of the key. The parts of s that are
+// already present are duplicates, the spans that touch s are merged with it.
+func (v *Verifier) insert(key spanKey, s span) {
+	// the spans before s, the spans that touch or overlap s and the spans after it
+	before, rest := split(v.spans[key], func(t span) bool { return int64(t.end) < int64(s.start)-1 })
+	touching, after := split(rest, func(t span) bool { return int64(t.start) <= int64(s.end)+1 })
+	merged := s
+	n := 0
+	walk(touching, func(t span) {
+		if lo, hi := max32(t.start, s.start), min32(t.end, s.end); lo <= hi {
+			v.duplicate(key, lo, hi)
+		}
+		merged.start = min32(merged.start, t.start)
+		merged.end = max32(merged.end, t.end)
+		n++
+	})
+	v.count += 1 - n
+	v.spans[key] = join(join(before, &spanNode{span: merged, priority: v.priority()}), after)
+}
+
+// priority returns the next number of a xorshift generator.
+func (v *Verifier) priority() uint32 {
+	v.seed ^= v.seed << 13
+	v.seed ^= v.seed >> 17
+	v.seed ^= v.seed << 5
+	return v.seed
+}
+
+// spanNode a node of the treap of spans, ordered by the sequences. A node has a
+// higher priority than its children, with random priorities the depth of the
+// tree is logarithmic in the number of spans.
+type spanNode struct {
+	span
+	priority    uint32
+	left, right *spanNode
+}
+
+// split returns the spans for which before is true and the others, before must
+// be true for the spans up to some sequence and false after it.
+func split(n *spanNode, before func(span) bool) (*spanNode, *spanNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if before(n.span) {
+		l, r := split(n.right, before)
+		n.right = l
+		return n, r
+	}
+	l, r := split(n.left, before)
+	n.left = r
+	return l, n
+}
+
+// join returns the spans of a and b, the spans of a are all before the ones of b.
+func join(a *spanNode, b *spanNode) *spanNode {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.priority > b.priority:
+		a.right = join(a.right, b)
+		return a
+	}
+	b.left = join(a, b.left)
+	return b
+}
+
+// walk calls f with the spans in order.
+func walk(n *spanNode, f func(span)) {
+	for n != nil {
+		walk(n.left, f)
+		f(n.span)
+		n = n.right
+	}
+}
+
+func (v *Verifier) duplicate(key spanKey, start int32, end int32) {
+	v.duplicateIDs += uint64(end-start) + 1
+	if len(v.duplicates) < v.maxDuplicates {
+		v.duplicates = append(v.duplicates, Duplicate{ServiceID: key.serviceID, ContainerID: key.containerID, Start: start, End: end})
+	}
+}
+
+func min32(a int32, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a int32, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,89 @@
+package verify
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/cnwinds/flake/util"
+)
+
+func TestRanges(t *testing.T) {
+	v := New(util.Layout{})
+	for start := int32(1); start < 1000000; start += 1000 {
+		if err := v.AddRange(10, 10, start, start+999); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v.AddRange(10, 11, 1, 100)
+	if r := v.Result(); !r.OK() || r.IDs != 1000100 || r.Spans != 2 {
+		t.Fatalf("contiguous ranges must be merged, got %+v", r)
+	}
+
+	// a range that overlaps two kept spans
+	v.AddRange(10, 11, 200, 300)
+	v.AddRange(10, 11, 50, 250)
+	r := v.Result()
+	if r.OK() || r.DuplicateIDs != 51+51 || r.Spans != 2 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if d := r.Duplicates[0]; d != (Duplicate{ServiceID: 10, ContainerID: 11, Start: 50, End: 100}) {
+		t.Fatalf("unexpected duplicate %v", d)
+	}
+	if d := r.Duplicates[1]; d.Start != 200 || d.End != 250 {
+		t.Fatalf("unexpected duplicate %v", d)
+	}
+	if err := v.AddRange(10, 11, 5, 4); err == nil {
+		t.Fatal("an empty range must be rejected")
+	}
+}
+
+func TestIDs(t *testing.T) {
+	v := New(util.Layout{})
+	v.SetBatchSize(1000)
+	ids := make([]int64, 0, 100000)
+	for seq := int32(1); seq <= 50000; seq++ {
+		ids = append(ids, util.GenUUID(10, 10, seq), util.GenUUID(11, 10, seq))
+	}
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	for _, id := range ids {
+		if err := v.AddID(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r := v.Result(); !r.OK() || r.IDs != 100000 || r.Spans != 2 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	v.AddID(ids[7])
+	v.AddID(ids[7])
+	r := v.Result()
+	if r.DuplicateIDs != 2 || len(r.Duplicates) != 2 {
+		t.Fatalf("want the ID twice, got %+v", r)
+	}
+	if err := v.AddID(-1); err == nil {
+		t.Fatal("a negative ID must be rejected")
+	}
+}
+
+func TestRangesOutOfOrder(t *testing.T) {
+	// spans with gaps added backwards, each one before all the others
+	v := New(util.Layout{})
+	const n = 200000
+	for i := int32(n - 1); i >= 0; i-- {
+		if err := v.AddRange(10, 10, i*10, i*10+4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r := v.Result(); !r.OK() || r.Spans != n {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	// filling the gaps merges everything into one span
+	for i := int32(0); i < n; i++ {
+		v.AddRange(10, 10, i*10+5, i*10+9)
+	}
+	v.AddRange(10, 10, 15, 25)
+	r := v.Result()
+	if r.Spans != 1 || r.DuplicateIDs != 11 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}